@@ -6,66 +6,197 @@ import (
 	"engo.io/ecs"
 )
 
+// AnimationMode is the order in which the frames of an AnimationAction are played
+type AnimationMode uint8
+
+const (
+	// AnimationForward plays the frames from first to last
+	AnimationForward AnimationMode = iota
+	// AnimationReverse plays the frames from last to first
+	AnimationReverse
+	// AnimationPingPong plays the frames from first to last, and then back again
+	AnimationPingPong
+)
+
 type AnimationAction struct {
 	Name   string
 	Frames []int
+	// Durations is how long each frame is shown, in seconds. Frames without a (positive) duration are shown for the
+	// Rate of the AnimationComponent.
+	Durations []float32
+	Mode      AnimationMode
+	// Loops is how many times the animation plays before it stops at its last frame; 0 plays it forever
+	Loops int
+}
+
+// steps returns the number of frames shown during a single loop
+func (a *AnimationAction) steps() int {
+	if a.Mode == AnimationPingPong && len(a.Frames) > 1 {
+		// The first and last frame are only shown once per loop
+		return 2*len(a.Frames) - 2
+	}
+	return len(a.Frames)
+}
+
+// position returns the index in Frames of the given step of a loop
+func (a *AnimationAction) position(step int) int {
+	switch a.Mode {
+	case AnimationReverse:
+		return len(a.Frames) - 1 - step
+	case AnimationPingPong:
+		if step >= len(a.Frames) {
+			return a.steps() - step
+		}
+	}
+	return step
+}
+
+// duration returns how long the frame at the position in Frames is shown, using rate when it has no duration
+func (a *AnimationAction) duration(position int, rate float32) float32 {
+	if position < len(a.Durations) && a.Durations[position] > 0 {
+		return a.Durations[position]
+	}
+	return rate
 }
 
 // Component that controls animation in rendering entities
 type AnimationComponent struct {
-	index            int              // What frame in the is being used
-	Rate             float32          // How often frames should increment, in seconds.
-	change           float32          // The time since the last incrementation
-	Drawables        []Drawable       // Renderables
-	Animations       map[string][]int // All possible animations
-	CurrentAnimation []int            // The current animation
+	index            int                         // What step of the current animation is being shown
+	loop             int                         // How many times the current animation has been played
+	done             bool                        // Whether or not the current animation played all of its Loops
+	dirty            bool                        // Whether or not the frame has to be drawn, without having changed
+	Rate             float32                     // How long frames without a duration are shown, in seconds.
+	change           float32                     // The time since the last incrementation
+	Drawables        []Drawable                  // Renderables
+	Animations       map[string]*AnimationAction // All possible animations
+	CurrentAnimation *AnimationAction            // The current animation
 }
 
 func NewAnimationComponent(drawables []Drawable, rate float32) AnimationComponent {
 	return AnimationComponent{
-		Animations: make(map[string][]int),
+		Animations: make(map[string]*AnimationAction),
 		Drawables:  drawables,
 		Rate:       rate,
 	}
 }
 
+// SelectAnimationByName starts the animation with the given name, unless it's playing already
 func (ac *AnimationComponent) SelectAnimationByName(name string) {
-	ac.CurrentAnimation = ac.Animations[name]
+	ac.selectAnimation(ac.Animations[name])
 }
 
+// SelectAnimationByAction starts the animation with the name of the action, unless it's playing already
 func (ac *AnimationComponent) SelectAnimationByAction(action *AnimationAction) {
-	ac.CurrentAnimation = ac.Animations[action.Name]
+	ac.selectAnimation(ac.Animations[action.Name])
+}
+
+func (ac *AnimationComponent) selectAnimation(action *AnimationAction) {
+	if action == ac.CurrentAnimation {
+		return
+	}
+
+	ac.CurrentAnimation = action
+	ac.Restart()
+}
+
+// Restart plays the current animation from the start
+func (ac *AnimationComponent) Restart() {
+	ac.index, ac.loop, ac.change, ac.done = 0, 0, 0, false
+	ac.dirty = true
+}
+
+// Finished returns whether or not the current animation has played all of its Loops
+func (ac *AnimationComponent) Finished() bool {
+	return ac.done
 }
 
 func (ac *AnimationComponent) AddAnimationAction(action *AnimationAction) {
-	ac.Animations[action.Name] = action.Frames
+	ac.Animations[action.Name] = action
 }
 
 func (ac *AnimationComponent) AddAnimationActions(actions []*AnimationAction) {
 	for _, action := range actions {
-		ac.Animations[action.Name] = action.Frames
+		ac.Animations[action.Name] = action
 	}
 }
 
+// Frame returns the index in the Frames of the current animation that's being shown
+func (ac *AnimationComponent) Frame() int {
+	return ac.CurrentAnimation.position(ac.index)
+}
+
 func (ac *AnimationComponent) Cell() Drawable {
-	idx := ac.CurrentAnimation[ac.index]
+	idx := ac.CurrentAnimation.Frames[ac.Frame()]
 
 	return ac.Drawables[idx]
 }
 
 func (ac *AnimationComponent) NextFrame() {
-	if len(ac.CurrentAnimation) == 0 {
+	if ac.CurrentAnimation == nil || len(ac.CurrentAnimation.Frames) == 0 {
 		log.Println("No data for this animation")
 		return
 	}
 
-	ac.index += 1
-	if ac.index >= len(ac.CurrentAnimation) {
-		ac.index = 0
-	}
+	ac.advance()
 	ac.change = 0
 }
 
+// advance moves to the next step of the current animation, and returns whether or not the frame changed
+func (ac *AnimationComponent) advance() bool {
+	if ac.done {
+		return false
+	}
+
+	action := ac.CurrentAnimation
+	previous := ac.Frame()
+
+	ac.index++
+	if ac.index >= action.steps() {
+		ac.loop++
+		if action.Loops > 0 && ac.loop >= action.Loops {
+			ac.done = true
+			ac.index = action.steps() - 1
+			if action.Mode == AnimationPingPong {
+				// Ping-pong animations end where they started
+				ac.index = 0
+			}
+		} else {
+			ac.index = 0
+		}
+	}
+
+	return ac.Frame() != previous
+}
+
+// update advances the current animation by dt seconds, skipping frames if needed; it returns whether or not the
+// frame has to be drawn, because it changed or because the animation was (re)started
+func (ac *AnimationComponent) update(dt float32) bool {
+	action := ac.CurrentAnimation
+	if action == nil || len(action.Frames) == 0 {
+		return false
+	}
+
+	changed := ac.dirty
+	ac.dirty = false
+	if ac.done {
+		return changed
+	}
+
+	ac.change += dt
+	for !ac.done {
+		duration := action.duration(ac.Frame(), ac.Rate)
+		if duration <= 0 || ac.change < duration {
+			break // because the current frame should still be shown
+		}
+
+		ac.change -= duration
+		if ac.advance() {
+			changed = true
+		}
+	}
+	return changed
+}
+
 type animationEntity struct {
 	*ecs.BasicEntity
 	*AnimationComponent
@@ -95,9 +226,7 @@ func (a *AnimationSystem) Remove(basic ecs.BasicEntity) {
 
 func (a *AnimationSystem) Update(dt float32) {
 	for _, e := range a.entities {
-		e.AnimationComponent.change += dt
-		if e.AnimationComponent.change >= e.AnimationComponent.Rate {
-			e.AnimationComponent.NextFrame()
+		if e.AnimationComponent.update(dt) {
 			e.RenderComponent.SetDrawable(e.AnimationComponent.Cell())
 		}
 	}
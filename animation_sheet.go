@@ -0,0 +1,246 @@
+package engo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// AnimationSheet is a sprite sheet exported as JSON by Aseprite or TexturePacker (in the "hash" or "array" format).
+// Its frames are parts of a single image, which become the cells of the Spritesheet, and its animations come from the
+// frame tags of Aseprite, or the animations of TexturePacker. Rotated frames aren't supported, and trimmed frames
+// are drawn without the space that was trimmed.
+type AnimationSheet struct {
+	*Spritesheet
+	// FrameNames are the names of the frames, in the order of the cells of the Spritesheet
+	FrameNames []string
+	// Actions are all animations, in the order of the file
+	Actions []*AnimationAction
+}
+
+// Frame returns the index of the frame with the given name, or -1 when there's no such frame
+func (s *AnimationSheet) Frame(name string) int {
+	return indexOf(s.FrameNames, name)
+}
+
+// Action returns the animation with the given name, or nil when there's no such animation
+func (s *AnimationSheet) Action(name string) *AnimationAction {
+	for _, a := range s.Actions {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// AnimationComponent creates an AnimationComponent with all frames and animations of the sheet; rate is used for
+// frames without a duration
+func (s *AnimationSheet) AnimationComponent(rate float32) AnimationComponent {
+	ac := NewAnimationComponent(s.Drawables(), rate)
+	ac.AddAnimationActions(s.Actions)
+	return ac
+}
+
+// animationSheetData is a decoded sprite sheet, of which the image still has to be loaded
+type animationSheetData struct {
+	image   string
+	names   []string
+	regions []image.Rectangle
+	actions []*AnimationAction
+}
+
+// spriteSheetJSON is the JSON format of Aseprite and TexturePacker
+type spriteSheetJSON struct {
+	Frames     json.RawMessage     `json:"frames"` // an object of frames by name, or an array of frames
+	Animations map[string][]string `json:"animations"`
+	Meta       struct {
+		Image     string               `json:"image"`
+		FrameTags []spriteFrameTagJSON `json:"frameTags"`
+	} `json:"meta"`
+}
+
+type spriteFrameJSON struct {
+	Filename string `json:"filename"`
+	Frame    struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+		W int `json:"w"`
+		H int `json:"h"`
+	} `json:"frame"`
+	Rotated  bool `json:"rotated"`
+	Duration int  `json:"duration"` // in milliseconds
+}
+
+type spriteFrameTagJSON struct {
+	Name      string `json:"name"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Direction string `json:"direction"`
+	Repeat    string `json:"repeat"`
+}
+
+// isSpriteSheetJSON returns whether or not the JSON document is a sprite sheet exported by Aseprite or TexturePacker
+func isSpriteSheetJSON(data []byte) bool {
+	var doc struct {
+		Frames json.RawMessage `json:"frames"`
+		Meta   struct {
+			Image string `json:"image"`
+		} `json:"meta"`
+	}
+	return json.Unmarshal(data, &doc) == nil && len(doc.Frames) > 0 && doc.Meta.Image != ""
+}
+
+// decodeAnimationSheet decodes a sprite sheet exported by Aseprite or TexturePacker
+func decodeAnimationSheet(data []byte) (*animationSheetData, error) {
+	var sheet spriteSheetJSON
+	if err := json.Unmarshal(data, &sheet); err != nil {
+		return nil, err
+	}
+
+	frames, err := decodeSpriteFrames(sheet.Frames)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &animationSheetData{
+		image:   path.Base(sheet.Meta.Image),
+		names:   make([]string, len(frames)),
+		regions: make([]image.Rectangle, len(frames)),
+	}
+	durations := make([]float32, len(frames))
+	for i, f := range frames {
+		if f.Rotated {
+			return nil, fmt.Errorf("frame %q is rotated, which isn't supported", f.Filename)
+		}
+		d.names[i] = f.Filename
+		d.regions[i] = image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+f.Frame.W, f.Frame.Y+f.Frame.H)
+		durations[i] = float32(f.Duration) / 1000
+	}
+
+	for _, tag := range sheet.Meta.FrameTags {
+		action, err := tag.action(durations)
+		if err != nil {
+			return nil, err
+		}
+		d.actions = append(d.actions, action)
+	}
+
+	// The animations of TexturePacker are an object, so they're sorted by name to always get the same order
+	names := make([]string, 0, len(sheet.Animations))
+	for name := range sheet.Animations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		action := &AnimationAction{Name: name}
+		for _, frame := range sheet.Animations[name] {
+			index := indexOf(d.names, frame)
+			if index < 0 {
+				return nil, fmt.Errorf("animation %q: unknown frame %q", name, frame)
+			}
+			action.Frames = append(action.Frames, index)
+			action.Durations = append(action.Durations, durations[index])
+		}
+		d.actions = append(d.actions, action)
+	}
+
+	return d, nil
+}
+
+// decodeSpriteFrames decodes the frames of a sprite sheet, which are either an array, or an object of frames by
+// name. The order of the object is kept, because the frame tags of Aseprite refer to frames by their index.
+func decodeSpriteFrames(data json.RawMessage) ([]spriteFrameJSON, error) {
+	var frames []spriteFrameJSON
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err := json.Unmarshal(data, &frames)
+		return frames, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("frames should be an object or an array")
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		var f spriteFrameJSON
+		if err := dec.Decode(&f); err != nil {
+			return nil, err
+		}
+		f.Filename = t.(string)
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+// action converts the frame tag of Aseprite, where durations are those of all frames in seconds
+func (tag spriteFrameTagJSON) action(durations []float32) (*AnimationAction, error) {
+	if tag.From < 0 || tag.To >= len(durations) || tag.From > tag.To {
+		return nil, fmt.Errorf("frame tag %q: frames %d to %d don't exist", tag.Name, tag.From, tag.To)
+	}
+
+	action := &AnimationAction{Name: tag.Name}
+	for i := tag.From; i <= tag.To; i++ {
+		action.Frames = append(action.Frames, i)
+		action.Durations = append(action.Durations, durations[i])
+	}
+
+	switch tag.Direction {
+	case "", "forward":
+		action.Mode = AnimationForward
+	case "reverse":
+		action.Mode = AnimationReverse
+	case "pingpong":
+		action.Mode = AnimationPingPong
+	case "pingpong_reverse":
+		// Playing the frames backwards, and then forwards again
+		action.Mode = AnimationPingPong
+		for i, j := 0, len(action.Frames)-1; i < j; i, j = i+1, j-1 {
+			action.Frames[i], action.Frames[j] = action.Frames[j], action.Frames[i]
+			action.Durations[i], action.Durations[j] = action.Durations[j], action.Durations[i]
+		}
+	default:
+		return nil, fmt.Errorf("frame tag %q: unknown direction %q", tag.Name, tag.Direction)
+	}
+
+	if tag.Repeat != "" {
+		loops, err := strconv.Atoi(tag.Repeat)
+		if err != nil {
+			return nil, fmt.Errorf("frame tag %q: invalid repeat %q", tag.Name, tag.Repeat)
+		}
+		action.Loops = loops
+	}
+
+	return action, nil
+}
+
+// newAnimationSheet creates the AnimationSheet from a decoded sprite sheet, of which the image has been loaded
+func newAnimationSheet(l *Loader, d *animationSheetData) (*AnimationSheet, error) {
+	texture, err := l.TryImage(d.image)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AnimationSheet{
+		Spritesheet: NewSpritesheetFromRegions(texture, d.regions),
+		FrameNames:  d.names,
+		Actions:     d.actions,
+	}, nil
+}
+
+// indexOf returns the index of name in names, or -1 when it's not there
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,256 @@
+package engo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"engo.io/ecs"
+)
+
+// testAnimationComponent creates an AnimationComponent with a rate of 0.1 and n drawables, which are told apart by
+// their width
+func testAnimationComponent(n int, actions ...*AnimationAction) AnimationComponent {
+	drawables := make([]Drawable, n)
+	for i := range drawables {
+		drawables[i] = &Region{width: float32(i)}
+	}
+
+	ac := NewAnimationComponent(drawables, 0.1)
+	ac.AddAnimationActions(actions)
+	return ac
+}
+
+func TestAnimationModes(t *testing.T) {
+	tests := []struct {
+		action   *AnimationAction
+		expected []int // the frames shown after every call to NextFrame
+	}{
+		{&AnimationAction{Frames: []int{0, 1, 2}}, []int{1, 2, 0, 1, 2, 0}},
+		{&AnimationAction{Frames: []int{0, 1, 2}, Mode: AnimationReverse}, []int{1, 0, 2, 1, 0, 2}},
+		{&AnimationAction{Frames: []int{0, 1, 2}, Mode: AnimationPingPong}, []int{1, 2, 1, 0, 1, 2}},
+		{&AnimationAction{Frames: []int{0, 1, 2}, Loops: 2}, []int{1, 2, 0, 1, 2, 2}},
+		{&AnimationAction{Frames: []int{0, 1, 2}, Mode: AnimationReverse, Loops: 1}, []int{1, 0, 0, 0}},
+		{&AnimationAction{Frames: []int{0, 1, 2}, Mode: AnimationPingPong, Loops: 1}, []int{1, 2, 1, 0, 0}},
+	}
+
+	for i, test := range tests {
+		test.action.Name = "test"
+		ac := testAnimationComponent(3, test.action)
+		ac.SelectAnimationByName("test")
+
+		shown := make([]int, len(test.expected))
+		for j := range shown {
+			ac.NextFrame()
+			shown[j] = int(ac.Cell().Width())
+		}
+		if !reflect.DeepEqual(shown, test.expected) {
+			t.Errorf("Test %d: expected frames %v, got %v", i, test.expected, shown)
+		}
+		if finished := test.action.Loops > 0; ac.Finished() != finished {
+			t.Errorf("Test %d: expected finished to be %v", i, finished)
+		}
+	}
+}
+
+func TestAnimationDurations(t *testing.T) {
+	walk := &AnimationAction{Name: "walk", Frames: []int{0, 1, 2}, Durations: []float32{0.5, 0, 0.2}}
+	ac := testAnimationComponent(3, walk)
+	ac.SelectAnimationByAction(walk)
+
+	steps := []struct {
+		dt      float32
+		frame   int
+		changed bool
+	}{
+		{0.4, 0, true}, // the first frame is drawn as soon as the animation starts
+		{0.15, 1, true},
+		{0.1, 2, true}, // the second frame uses the Rate
+		{0.25, 0, true},
+		{0.9, 0, true}, // skipping ahead a whole loop, and part of the first frame again
+	}
+	for i, s := range steps {
+		if changed := ac.update(s.dt); changed != s.changed || ac.Frame() != s.frame {
+			t.Errorf("Step %d: expected frame %d (changed: %v), got %d (%v)", i, s.frame, s.changed, ac.Frame(),
+				changed)
+		}
+	}
+}
+
+func TestAnimationSelect(t *testing.T) {
+	walk := &AnimationAction{Name: "walk", Frames: []int{0, 1, 2}}
+	jump := &AnimationAction{Name: "jump", Frames: []int{3}}
+	ac := testAnimationComponent(4, walk, jump)
+
+	ac.SelectAnimationByAction(walk)
+	ac.NextFrame()
+	ac.NextFrame()
+
+	// Selecting the animation that's playing doesn't restart it
+	ac.SelectAnimationByName("walk")
+	if ac.Frame() != 2 {
+		t.Errorf("Expected to still be at frame 2, got %d", ac.Frame())
+	}
+
+	// Switching to a shorter animation starts at its first frame
+	ac.SelectAnimationByAction(jump)
+	if ac.Frame() != 0 || ac.Cell().Width() != 3 {
+		t.Errorf("Expected the first frame of the jump, got %d", ac.Frame())
+	}
+}
+
+func TestAnimationSystem(t *testing.T) {
+	headless = true
+
+	walk := &AnimationAction{Name: "walk", Frames: []int{2, 1}}
+	ac := testAnimationComponent(3, walk)
+	ac.SelectAnimationByAction(walk)
+
+	basic := ecs.NewBasic()
+	render := &RenderComponent{}
+	sys := &AnimationSystem{}
+	sys.Add(&basic, &ac, render)
+
+	// The first frame is drawn right away, and the next one once it's been shown for the Rate
+	sys.Update(0.05)
+	if render.Drawable() != ac.Drawables[2] {
+		t.Errorf("Expected the first frame to be drawn, got %v", render.Drawable())
+	}
+	sys.Update(0.05)
+	if render.Drawable() != ac.Drawables[1] {
+		t.Errorf("Expected the second frame to be drawn, got %v", render.Drawable())
+	}
+}
+
+func TestAnimationSystemSingleFrame(t *testing.T) {
+	headless = true
+
+	walk := &AnimationAction{Name: "walk", Frames: []int{0, 1}}
+	jump := &AnimationAction{Name: "jump", Frames: []int{2}}
+	ac := testAnimationComponent(3, walk, jump)
+	ac.SelectAnimationByAction(walk)
+
+	basic := ecs.NewBasic()
+	render := &RenderComponent{}
+	sys := &AnimationSystem{}
+	sys.Add(&basic, &ac, render)
+	sys.Update(0.15)
+
+	// A single frame never changes, but it's still drawn as soon as the animation is selected
+	ac.SelectAnimationByAction(jump)
+	sys.Update(0.01)
+	if render.Drawable() != ac.Drawables[2] {
+		t.Fatalf("Expected the jump to be drawn, got %v", render.Drawable())
+	}
+	sys.Update(1)
+	if render.Drawable() != ac.Drawables[2] {
+		t.Errorf("Expected the jump to stay, got %v", render.Drawable())
+	}
+}
+
+// loadTestAnimationSheet loads the JSON file as "sheet.json", next to a 16x16 "sheet.png"
+func loadTestAnimationSheet(t *testing.T, sheet string) (*AnimationSheet, error) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	l := NewLoader()
+	l.SetFileSystem(MemoryFileSystem{"sheet.png": testPNG(t, 16), "sheet.json": []byte(sheet)})
+	l.Add("sheet.png", "sheet.json")
+	if err := l.TryLoad(); err != nil {
+		return nil, err
+	}
+
+	return l.TryAnimationSheet("sheet.json")
+}
+
+func TestAsepriteAnimationSheet(t *testing.T) {
+	// Frames of the hash format are kept in order, even though they aren't sorted by name
+	sheet, err := loadTestAnimationSheet(t, `{
+ "frames": {
+  "hero 2.aseprite": {"frame": {"x": 8, "y": 8, "w": 8, "h": 8}, "rotated": false, "duration": 100},
+  "hero 0.aseprite": {"frame": {"x": 0, "y": 0, "w": 8, "h": 8}, "rotated": false, "duration": 100},
+  "hero 1.aseprite": {"frame": {"x": 8, "y": 0, "w": 8, "h": 16}, "rotated": false, "duration": 250}
+ },
+ "meta": {
+  "app": "http://www.aseprite.org/",
+  "image": "sheets/sheet.png",
+  "frameTags": [
+   {"name": "idle", "from": 0, "to": 0, "direction": "forward"},
+   {"name": "walk", "from": 1, "to": 2, "direction": "pingpong_reverse", "repeat": "3"}
+  ]
+ }
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"hero 2.aseprite", "hero 0.aseprite", "hero 1.aseprite"}
+	if !reflect.DeepEqual(sheet.FrameNames, names) || sheet.Frame("hero 1.aseprite") != 2 {
+		t.Errorf("Expected frames %v, got %v", names, sheet.FrameNames)
+	}
+	if sheet.CellCount() != 3 {
+		t.Fatalf("Expected 3 cells, got %d", sheet.CellCount())
+	}
+	if cell := sheet.Cell(2); cell.Width() != 8 || cell.Height() != 16 || cell.u != 0.5 || cell.v != 0 {
+		t.Errorf("Unexpected region for the third frame: %+v", cell)
+	}
+
+	walk := sheet.Action("walk")
+	expected := &AnimationAction{Name: "walk", Frames: []int{2, 1}, Durations: []float32{0.25, 0.1},
+		Mode: AnimationPingPong, Loops: 3}
+	if !reflect.DeepEqual(walk, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, walk)
+	}
+
+	ac := sheet.AnimationComponent(0.1)
+	ac.SelectAnimationByName("idle")
+	if ac.Cell() != sheet.Cell(0) {
+		t.Error("Expected the idle animation to show the first frame")
+	}
+}
+
+func TestTexturePackerAnimationSheet(t *testing.T) {
+	sheet, err := loadTestAnimationSheet(t, `{
+ "frames": [
+  {"filename": "run_0.png", "frame": {"x": 0, "y": 0, "w": 4, "h": 4}, "rotated": false, "trimmed": false},
+  {"filename": "run_1.png", "frame": {"x": 4, "y": 0, "w": 4, "h": 4}, "rotated": false, "trimmed": false},
+  {"filename": "jump.png", "frame": {"x": 0, "y": 4, "w": 8, "h": 8}, "rotated": false, "trimmed": false}
+ ],
+ "animations": {"run": ["run_0.png", "run_1.png"], "jump": ["jump.png"]},
+ "meta": {"app": "https://www.codeandweb.com/texturepacker", "image": "sheet.png"}
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Animations are sorted by name, and have no durations of their own
+	if len(sheet.Actions) != 2 || sheet.Actions[0].Name != "jump" || sheet.Actions[1].Name != "run" {
+		t.Fatalf("Unexpected animations: %v", sheet.Actions)
+	}
+	if run := sheet.Action("run"); !reflect.DeepEqual(run.Frames, []int{0, 1}) ||
+		!reflect.DeepEqual(run.Durations, []float32{0, 0}) {
+		t.Errorf("Unexpected run animation: %+v", run)
+	}
+	if cell := sheet.Cell(2); cell.Width() != 8 || cell.v != 0.25 {
+		t.Errorf("Unexpected region for the jump: %+v", cell)
+	}
+}
+
+func TestAnimationSheetErrors(t *testing.T) {
+	tests := map[string]string{
+		"rotated": `{"frames": [{"filename": "a", "frame": {"w": 4, "h": 4}, "rotated": true}],
+			"meta": {"image": "sheet.png"}}`,
+		"don't exist": `{"frames": [{"filename": "a", "frame": {"w": 4, "h": 4}}],
+			"meta": {"image": "sheet.png", "frameTags": [{"name": "b", "from": 0, "to": 1}]}}`,
+		"unknown direction": `{"frames": [{"filename": "a", "frame": {"w": 4, "h": 4}}],
+			"meta": {"image": "sheet.png", "frameTags": [{"name": "b", "from": 0, "to": 0, "direction": "up"}]}}`,
+		"unknown frame": `{"frames": [{"filename": "a", "frame": {"w": 4, "h": 4}}],
+			"animations": {"b": ["c"]}, "meta": {"image": "sheet.png"}}`,
+	}
+
+	for expected, sheet := range tests {
+		if _, err := loadTestAnimationSheet(t, sheet); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error about %q, got %v", expected, err)
+		}
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"io/ioutil"
 	"log"
-	"os"
 	"path"
+	"sort"
+	"strings"
+	"time"
 
 	"engo.io/gl"
 	"github.com/golang/freetype/truetype"
@@ -20,65 +21,157 @@ type Resource struct {
 	url  string
 }
 
+// Kind returns the extension of the resource, without the dot
+func (r Resource) Kind() string {
+	return r.kind
+}
+
+// Name returns the name of the resource, by which it can be retrieved from the Loader
+func (r Resource) Name() string {
+	return r.name
+}
+
+// URL returns the location of the resource in the FileSystem
+func (r Resource) URL() string {
+	return r.url
+}
+
 type Loader struct {
-	resources []Resource
-	images    map[string]*Texture
-	jsons     map[string]string
-	levels    map[string]*Level
-	sounds    map[string]string
-	fonts     map[string]*truetype.Font
+	fs         FileSystem
+	resources  []Resource
+	images     map[string]*Texture
+	jsons      map[string]string
+	levels     map[string]*Level
+	animations map[string]*AnimationSheet
+	sounds     map[string]string
+	fonts      map[string]*truetype.Font
+
+	loaders map[string]FileLoader // by extension
+
+	atlas      *AtlasOptions
+	atlasPages []*Texture
+	packing    map[string]Image // the images waiting to be packed into the atlas
+
+	loading  *loadState // the state of LoadAsync, or nil when it's not loading
+	progress LoadProgress
+	invalid  []ResourceError          // the URLs passed to Add which can't be loaded, reported by the next Load
+	failed   map[string]ResourceError // the resources that failed to load, by name
+
+	owner  string                     // the Type of the Scene that's adding resources, or "" outside of scenes
+	owners map[string]map[string]bool // the owners of every resource, by name
+
+	watchInterval time.Duration
+	lastCheck     time.Time
+	stamps        map[string]fileStamp // the state of the files of all loaded resources, by name
 }
 
 func NewLoader() *Loader {
-	return &Loader{
-		resources: make([]Resource, 1),
-		images:    make(map[string]*Texture),
-		jsons:     make(map[string]string),
-		levels:    make(map[string]*Level),
-		sounds:    make(map[string]string),
-		fonts:     make(map[string]*truetype.Font),
+	l := &Loader{
+		fs:         OSFileSystem{},
+		resources:  make([]Resource, 1),
+		images:     make(map[string]*Texture),
+		jsons:      make(map[string]string),
+		levels:     make(map[string]*Level),
+		animations: make(map[string]*AnimationSheet),
+		sounds:     make(map[string]string),
+		fonts:      make(map[string]*truetype.Font),
+		loaders:    make(map[string]FileLoader),
+		packing:    make(map[string]Image),
+		failed:     make(map[string]ResourceError),
+		owners:     make(map[string]map[string]bool),
 	}
+	l.registerDefaults()
+
+	return l
 }
 
+// NewResource creates a Resource from the URL, or an empty Resource when the URL has no extension
 func NewResource(url string) Resource {
+	r, err := ParseResource(url)
+	if err != nil {
+		log.Println("WARNING:", err)
+	}
+	return r
+}
+
+// ParseResource creates a Resource from the URL; the extension of the URL determines the kind of the Resource
+func ParseResource(url string) (Resource, error) {
 	kind := path.Ext(url)
 	name := path.Base(url)
 
 	if len(kind) == 0 {
-		log.Println("WARNING: Cannot laod extensionless resource.")
-		return Resource{}
+		return Resource{}, ResourceError{URL: url, Err: ErrNoExtension}
 	}
 
-	return Resource{name: name, url: url, kind: kind[1:]}
+	return Resource{name: name, url: url, kind: kind[1:]}, nil
 }
 
-func (l *Loader) AddFromDir(url string, recurse bool) {
-	files, err := ioutil.ReadDir(url)
+// AddFromDir adds all files in the directory (and its subdirectories, if recurse is true) which have an extension
+func (l *Loader) AddFromDir(url string, recurse bool) error {
+	files, err := l.fs.ReadDir(url)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	for _, f := range files {
 		furl := url + "/" + f.Name()
 		if !f.IsDir() {
-			Files.Add(furl)
+			if path.Ext(furl) != "" {
+				l.Add(furl)
+			}
 		} else if recurse {
-			Files.AddFromDir(furl, recurse)
+			if err := l.AddFromDir(furl, recurse); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
 
+// Add adds the URLs to the resources that should be loaded. URLs which can't be loaded, because they don't have an
+// extension, are reported as errors by the next Load.
 func (l *Loader) Add(urls ...string) {
 	for _, u := range urls {
-		r := NewResource(u)
+		r, err := ParseResource(u)
+		if err != nil {
+			l.invalid = append(l.invalid, err.(ResourceError))
+			continue // with other urls
+		}
 		l.resources = append(l.resources, r)
 		log.Println(r)
+
+		if l.owners[r.name] == nil {
+			l.owners[r.name] = make(map[string]bool)
+		}
+		l.owners[r.name][l.owner] = true
 	}
 }
 
+// UseAtlas makes the Loader pack all images it loads into atlas pages, so entities using different images can
+// still be batched together. Image keeps returning a *Texture, which only covers its own part of the page. Images
+// which are too large for a page get a texture of their own.
+func (l *Loader) UseAtlas(options AtlasOptions) {
+	l.atlas = &options
+}
+
 func (l *Loader) Image(name string) *Texture {
 	return l.images[name]
 }
 
+// AtlasPages returns the textures of all atlas pages that are in use
+func (l *Loader) AtlasPages() []*Texture {
+	return l.atlasPages
+}
+
+// removeAtlasPage forgets the atlas page; it's called when the page is closed
+func (l *Loader) removeAtlasPage(page *Texture) {
+	for i, p := range l.atlasPages {
+		if p == page {
+			l.atlasPages = append(l.atlasPages[:i], l.atlasPages[i+1:]...)
+			return
+		}
+	}
+}
+
 func (l *Loader) Json(name string) string {
 	return l.jsons[name]
 }
@@ -87,82 +180,199 @@ func (l *Loader) Level(name string) *Level {
 	return l.levels[name]
 }
 
+// AnimationSheet returns the sprite sheet of a JSON file exported by Aseprite or TexturePacker
+func (l *Loader) AnimationSheet(name string) *AnimationSheet {
+	return l.animations[name]
+}
+
 func (l *Loader) Sound(name string) ReadSeekCloser {
-	f, err := os.Open(l.sounds[name])
+	f, _ := l.TrySound(name)
+	return f
+}
+
+// TryImage returns the texture of the image, or an error when it isn't loaded
+func (l *Loader) TryImage(name string) (*Texture, error) {
+	if img, ok := l.images[name]; ok {
+		return img, nil
+	}
+	return nil, l.notLoaded(name)
+}
+
+// TryJson returns the contents of the JSON file, or an error when it isn't loaded
+func (l *Loader) TryJson(name string) (string, error) {
+	if data, ok := l.jsons[name]; ok {
+		return data, nil
+	}
+	return "", l.notLoaded(name)
+}
+
+// TryLevel returns the Level, or an error when it isn't loaded
+func (l *Loader) TryLevel(name string) (*Level, error) {
+	if lvl, ok := l.levels[name]; ok {
+		return lvl, nil
+	}
+	return nil, l.notLoaded(name)
+}
+
+// TryAnimationSheet returns the AnimationSheet, or an error when it isn't loaded
+func (l *Loader) TryAnimationSheet(name string) (*AnimationSheet, error) {
+	if sheet, ok := l.animations[name]; ok {
+		return sheet, nil
+	}
+	return nil, l.notLoaded(name)
+}
+
+// TrySound opens the sound file, or returns an error when it isn't loaded or can't be opened
+func (l *Loader) TrySound(name string) (ReadSeekCloser, error) {
+	url, ok := l.sounds[name]
+	if !ok {
+		return nil, l.notLoaded(name)
+	}
+
+	f, err := l.fs.Open(url)
 	if err != nil {
-		return nil
+		return nil, ResourceError{"wav", url, err}
 	}
-	return f
+	return f, nil
 }
 
-func (l *Loader) Load(onFinish func()) {
+// TryFont returns the font, or an error when it isn't loaded
+func (l *Loader) TryFont(name string) (*truetype.Font, error) {
+	if f, ok := l.fonts[name]; ok {
+		return f, nil
+	}
+	return nil, l.notLoaded(name)
+}
+
+// notLoaded returns the reason the resource isn't loaded
+func (l *Loader) notLoaded(name string) error {
+	if err, ok := l.failed[name]; ok {
+		return err
+	}
+
 	for _, r := range l.resources {
-		switch r.kind {
-		case "png":
-			if _, ok := l.images[r.name]; ok {
-				continue // with other resources
-			}
+		if r.name == name {
+			return ResourceError{r.kind, r.url, ErrNotLoaded}
+		}
+	}
 
-			data, err := loadImage(r)
-			if err != nil {
-				log.Println("Error loading resource:", err)
-				continue // with other resources
-			}
+	return ResourceError{strings.TrimPrefix(path.Ext(name), "."), name, ErrNotLoaded}
+}
 
-			l.images[r.name] = NewTexture(data)
-		case "jpg":
-			if _, ok := l.images[r.name]; ok {
-				continue // with other resources
-			}
+// Load loads all resources which haven't been loaded yet, and calls onFinish when done. It blocks until everything
+// has been loaded; use LoadAsync to load in the background instead.
+func (l *Loader) Load(onFinish func()) {
+	l.wait()
 
-			data, err := loadImage(r)
-			if err != nil {
-				log.Println("Error loading resource:", err)
-				continue // with other resources
-			}
+	state := l.newLoadState(onFinish)
+	for i, r := range state.resources {
+		data, err := l.decode(r)
+		l.handle(state, loadJob{i, r, data, err})
+	}
+	l.finish(state)
+}
 
-			l.images[r.name] = NewTexture(data)
-		case "json":
-			if _, ok := l.jsons[r.name]; ok {
-				continue // with other resources
-			}
+// TryLoad loads all resources which haven't been loaded yet, like Load, but returns a LoadErrors listing every
+// resource that failed to load, instead of only logging them
+func (l *Loader) TryLoad() error {
+	l.Load(nil)
+	return l.progress.Err()
+}
 
-			data, err := loadJSON(r)
-			if err != nil {
-				log.Println("Error loading resource:", err)
-				continue // with other resources
-			}
+// skip returns whether or not the resource was loaded before, or is of a kind the Loader doesn't know about
+func (l *Loader) skip(r Resource) bool {
+	loader, ok := l.loaders[r.kind]
+	if !ok {
+		return true
+	}
 
-			l.jsons[r.name] = data
-		case "tmx":
-			if _, ok := l.levels[r.name]; ok {
-				continue // with other resources
-			}
+	_, loaded := loader.Resource(r.name)
+	return loaded
+}
 
-			data, err := createLevelFromTmx(r)
-			if err != nil {
-				log.Println("Error loading resource:", err)
-				continue // with other resources
-			}
+// decode reads and decodes the resource, without creating any textures. It can be called from any goroutine.
+func (l *Loader) decode(r Resource) (interface{}, error) {
+	return l.loaders[r.kind].Decode(l.fs, r)
+}
 
-			l.levels[r.name] = data
-		case "wav":
-			l.sounds[r.name] = r.url
-		case "ttf":
-			if _, ok := l.fonts[r.name]; ok {
-				continue // with other resources
-			}
+// handle stores the decoded resource. It has to be called on the render thread, because it creates textures.
+func (l *Loader) handle(state *loadState, job loadJob) {
+	state.remaining--
 
-			f, err := loadFont(r)
-			if err != nil {
-				log.Println("Error loading resource:", err)
-				continue // with other resources
-			}
+	if job.err != nil {
+		l.report(job, job.err)
+		return
+	}
+
+	loader := l.loaders[job.resource.kind]
+	if deferred, ok := loader.(DeferredFileLoader); ok && deferred.Deferred() {
+		state.deferred = append(state.deferred, job)
+		return
+	}
+
+	l.report(job, loader.Load(job.resource, job.data))
+}
+
+// finish packs the atlas, creates the levels and calls onFinish, once all resources have been handled
+func (l *Loader) finish(state *loadState) {
+	if len(l.packing) > 0 {
+		l.packAtlas(l.packing)
+		l.packing = make(map[string]Image)
+	}
+
+	sort.Sort(loadJobs(state.deferred))
+	for _, job := range state.deferred {
+		l.report(job, l.loaders[job.resource.kind].Load(job.resource, job.data))
+	}
+
+	l.progress.Done = true
+	if Mailbox != nil {
+		Mailbox.Dispatch(LoadProgressMessage{l.progress})
+	}
+
+	if state.onFinish != nil {
+		state.onFinish()
+	}
+}
+
+// packable returns whether or not the image should be packed into an atlas
+func (l *Loader) packable(img Image) bool {
+	if l.atlas == nil {
+		return false
+	}
+
+	_, ok := img.Data().(*image.NRGBA)
+	return ok && l.atlas.fits(img.Width(), img.Height())
+}
 
-			l.fonts[r.name] = f
+// packAtlas packs the images into new atlas pages, and stores a Texture for each of them
+func (l *Loader) packAtlas(images map[string]Image) {
+	nrgbas := make(map[string]*image.NRGBA, len(images))
+	for name, img := range images {
+		nrgbas[name] = img.Data().(*image.NRGBA)
+	}
+
+	atlas, err := PackAtlas(nrgbas, *l.atlas)
+	if err != nil {
+		// The images were reported as loaded already, so they get a texture of their own instead
+		log.Println("Error packing atlas, not using it for these images:", err)
+		for name, img := range images {
+			l.images[name] = NewTexture(img)
 		}
+		return
+	}
+
+	pages := make([]*Texture, len(atlas.Pages))
+	for i, page := range atlas.Pages {
+		pages[i] = NewTexture(&ImageObject{page})
+		pages[i].loader = l
+	}
+	l.atlasPages = append(l.atlasPages, pages...)
+
+	for name, entry := range atlas.Entries {
+		l.images[name] = newAtlasTexture(pages[entry.Page], entry.Bounds)
+		pages[entry.Page].users++
 	}
-	onFinish()
 }
 
 type Image interface {
@@ -197,14 +407,16 @@ type Region struct {
 	width, height float32
 }
 
+// NewRegion creates a Region from the part of the texture at (x, y) of the given size. When the texture was packed
+// into an atlas, the coordinates are still relative to the texture itself.
 func NewRegion(texture *Texture, x, y, w, h float32) *Region {
-	invTexWidth := 1.0 / texture.Width()
-	invTexHeight := 1.0 / texture.Height()
+	invTexWidth := (texture.u2 - texture.u) / texture.Width()
+	invTexHeight := (texture.v2 - texture.v) / texture.Height()
 
-	u := x * invTexWidth
-	v := y * invTexHeight
-	u2 := (x + w) * invTexWidth
-	v2 := (y + h) * invTexHeight
+	u := texture.u + x*invTexWidth
+	v := texture.v + y*invTexHeight
+	u2 := texture.u + (x+w)*invTexWidth
+	v2 := texture.v + (y+h)*invTexHeight
 
 	width := math.Abs(w)
 	height := math.Abs(h)
@@ -228,10 +440,35 @@ func (r *Region) View() (float32, float32, float32, float32) {
 	return r.u, r.v, r.u2, r.v2
 }
 
+// Flipped returns a copy of the Region which is mirrored horizontally and/or vertically; it returns the Region itself
+// when it isn't flipped at all
+func (r *Region) Flipped(horizontally, vertically bool) *Region {
+	if !horizontally && !vertically {
+		return r
+	}
+
+	flipped := *r
+	if horizontally {
+		flipped.u, flipped.u2 = r.u2, r.u
+	}
+	if vertically {
+		flipped.v, flipped.v2 = r.v2, r.v
+	}
+	return &flipped
+}
+
 type Texture struct {
 	id     *gl.Texture
 	width  float32
 	height float32
+
+	// u, v, u2 and v2 are the part of the OpenGL texture which is used; it's only a part of it when the texture was
+	// packed into an atlas
+	u, v, u2, v2 float32
+
+	page   *Texture // the atlas page this texture is a part of, if any
+	users  int      // the number of textures using this atlas page
+	loader *Loader  // the Loader which lists this atlas page in AtlasPages
 }
 
 func NewTexture(img Image) *Texture {
@@ -253,7 +490,41 @@ func NewTexture(img Image) *Texture {
 		Gl.TexImage2D(Gl.TEXTURE_2D, 0, Gl.RGBA, Gl.RGBA, Gl.UNSIGNED_BYTE, img.Data())
 	}
 
-	return &Texture{id, float32(img.Width()), float32(img.Height())}
+	return &Texture{id: id, width: float32(img.Width()), height: float32(img.Height()), u2: 1, v2: 1}
+}
+
+// newAtlasTexture creates a Texture from the part of the page at bounds
+func newAtlasTexture(page *Texture, bounds image.Rectangle) *Texture {
+	return &Texture{
+		id:     page.id,
+		width:  float32(bounds.Dx()),
+		height: float32(bounds.Dy()),
+		u:      float32(bounds.Min.X) / page.width,
+		v:      float32(bounds.Min.Y) / page.height,
+		u2:     float32(bounds.Max.X) / page.width,
+		v2:     float32(bounds.Max.Y) / page.height,
+		page:   page,
+	}
+}
+
+// Close frees the OpenGL texture; the Texture can't be drawn anymore afterwards. A texture which is part of an
+// atlas page only frees the page once all other textures on it are closed as well.
+func (t *Texture) Close() {
+	if t.page != nil {
+		t.page.users--
+		if t.page.users == 0 {
+			t.page.Close()
+		}
+		t.page = nil
+	} else if t.id != nil && !headless {
+		Gl.DeleteTexture(t.id)
+	}
+
+	if t.loader != nil {
+		t.loader.removeAtlasPage(t)
+		t.loader = nil
+	}
+	t.id = nil
 }
 
 // Width returns the width of the texture.
@@ -271,7 +542,7 @@ func (t *Texture) Texture() *gl.Texture {
 }
 
 func (r *Texture) View() (float32, float32, float32, float32) {
-	return 0.0, 0.0, 1.0, 1.0
+	return r.u, r.v, r.u2, r.v2
 }
 
 type Sprite struct {
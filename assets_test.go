@@ -1,6 +1,12 @@
 package engo
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestNewResourceWithoutExtension(t *testing.T) {
 	url := "pineapple"
@@ -10,3 +16,87 @@ func TestNewResourceWithoutExtension(t *testing.T) {
 		t.Error("Expected empty resource.")
 	}
 }
+
+func TestParseResource(t *testing.T) {
+	r, err := ParseResource("assets/icon.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != (Resource{kind: "png", name: "icon.png", url: "assets/icon.png"}) {
+		t.Errorf("Unexpected resource: %+v", r)
+	}
+
+	if _, err := ParseResource("pineapple"); err == nil || err.(ResourceError).Err != ErrNoExtension {
+		t.Errorf("Expected ErrNoExtension, got %v", err)
+	}
+}
+
+func TestLoaderTryLoad(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	dir := writeTestAssets(t, map[string]int{"a.png": 4})
+	defer os.RemoveAll(dir)
+
+	l := NewLoader()
+	l.Add(filepath.Join(dir, "a.png"), filepath.Join(dir, "missing.png"), filepath.Join(dir, "missing.tmx"), "pineapple")
+
+	err := l.TryLoad()
+	errs, ok := err.(LoadErrors)
+	if !ok {
+		t.Fatalf("Expected LoadErrors, got %v", err)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, got %d: %v", len(errs), errs)
+	}
+
+	expected := []struct{ kind, url string }{
+		{"", "pineapple"},
+		{"png", filepath.Join(dir, "missing.png")},
+		{"tmx", filepath.Join(dir, "missing.tmx")},
+	}
+	for i, e := range expected {
+		if errs[i].Kind != e.kind || errs[i].URL != e.url {
+			t.Errorf("Error %d: expected %s resource %q, got %s resource %q", i, e.kind, e.url, errs[i].Kind, errs[i].URL)
+		}
+		if !strings.Contains(err.Error(), e.url) {
+			t.Errorf("Expected the error message to contain %q", e.url)
+		}
+	}
+
+	if _, err := l.TryImage("a.png"); err != nil {
+		t.Errorf("Expected a.png to be loaded, got %v", err)
+	}
+	if _, err := l.TryImage("missing.png"); err == nil || err.(ResourceError).URL != filepath.Join(dir, "missing.png") {
+		t.Errorf("Expected the load error of missing.png, got %v", err)
+	}
+	if _, err := l.TryLevel("unknown.tmx"); err == nil || err.(ResourceError).Err != ErrNotLoaded {
+		t.Errorf("Expected ErrNotLoaded, got %v", err)
+	}
+
+	// Resources that failed are tried again, but invalid URLs are only reported once
+	if err := l.TryLoad(); err == nil || len(err.(LoadErrors)) != 2 {
+		t.Errorf("Expected the missing files to fail again, got %v", err)
+	}
+}
+
+func TestLoaderAddFromDir(t *testing.T) {
+	dir := writeTestAssets(t, map[string]int{"a.png": 4})
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "LICENSE"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLoader()
+	if err := l.AddFromDir(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.invalid) != 0 {
+		t.Errorf("Expected files without an extension to be skipped, got %v", l.invalid)
+	}
+
+	if err := l.AddFromDir(filepath.Join(dir, "missing"), false); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
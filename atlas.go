@@ -0,0 +1,217 @@
+package engo
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+)
+
+// DefaultAtlasSize is the width and height of an atlas page, when no size was given
+const DefaultAtlasSize = 2048
+
+// AtlasOptions configures how images are packed into atlas pages
+type AtlasOptions struct {
+	// Width and Height are the maximum dimensions of a page; they default to DefaultAtlasSize
+	Width, Height int
+	// Padding is the number of transparent pixels between images, which keeps them from bleeding into each other
+	Padding int
+}
+
+func (o AtlasOptions) size() (int, int) {
+	width, height := o.Width, o.Height
+	if width <= 0 {
+		width = DefaultAtlasSize
+	}
+	if height <= 0 {
+		height = DefaultAtlasSize
+	}
+	return width, height
+}
+
+// fits returns whether or not an image of the given size fits on a single page
+func (o AtlasOptions) fits(width, height int) bool {
+	pageWidth, pageHeight := o.size()
+	return width+o.Padding <= pageWidth && height+o.Padding <= pageHeight
+}
+
+// AtlasEntry is the location of a packed image
+type AtlasEntry struct {
+	// Page is the index of the page the image was packed into
+	Page int
+	// Bounds is the location of the image on the page
+	Bounds image.Rectangle
+}
+
+// Atlas is the result of packing images into pages
+type Atlas struct {
+	Pages   []*image.NRGBA
+	Entries map[string]AtlasEntry
+}
+
+// PackAtlas packs the images into as few pages as possible, using the max-rects algorithm. The result only depends
+// on the names and sizes of the images, so the same images always end up at the same location. Pages are cropped
+// to the area that is actually used.
+func PackAtlas(images map[string]*image.NRGBA, options AtlasOptions) (*Atlas, error) {
+	pageWidth, pageHeight := options.size()
+
+	// Packing the largest images first gives the best results
+	names := make([]string, 0, len(images))
+	for name, img := range images {
+		if !options.fits(img.Bounds().Dx(), img.Bounds().Dy()) {
+			return nil, fmt.Errorf("image %q (%dx%d) doesn't fit on an atlas page of %dx%d", name,
+				img.Bounds().Dx(), img.Bounds().Dy(), pageWidth, pageHeight)
+		}
+		names = append(names, name)
+	}
+	sort.Sort(atlasOrder{names, images})
+
+	var pages []*maxRectsPage
+	entries := make(map[string]AtlasEntry, len(names))
+	for _, name := range names {
+		bounds := images[name].Bounds()
+		width, height := bounds.Dx()+options.Padding, bounds.Dy()+options.Padding
+
+		entry := AtlasEntry{Page: -1}
+		for i, page := range pages {
+			if placed, ok := page.insert(width, height); ok {
+				entry = AtlasEntry{Page: i, Bounds: placed}
+				break
+			}
+		}
+		if entry.Page < 0 {
+			page := newMaxRectsPage(pageWidth, pageHeight)
+			placed, _ := page.insert(width, height)
+			pages = append(pages, page)
+			entry = AtlasEntry{Page: len(pages) - 1, Bounds: placed}
+		}
+
+		// The padding is only used to keep images apart; it's not part of the image itself
+		entry.Bounds.Max = entry.Bounds.Min.Add(bounds.Size())
+		entries[name] = entry
+	}
+
+	atlas := &Atlas{Pages: make([]*image.NRGBA, len(pages)), Entries: entries}
+	for i, page := range pages {
+		atlas.Pages[i] = image.NewNRGBA(image.Rect(0, 0, page.used.X, page.used.Y))
+	}
+	for name, entry := range entries {
+		img := images[name]
+		draw.Draw(atlas.Pages[entry.Page], entry.Bounds, img, img.Bounds().Min, draw.Src)
+	}
+
+	return atlas, nil
+}
+
+// atlasOrder sorts the names of images by height and width (largest first), and then by name
+type atlasOrder struct {
+	names  []string
+	images map[string]*image.NRGBA
+}
+
+func (o atlasOrder) Len() int {
+	return len(o.names)
+}
+
+func (o atlasOrder) Less(i, j int) bool {
+	a, b := o.images[o.names[i]].Bounds(), o.images[o.names[j]].Bounds()
+	if a.Dy() != b.Dy() {
+		return a.Dy() > b.Dy()
+	}
+	if a.Dx() != b.Dx() {
+		return a.Dx() > b.Dx()
+	}
+	return o.names[i] < o.names[j]
+}
+
+func (o atlasOrder) Swap(i, j int) {
+	o.names[i], o.names[j] = o.names[j], o.names[i]
+}
+
+// maxRectsPage keeps track of all maximal free rectangles of a single page
+type maxRectsPage struct {
+	free []image.Rectangle
+	used image.Point // the bottom-right corner of the area that's in use
+}
+
+func newMaxRectsPage(width, height int) *maxRectsPage {
+	return &maxRectsPage{free: []image.Rectangle{image.Rect(0, 0, width, height)}}
+}
+
+// insert places a rectangle of the given size in the free rectangle that fits it best, using the "best short side
+// fit" heuristic
+func (p *maxRectsPage) insert(width, height int) (image.Rectangle, bool) {
+	best := -1
+	bestShort, bestLong := 0, 0
+	for i, f := range p.free {
+		if width > f.Dx() || height > f.Dy() {
+			continue // with other free rectangles
+		}
+
+		short, long := f.Dx()-width, f.Dy()-height
+		if short > long {
+			short, long = long, short
+		}
+		if best < 0 || short < bestShort || (short == bestShort && long < bestLong) {
+			best, bestShort, bestLong = i, short, long
+		}
+	}
+	if best < 0 {
+		return image.Rectangle{}, false
+	}
+
+	placed := image.Rect(p.free[best].Min.X, p.free[best].Min.Y, p.free[best].Min.X+width, p.free[best].Min.Y+height)
+	p.split(placed)
+	p.prune()
+
+	if placed.Max.X > p.used.X {
+		p.used.X = placed.Max.X
+	}
+	if placed.Max.Y > p.used.Y {
+		p.used.Y = placed.Max.Y
+	}
+
+	return placed, true
+}
+
+// split replaces every free rectangle overlapping with used by the (up to four) maximal rectangles around it
+func (p *maxRectsPage) split(used image.Rectangle) {
+	free := make([]image.Rectangle, 0, len(p.free)+4)
+	for _, f := range p.free {
+		if !f.Overlaps(used) {
+			free = append(free, f)
+			continue // with other free rectangles
+		}
+
+		if used.Min.X > f.Min.X {
+			free = append(free, image.Rect(f.Min.X, f.Min.Y, used.Min.X, f.Max.Y))
+		}
+		if used.Max.X < f.Max.X {
+			free = append(free, image.Rect(used.Max.X, f.Min.Y, f.Max.X, f.Max.Y))
+		}
+		if used.Min.Y > f.Min.Y {
+			free = append(free, image.Rect(f.Min.X, f.Min.Y, f.Max.X, used.Min.Y))
+		}
+		if used.Max.Y < f.Max.Y {
+			free = append(free, image.Rect(f.Min.X, used.Max.Y, f.Max.X, f.Max.Y))
+		}
+	}
+	p.free = free
+}
+
+// prune removes the free rectangles that are contained in another one
+func (p *maxRectsPage) prune() {
+	for i := 0; i < len(p.free); i++ {
+		for j := i + 1; j < len(p.free); j++ {
+			if p.free[i].In(p.free[j]) {
+				p.free = append(p.free[:i], p.free[i+1:]...)
+				i--
+				break
+			}
+			if p.free[j].In(p.free[i]) {
+				p.free = append(p.free[:j], p.free[j+1:]...)
+				j--
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package engo
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// atlasTestImages creates images of various sizes, each filled with a color unique to the image
+func atlasTestImages(count int) map[string]*image.NRGBA {
+	images := make(map[string]*image.NRGBA, count)
+	for i := 0; i < count; i++ {
+		img := image.NewNRGBA(image.Rect(0, 0, 8+(i*7)%40, 8+(i*13)%30))
+		c := color.NRGBA{uint8(i), uint8(i * 3), 255, 255}
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			for x := 0; x < img.Bounds().Dx(); x++ {
+				img.SetNRGBA(x, y, c)
+			}
+		}
+		images[fmt.Sprintf("image%d.png", i)] = img
+	}
+	return images
+}
+
+func TestPackAtlas(t *testing.T) {
+	images := atlasTestImages(50)
+
+	atlas, err := PackAtlas(images, AtlasOptions{Width: 256, Height: 256, Padding: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(atlas.Entries) != len(images) {
+		t.Fatalf("Expected %d entries, got %d", len(images), len(atlas.Entries))
+	}
+
+	for name, entry := range atlas.Entries {
+		img := images[name]
+		page := atlas.Pages[entry.Page]
+
+		if entry.Bounds.Size() != img.Bounds().Size() {
+			t.Errorf("%s: expected size %v, got %v", name, img.Bounds().Size(), entry.Bounds.Size())
+		}
+		if !entry.Bounds.In(page.Bounds()) {
+			t.Errorf("%s: %v is outside of page %v", name, entry.Bounds, page.Bounds())
+		}
+		if page.Bounds().Dx() > 256 || page.Bounds().Dy() > 256 {
+			t.Errorf("Page %d is larger than the maximum: %v", entry.Page, page.Bounds())
+		}
+
+		for other, otherEntry := range atlas.Entries {
+			if other == name || otherEntry.Page != entry.Page {
+				continue
+			}
+			// Including the padding, the images may not even touch
+			padded := entry.Bounds
+			padded.Max = padded.Max.Add(image.Point{1, 1})
+			if padded.Overlaps(otherEntry.Bounds) {
+				t.Errorf("%s at %v overlaps with %s at %v", name, entry.Bounds, other, otherEntry.Bounds)
+			}
+		}
+
+		if page.NRGBAAt(entry.Bounds.Min.X, entry.Bounds.Min.Y) != img.NRGBAAt(0, 0) ||
+			page.NRGBAAt(entry.Bounds.Max.X-1, entry.Bounds.Max.Y-1) != img.NRGBAAt(0, 0) {
+			t.Errorf("%s: pixels weren't copied to the page", name)
+		}
+	}
+}
+
+func TestPackAtlasMultiplePages(t *testing.T) {
+	images := make(map[string]*image.NRGBA)
+	for i := 0; i < 5; i++ {
+		images[fmt.Sprintf("%d", i)] = image.NewNRGBA(image.Rect(0, 0, 60, 60))
+	}
+
+	atlas, err := PackAtlas(images, AtlasOptions{Width: 128, Height: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(atlas.Pages) != 2 {
+		t.Fatalf("Expected 2 pages, got %d", len(atlas.Pages))
+	}
+	if size := atlas.Pages[1].Bounds().Size(); size != (image.Point{60, 60}) {
+		t.Errorf("Expected the last page to be cropped to 60x60, got %v", size)
+	}
+}
+
+func TestPackAtlasDeterministic(t *testing.T) {
+	first, err := PackAtlas(atlasTestImages(30), AtlasOptions{Width: 128, Height: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		atlas, err := PackAtlas(atlasTestImages(30), AtlasOptions{Width: 128, Height: 128})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, entry := range first.Entries {
+			if atlas.Entries[name] != entry {
+				t.Errorf("%s: expected %v, got %v", name, entry, atlas.Entries[name])
+			}
+		}
+	}
+}
+
+func TestPackAtlasTooLarge(t *testing.T) {
+	images := map[string]*image.NRGBA{"huge.png": image.NewNRGBA(image.Rect(0, 0, 300, 10))}
+
+	if _, err := PackAtlas(images, AtlasOptions{Width: 256, Height: 256}); err == nil {
+		t.Error("Expected an error for an image larger than a page")
+	}
+}
+
+func TestAtlasTextureRegion(t *testing.T) {
+	page := &Texture{width: 200, height: 100, u2: 1, v2: 1}
+	texture := newAtlasTexture(page, image.Rect(100, 50, 140, 70))
+
+	if texture.Width() != 40 || texture.Height() != 20 {
+		t.Errorf("Expected a 40x20 texture, got %vx%v", texture.Width(), texture.Height())
+	}
+
+	u, v, u2, v2 := texture.View()
+	if u != 0.5 || v != 0.5 || u2 != 0.7 || v2 != 0.7 {
+		t.Errorf("Unexpected view of the texture: %v %v %v %v", u, v, u2, v2)
+	}
+
+	// A region of the texture should be relative to the texture, not the page
+	region := NewRegion(texture, 20, 10, 20, 10)
+	u, v, u2, v2 = region.View()
+	if u != 0.6 || v != 0.6 || u2 != 0.7 || v2 != 0.7 {
+		t.Errorf("Unexpected view of the region: %v %v %v %v", u, v, u2, v2)
+	}
+}
@@ -0,0 +1,88 @@
+package engo
+
+import (
+	"engo.io/gl"
+	"github.com/luxengine/math"
+)
+
+// vertexSize is the number of floats per vertex: the position (x, y), texture coordinates (u, v) and tint
+const vertexSize = 5
+
+// quadSize is the number of floats per quad, which consists of 4 vertices
+const quadSize = 4 * vertexSize
+
+// RenderStats contains the amount of work done to render a single frame
+type RenderStats struct {
+	// DrawCalls is the number of times OpenGL was asked to draw something
+	DrawCalls int
+	// Vertices is the number of vertices that were drawn
+	Vertices int
+}
+
+// renderStats is updated by the shaders, and reset by the RenderSystem at the start of every frame
+var renderStats RenderStats
+
+// BatchShader is a Shader which can collect many entities and draw them with a single draw call. The RenderSystem
+// prefers DrawBatched over Draw for shaders implementing it.
+type BatchShader interface {
+	Shader
+
+	// DrawBatched adds the quad described by the vertices (as generated by the RenderComponent) to the batch. The
+	// arguments are otherwise the same as for Draw. The batch is drawn whenever the texture changes, when the batch
+	// is full, and in Post.
+	DrawBatched(texture *gl.Texture, vertices []float32, x, y, rotation float32)
+}
+
+// spriteBatch collects the vertices of consecutive quads sharing a texture, so they can be drawn at once
+type spriteBatch struct {
+	texture  *gl.Texture
+	vertices []float32
+	quads    int
+	capacity int
+
+	// flush is called to draw the collected quads
+	flush func(texture *gl.Texture, vertices []float32, quads int)
+}
+
+func newSpriteBatch(capacity int, flush func(*gl.Texture, []float32, int)) *spriteBatch {
+	return &spriteBatch{
+		vertices: make([]float32, capacity*quadSize),
+		capacity: capacity,
+		flush:    flush,
+	}
+}
+
+// add moves the vertices of a quad (which are relative to its pivot) to the given location, rotates them by
+// rotation degrees, and adds them to the batch. The batch is flushed first if needed.
+func (b *spriteBatch) add(texture *gl.Texture, vertices []float32, x, y, rotation float32) {
+	if b.quads > 0 && (texture != b.texture || b.quads == b.capacity) {
+		b.end()
+	}
+	b.texture = texture
+
+	cos, sin := float32(1), float32(0)
+	if rotation != 0 {
+		rot := rotation * (math.Pi / 180.0)
+		cos = math.Cos(rot)
+		sin = math.Sin(rot)
+	}
+
+	offset := b.quads * quadSize
+	copy(b.vertices[offset:offset+quadSize], vertices)
+	for i := offset; i < offset+quadSize; i += vertexSize {
+		vx, vy := b.vertices[i], b.vertices[i+1]
+		b.vertices[i] = x + cos*vx - sin*vy
+		b.vertices[i+1] = y + sin*vx + cos*vy
+	}
+	b.quads++
+}
+
+// end flushes all quads that were collected so far
+func (b *spriteBatch) end() {
+	if b.quads == 0 {
+		return
+	}
+
+	b.flush(b.texture, b.vertices[:b.quads*quadSize], b.quads)
+	b.quads = 0
+}
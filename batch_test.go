@@ -0,0 +1,101 @@
+package engo
+
+import (
+	"testing"
+
+	"engo.io/gl"
+)
+
+type batchFlush struct {
+	texture  *gl.Texture
+	vertices []float32
+	quads    int
+}
+
+func newTestBatch(capacity int) (*spriteBatch, *[]batchFlush) {
+	var flushes []batchFlush
+	batch := newSpriteBatch(capacity, func(texture *gl.Texture, vertices []float32, quads int) {
+		flushes = append(flushes, batchFlush{texture, append([]float32(nil), vertices...), quads})
+	})
+	return batch, &flushes
+}
+
+func testQuad(width, height float32) []float32 {
+	return []float32{
+		0, 0, 0, 0, 1,
+		width, 0, 1, 0, 1,
+		width, height, 1, 1, 1,
+		0, height, 0, 1, 1,
+	}
+}
+
+func TestSpriteBatchTextureChange(t *testing.T) {
+	batch, flushes := newTestBatch(10)
+	tex1, tex2 := &gl.Texture{}, &gl.Texture{}
+
+	batch.add(tex1, testQuad(10, 10), 0, 0, 0)
+	batch.add(tex1, testQuad(10, 10), 20, 0, 0)
+	batch.add(tex2, testQuad(10, 10), 40, 0, 0)
+	batch.add(tex1, testQuad(10, 10), 60, 0, 0)
+	batch.end()
+
+	if len(*flushes) != 3 {
+		t.Fatalf("Expected 3 flushes, got %d", len(*flushes))
+	}
+
+	expected := []struct {
+		texture *gl.Texture
+		quads   int
+	}{{tex1, 2}, {tex2, 1}, {tex1, 1}}
+	for i, e := range expected {
+		f := (*flushes)[i]
+		if f.texture != e.texture || f.quads != e.quads || len(f.vertices) != e.quads*quadSize {
+			t.Errorf("Flush %d: expected %d quads of texture %p, got %d quads (%d floats) of texture %p",
+				i, e.quads, e.texture, f.quads, len(f.vertices), f.texture)
+		}
+	}
+
+	batch.end()
+	if len(*flushes) != 3 {
+		t.Errorf("Expected an empty batch not to be flushed")
+	}
+}
+
+func TestSpriteBatchCapacity(t *testing.T) {
+	batch, flushes := newTestBatch(2)
+	tex := &gl.Texture{}
+
+	for i := 0; i < 5; i++ {
+		batch.add(tex, testQuad(10, 10), float32(i*10), 0, 0)
+	}
+	batch.end()
+
+	quads := []int{2, 2, 1}
+	if len(*flushes) != len(quads) {
+		t.Fatalf("Expected %d flushes, got %d", len(quads), len(*flushes))
+	}
+	for i, q := range quads {
+		if (*flushes)[i].quads != q {
+			t.Errorf("Flush %d: expected %d quads, got %d", i, q, (*flushes)[i].quads)
+		}
+	}
+}
+
+func TestSpriteBatchTransform(t *testing.T) {
+	batch, flushes := newTestBatch(10)
+
+	batch.add(&gl.Texture{}, testQuad(10, 20), 100, 50, 90)
+	batch.end()
+
+	vertices := (*flushes)[0].vertices
+	expected := []Point{{100, 50}, {100, 60}, {80, 60}, {80, 50}}
+	for i, e := range expected {
+		actual := Point{vertices[i*vertexSize], vertices[i*vertexSize+1]}
+		if !pointsAlmostEqual(actual, e) {
+			t.Errorf("Vertex %d: expected %v, got %v", i, e, actual)
+		}
+		if vertices[i*vertexSize+4] != 1 {
+			t.Errorf("Vertex %d: expected the tint to be kept", i)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package engo
+
+import (
+	"sort"
+
+	"github.com/luxengine/math"
+)
+
+// DefaultCellSize is the cell size used by the SpatialHash a CollisionSystem creates when no Broadphase was set
+const DefaultCellSize float32 = 64
+
+// Broadphase quickly finds the entities that could possibly be colliding, so the CollisionSystem only has to do the
+// (more expensive) intersection tests on those. Entities are identified by the ID of their BasicEntity.
+type Broadphase interface {
+	// Insert adds the entity with the given bounding box
+	Insert(id uint64, box AABB)
+	// Update changes the bounding box of an entity that was inserted before
+	Update(id uint64, box AABB)
+	// Remove removes the entity
+	Remove(id uint64)
+	// Query appends the IDs of all entities which may intersect with box to ids, and returns the result. Every ID
+	// is appended at most once, but the candidates are not guaranteed to actually intersect.
+	Query(box AABB, ids []uint64) []uint64
+}
+
+// NaiveBroadphase returns every entity as a candidate for every query. It is the slowest Broadphase there is, but
+// it's useful as a reference and when there are only a handful of entities.
+type NaiveBroadphase struct {
+	ids []uint64
+}
+
+func (n *NaiveBroadphase) Insert(id uint64, box AABB) {
+	n.ids = append(n.ids, id)
+}
+
+func (n *NaiveBroadphase) Update(id uint64, box AABB) {}
+
+func (n *NaiveBroadphase) Remove(id uint64) {
+	for index, other := range n.ids {
+		if other == id {
+			n.ids = append(n.ids[:index], n.ids[index+1:]...)
+			return
+		}
+	}
+}
+
+func (n *NaiveBroadphase) Query(box AABB, ids []uint64) []uint64 {
+	return append(ids, n.ids...)
+}
+
+type cellKey struct {
+	x, y int32
+}
+
+type cellRange struct {
+	min, max cellKey
+}
+
+// SpatialHash is a Broadphase which divides the world into a uniform grid of square cells. Entities are only
+// returned as candidates if they share at least one cell with the queried box, which makes it a good fit for worlds
+// where most entities are roughly the same size - such as tile maps.
+type SpatialHash struct {
+	// CellSize is the width and height of a single cell; it should not be changed once entities were inserted
+	CellSize float32
+
+	cells   map[cellKey][]uint64
+	entries map[uint64]cellRange
+}
+
+// NewSpatialHash creates a new SpatialHash with the given cell size
+func NewSpatialHash(cellSize float32) *SpatialHash {
+	return &SpatialHash{
+		CellSize: cellSize,
+		cells:    make(map[cellKey][]uint64),
+		entries:  make(map[uint64]cellRange),
+	}
+}
+
+func (h *SpatialHash) cellRange(box AABB) cellRange {
+	return cellRange{
+		min: cellKey{int32(math.Floor(box.Min.X / h.CellSize)), int32(math.Floor(box.Min.Y / h.CellSize))},
+		max: cellKey{int32(math.Floor(box.Max.X / h.CellSize)), int32(math.Floor(box.Max.Y / h.CellSize))},
+	}
+}
+
+func (h *SpatialHash) Insert(id uint64, box AABB) {
+	if h.cells == nil {
+		h.cells = make(map[cellKey][]uint64)
+		h.entries = make(map[uint64]cellRange)
+	}
+
+	r := h.cellRange(box)
+	for x := r.min.x; x <= r.max.x; x++ {
+		for y := r.min.y; y <= r.max.y; y++ {
+			key := cellKey{x, y}
+			h.cells[key] = append(h.cells[key], id)
+		}
+	}
+	h.entries[id] = r
+}
+
+func (h *SpatialHash) Update(id uint64, box AABB) {
+	if old, ok := h.entries[id]; ok && old == h.cellRange(box) {
+		return // because it's still in the same cells
+	}
+
+	h.Remove(id)
+	h.Insert(id, box)
+}
+
+func (h *SpatialHash) Remove(id uint64) {
+	r, ok := h.entries[id]
+	if !ok {
+		return
+	}
+
+	for x := r.min.x; x <= r.max.x; x++ {
+		for y := r.min.y; y <= r.max.y; y++ {
+			key := cellKey{x, y}
+			cell := h.cells[key]
+			for index, other := range cell {
+				if other == id {
+					cell = append(cell[:index], cell[index+1:]...)
+					break
+				}
+			}
+
+			if len(cell) == 0 {
+				delete(h.cells, key)
+			} else {
+				h.cells[key] = cell
+			}
+		}
+	}
+	delete(h.entries, id)
+}
+
+func (h *SpatialHash) Query(box AABB, ids []uint64) []uint64 {
+	start := len(ids)
+
+	r := h.cellRange(box)
+	for x := r.min.x; x <= r.max.x; x++ {
+		for y := r.min.y; y <= r.max.y; y++ {
+			ids = append(ids, h.cells[cellKey{x, y}]...)
+		}
+	}
+
+	// Entities spanning multiple cells are found more than once
+	found := idList(ids[start:])
+	sort.Sort(found)
+	unique := start
+	for index, id := range found {
+		if index > 0 && id == found[index-1] {
+			continue // with other ids
+		}
+		ids[unique] = id
+		unique++
+	}
+
+	return ids[:unique]
+}
+
+// QuadTree is a Broadphase which recursively divides its Bounds into four quadrants whenever too many entities are
+// in one of them. It adapts to the distribution of the entities, so it's a good fit when entity sizes vary a lot or
+// when most entities are clustered together. Entities outside of Bounds are still handled correctly, but slowly.
+type QuadTree struct {
+	// MaxObjects is the number of entities a quadrant may hold before it is split
+	MaxObjects int
+	// MaxLevels is the maximum depth of the tree
+	MaxLevels int
+
+	root    *quadNode
+	nodes   map[uint64]*quadNode
+	entries map[uint64]AABB
+}
+
+type quadNode struct {
+	bounds   AABB
+	level    int
+	ids      []uint64
+	children []*quadNode
+}
+
+// NewQuadTree creates a new QuadTree covering the given bounds
+func NewQuadTree(bounds AABB, maxObjects, maxLevels int) *QuadTree {
+	return &QuadTree{
+		MaxObjects: maxObjects,
+		MaxLevels:  maxLevels,
+		root:       &quadNode{bounds: bounds},
+		nodes:      make(map[uint64]*quadNode),
+		entries:    make(map[uint64]AABB),
+	}
+}
+
+func (q *QuadTree) Insert(id uint64, box AABB) {
+	q.entries[id] = box
+	q.insert(q.root, id)
+}
+
+func (q *QuadTree) insert(node *quadNode, id uint64) {
+	for node.children != nil {
+		child := node.childContaining(q.entries[id])
+		if child == nil {
+			break
+		}
+		node = child
+	}
+
+	node.ids = append(node.ids, id)
+	q.nodes[id] = node
+
+	if node.children == nil && len(node.ids) > q.MaxObjects && node.level < q.MaxLevels {
+		q.split(node)
+	}
+}
+
+func (q *QuadTree) split(node *quadNode) {
+	halfWidth := (node.bounds.Max.X - node.bounds.Min.X) / 2
+	halfHeight := (node.bounds.Max.Y - node.bounds.Min.Y) / 2
+	min := node.bounds.Min
+
+	node.children = make([]*quadNode, 4)
+	for i := range node.children {
+		x := min.X + float32(i%2)*halfWidth
+		y := min.Y + float32(i/2)*halfHeight
+		node.children[i] = &quadNode{
+			bounds: AABB{Point{x, y}, Point{x + halfWidth, y + halfHeight}},
+			level:  node.level + 1,
+		}
+	}
+
+	ids := node.ids
+	node.ids = nil
+	for _, id := range ids {
+		q.insert(node, id)
+	}
+}
+
+// childContaining returns the child which fully contains box, or nil if there is no such child
+func (n *quadNode) childContaining(box AABB) *quadNode {
+	for _, child := range n.children {
+		if box.Min.X >= child.bounds.Min.X && box.Max.X <= child.bounds.Max.X &&
+			box.Min.Y >= child.bounds.Min.Y && box.Max.Y <= child.bounds.Max.Y {
+			return child
+		}
+	}
+	return nil
+}
+
+func (q *QuadTree) Update(id uint64, box AABB) {
+	q.Remove(id)
+	q.Insert(id, box)
+}
+
+func (q *QuadTree) Remove(id uint64) {
+	node, ok := q.nodes[id]
+	if !ok {
+		return
+	}
+
+	for index, other := range node.ids {
+		if other == id {
+			node.ids = append(node.ids[:index], node.ids[index+1:]...)
+			break
+		}
+	}
+	delete(q.nodes, id)
+	delete(q.entries, id)
+}
+
+func (q *QuadTree) Query(box AABB, ids []uint64) []uint64 {
+	return q.query(q.root, box, ids, true)
+}
+
+func (q *QuadTree) query(node *quadNode, box AABB, ids []uint64, root bool) []uint64 {
+	// The root node also holds everything outside of the bounds, so it is always checked
+	if !root && !overlaps(node.bounds, box) {
+		return ids
+	}
+
+	for _, id := range node.ids {
+		if overlaps(q.entries[id], box) {
+			ids = append(ids, id)
+		}
+	}
+
+	for _, child := range node.children {
+		ids = q.query(child, box, ids, false)
+	}
+
+	return ids
+}
+
+// overlaps is like IsIntersecting, but also returns true when the boxes only touch
+func overlaps(rect1 AABB, rect2 AABB) bool {
+	return rect1.Max.X >= rect2.Min.X && rect1.Min.X <= rect2.Max.X && rect1.Max.Y >= rect2.Min.Y && rect1.Min.Y <= rect2.Max.Y
+}
+
+type idList []uint64
+
+func (l idList) Len() int           { return len(l) }
+func (l idList) Less(i, j int) bool { return l[i] < l[j] }
+func (l idList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
@@ -171,6 +171,20 @@ func (cam *cameraSystem) centerCam(x, y, z float32) {
 	cam.zoomTo(z)
 }
 
+// CameraBounds returns the part of the world that is visible to the camera; without a camera, it's the WorldBounds
+func CameraBounds() AABB {
+	if cam == nil {
+		return WorldBounds
+	}
+
+	halfWidth := Width() / 2 * cam.z
+	halfHeight := Height() / 2 * cam.z
+	return AABB{
+		Min: Point{cam.x - halfWidth, cam.y - halfHeight},
+		Max: Point{cam.x + halfWidth, cam.y + halfHeight},
+	}
+}
+
 // CameraAxis is the axis at which the Camera can/has to move
 type CameraAxis uint8
 
@@ -2,6 +2,7 @@ package engo
 
 import (
 	"log"
+	"sort"
 
 	"engo.io/ecs"
 	"github.com/luxengine/math"
@@ -15,6 +16,11 @@ type SpaceComponent struct {
 	Position Point
 	Width    float32
 	Height   float32
+
+	// Rotation is the clockwise rotation in degrees, around the Pivot
+	Rotation float32
+	// Pivot is the point around which the entity is rotated, relative to Position
+	Pivot Point
 }
 
 // Center positions the space component according to its center instead of its
@@ -27,34 +33,278 @@ func (sc *SpaceComponent) Center(p Point) {
 	sc.Position.Y = p.Y - yDelta
 }
 
+// AABB returns the axis-aligned bounding box of the space component; when rotated, it contains all its Corners
 func (sc SpaceComponent) AABB() AABB {
-	return AABB{Min: sc.Position, Max: Point{sc.Position.X + sc.Width, sc.Position.Y + sc.Height}}
+	if sc.Rotation == 0 {
+		return AABB{Min: sc.Position, Max: Point{sc.Position.X + sc.Width, sc.Position.Y + sc.Height}}
+	}
+
+	corners := sc.Corners()
+	aabb := AABB{Min: corners[0], Max: corners[0]}
+	for _, c := range corners[1:] {
+		aabb.Min.X = math.Min(aabb.Min.X, c.X)
+		aabb.Min.Y = math.Min(aabb.Min.Y, c.Y)
+		aabb.Max.X = math.Max(aabb.Max.X, c.X)
+		aabb.Max.Y = math.Max(aabb.Max.Y, c.Y)
+	}
+	return aabb
+}
+
+// Corners returns the location of the four corners, starting at the top-left one and going clockwise
+func (sc SpaceComponent) Corners() [4]Point {
+	corners := [4]Point{{0, 0}, {sc.Width, 0}, {sc.Width, sc.Height}, {0, sc.Height}}
+
+	rot := sc.Rotation * (math.Pi / 180.0)
+	cos := math.Cos(rot)
+	sin := math.Sin(rot)
+
+	for i, c := range corners {
+		x := c.X - sc.Pivot.X
+		y := c.Y - sc.Pivot.Y
+		corners[i] = Point{
+			sc.Position.X + sc.Pivot.X + cos*x - sin*y,
+			sc.Position.Y + sc.Pivot.Y + sin*x + cos*y,
+		}
+	}
+
+	return corners
 }
 
+// Contains returns whether or not the point is within the (rotated) space component
+func (sc SpaceComponent) Contains(p Point) bool {
+	// Rotate the point the other way around, so it's in the same space as the unrotated component
+	x := p.X - sc.Position.X - sc.Pivot.X
+	y := p.Y - sc.Position.Y - sc.Pivot.Y
+	if sc.Rotation != 0 {
+		rot := -sc.Rotation * (math.Pi / 180.0)
+		cos := math.Cos(rot)
+		sin := math.Sin(rot)
+		x, y = cos*x-sin*y, sin*x+cos*y
+	}
+	x += sc.Pivot.X
+	y += sc.Pivot.Y
+
+	return x > 0 && x < sc.Width && y > 0 && y < sc.Height
+}
+
+// CollisionLayer is a bitfield of collision layers. Every bit represents a layer, so there are up to 32 layers.
+type CollisionLayer uint32
+
+const (
+	// DefaultCollisionLayer is the layer entities are on when their Category is not set
+	DefaultCollisionLayer CollisionLayer = 1 << iota
+)
+
+// AllCollisionLayers contains every layer; it is used whenever a Mask or SolidMask is not set
+const AllCollisionLayers CollisionLayer = ^CollisionLayer(0)
+
 type CollisionComponent struct {
 	Solid, Main bool
 	Extra       Point
+
+	// Shape is the shape used for collision detection; when nil, the AABB of the SpaceComponent is used
+	Shape Shape
+
+	// Category contains the layers this entity is on; when zero, the entity is on the DefaultCollisionLayer
+	Category CollisionLayer
+	// Mask contains the layers this entity collides with; when zero, it collides with all layers. Two entities only
+	// collide if both of them have a layer of the other one in their Mask.
+	Mask CollisionLayer
+	// SolidMask contains the layers this entity is Solid against; when zero, it's Solid against all layers. Two
+	// colliding entities only push each other away if both are Solid against a layer of the other one.
+	SolidMask CollisionLayer
+
+	// Continuous enables continuous collision detection: instead of only checking for collisions at the current
+	// position, the CollisionSystem checks the entire path from the previous position. This prevents fast moving
+	// Main entities from passing through thin walls. The path is swept using the AABB of the Shape.
+	Continuous bool
+}
+
+func (c *CollisionComponent) category() CollisionLayer {
+	if c.Category == 0 {
+		return DefaultCollisionLayer
+	}
+	return c.Category
+}
+
+func (c *CollisionComponent) mask() CollisionLayer {
+	if c.Mask == 0 {
+		return AllCollisionLayers
+	}
+	return c.Mask
+}
+
+func (c *CollisionComponent) solidMask() CollisionLayer {
+	if c.SolidMask == 0 {
+		return AllCollisionLayers
+	}
+	return c.SolidMask
+}
+
+// Interacts returns whether or not the layers of both components allow them to collide
+func (c *CollisionComponent) Interacts(other *CollisionComponent) bool {
+	return c.mask()&other.category() != 0 && other.mask()&c.category() != 0
+}
+
+// SolidAgainst returns whether or not both components are Solid, on the layers they share
+func (c *CollisionComponent) SolidAgainst(other *CollisionComponent) bool {
+	return c.Solid && other.Solid && c.solidMask()&other.category() != 0 && other.solidMask()&c.category() != 0
 }
 
 type CollisionMessage struct {
 	Entity collisionEntity
 	To     collisionEntity
+
+	// TimeOfImpact is the fraction of this frame's movement after which Entity first touched To. It's only set
+	// when Entity is Continuous.
+	TimeOfImpact float32
+	// Normal is the normal of the surface of To that was hit, pointing towards Entity
+	Normal Point
 }
 
 func (CollisionMessage) Type() string { return "CollisionMessage" }
 
+// CollisionStartMessage is dispatched in the first frame two entities collide. The CollisionMessage is
+// dispatched as well, every frame, as long as they keep colliding.
+type CollisionStartMessage struct {
+	Entity collisionEntity
+	To     collisionEntity
+}
+
+func (CollisionStartMessage) Type() string { return "CollisionStartMessage" }
+
+// CollisionStayMessage is dispatched every frame two entities keep colliding, after the one in which the
+// CollisionStartMessage was dispatched
+type CollisionStayMessage struct {
+	Entity collisionEntity
+	To     collisionEntity
+}
+
+func (CollisionStayMessage) Type() string { return "CollisionStayMessage" }
+
+// CollisionEndMessage is dispatched in the first frame two entities no longer collide, or when one of them is
+// removed from the CollisionSystem
+type CollisionEndMessage struct {
+	Entity collisionEntity
+	To     collisionEntity
+}
+
+func (CollisionEndMessage) Type() string { return "CollisionEndMessage" }
+
+// contactKey identifies a collision between a Main entity and the entity it collided with
+type contactKey struct {
+	entity, to uint64
+}
+
+type contact struct {
+	entity, to collisionEntity
+	frame      uint64 // the last frame in which the entities were colliding
+}
+
 type collisionEntity struct {
 	*ecs.BasicEntity
 	*CollisionComponent
 	*SpaceComponent
+
+	bounds   AABB  // the bounds as they are known to the Broadphase
+	previous Point // the position at the end of the previous frame
+}
+
+// paddedAABB returns the AABB of the entity, grown by the Extra space of the CollisionComponent
+func (e collisionEntity) paddedAABB() AABB {
+	if e.CollisionComponent.Shape != nil {
+		return e.CollisionComponent.Shape.Bounds(e.SpaceComponent.Position, e.CollisionComponent.Extra)
+	}
+
+	aabb := e.SpaceComponent.AABB()
+	offset := Point{e.CollisionComponent.Extra.X / 2, e.CollisionComponent.Extra.Y / 2}
+	aabb.Min.X -= offset.X
+	aabb.Min.Y -= offset.Y
+	aabb.Max.X += offset.X
+	aabb.Max.Y += offset.Y
+	return aabb
+}
+
+// placedShape returns the Shape of the entity in world coordinates, or its (padded) AABB if it has no Shape
+func (e collisionEntity) placedShape() placedShape {
+	if e.CollisionComponent.Shape != nil {
+		return e.CollisionComponent.Shape.place(e.SpaceComponent.Position, e.CollisionComponent.Extra)
+	}
+
+	return aabbShape(e.paddedAABB())
+}
+
+func aabbShape(aabb AABB) placedShape {
+	return placedShape{points: []Point{aabb.Min, {aabb.Max.X, aabb.Min.Y}, aabb.Max, {aabb.Min.X, aabb.Max.Y}}}
+}
+
+// intersection checks whether e1 (with its AABB and Shape as given) intersects with e2, and returns the minimum
+// translation that moves e1 out of e2. The (cheaper) AABB functions are used when neither of them has a Shape, and
+// entityShape is ignored when e1 has no Shape.
+func intersection(e1 collisionEntity, entityAABB AABB, entityShape placedShape, e2 collisionEntity) (Point, bool) {
+	otherAABB := e2.paddedAABB()
+	if !IsIntersecting(entityAABB, otherAABB) {
+		return Point{}, false
+	}
+
+	if e1.CollisionComponent.Shape == nil && e2.CollisionComponent.Shape == nil {
+		return MinimumTranslation(entityAABB, otherAABB), true
+	}
+
+	if e1.CollisionComponent.Shape == nil {
+		entityShape = aabbShape(entityAABB)
+	}
+	return satTranslation(entityShape, e2.placedShape())
+}
+
+// translationNormal returns the direction of the minimum translation, which is the normal of the surface that was hit
+func translationNormal(mtd Point) Point {
+	if mtd.X == 0 && mtd.Y == 0 {
+		return mtd
+	}
+
+	normal, _ := mtd.Normalize()
+	return normal
 }
 
 type CollisionSystem struct {
-	entities []collisionEntity
+	// Broadphase is used to find the entities that might be colliding; it defaults to a SpatialHash with
+	// DefaultCellSize. It should be set before any entities are added.
+	Broadphase Broadphase
+
+	entities   []collisionEntity
+	indices    map[uint64]int
+	candidates []uint64
+	order      []int
+	refreshed  bool // whether the Broadphase knows about entities that moved since the last Update
+
+	contacts map[contactKey]*contact
+	frame    uint64
+
+	lines []Line // static geometry from levels
 }
 
+// Add adds the entity to the CollisionSystem, unless its Shape is invalid (like a polygon of fewer than 3 points)
 func (c *CollisionSystem) Add(basic *ecs.BasicEntity, collision *CollisionComponent, space *SpaceComponent) {
-	c.entities = append(c.entities, collisionEntity{basic, collision, space})
+	if err := shapeError(collision.Shape); err != nil {
+		log.Println("CollisionSystem: not adding entity", basic.ID(), "because of its Shape:", err)
+		return
+	}
+
+	if c.Broadphase == nil {
+		c.Broadphase = NewSpatialHash(DefaultCellSize)
+	}
+	if c.indices == nil {
+		c.indices = make(map[uint64]int)
+	}
+
+	e := collisionEntity{BasicEntity: basic, CollisionComponent: collision, SpaceComponent: space}
+	e.bounds = e.paddedAABB()
+	e.previous = space.Position
+
+	c.indices[basic.ID()] = len(c.entities)
+	c.entities = append(c.entities, e)
+	c.Broadphase.Insert(basic.ID(), e.bounds)
 }
 
 func (c *CollisionSystem) Remove(basic ecs.BasicEntity) {
@@ -67,44 +317,297 @@ func (c *CollisionSystem) Remove(basic ecs.BasicEntity) {
 	}
 	if delete >= 0 {
 		c.entities = append(c.entities[:delete], c.entities[delete+1:]...)
+		c.Broadphase.Remove(basic.ID())
+
+		c.indices = make(map[uint64]int, len(c.entities))
+		for index, e := range c.entities {
+			c.indices[e.BasicEntity.ID()] = index
+		}
+
+		c.endContacts(func(key contactKey, _ *contact) bool {
+			return key.entity == basic.ID() || key.to == basic.ID()
+		})
+	}
+}
+
+// endContacts removes all contacts for which ended returns true, and dispatches a CollisionEndMessage for them
+func (cs *CollisionSystem) endContacts(ended func(contactKey, *contact) bool) {
+	var keys []contactKey
+	for key, c := range cs.contacts {
+		if ended(key, c) {
+			keys = append(keys, key)
+		}
+	}
+
+	// Map iteration is random, but the messages shouldn't be
+	sort.Sort(contactKeys(keys))
+	for _, key := range keys {
+		c := cs.contacts[key]
+		delete(cs.contacts, key)
+		Mailbox.Dispatch(CollisionEndMessage{Entity: c.entity, To: c.to})
+	}
+}
+
+type contactKeys []contactKey
+
+func (k contactKeys) Len() int      { return len(k) }
+func (k contactKeys) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+func (k contactKeys) Less(i, j int) bool {
+	if k[i].entity == k[j].entity {
+		return k[i].to < k[j].to
 	}
+	return k[i].entity < k[j].entity
+}
+
+// refresh lets the Broadphase know about the entity at the given index, if it moved or changed size
+func (cs *CollisionSystem) refresh(index int) {
+	if index >= len(cs.entities) {
+		return // because it was removed in the meantime
+	}
+
+	e := &cs.entities[index]
+	if aabb := e.paddedAABB(); aabb != e.bounds {
+		e.bounds = aabb
+		cs.Broadphase.Update(e.BasicEntity.ID(), aabb)
+	}
+}
+
+// Refresh lets the Broadphase know about the entities that moved since the last Update. Raycast and the queries do
+// this once after every Update; entities that are moved after that are only seen by them after calling Refresh.
+func (cs *CollisionSystem) Refresh() {
+	for index := range cs.entities {
+		cs.refresh(index)
+	}
+	cs.refreshed = true
 }
 
 func (cs *CollisionSystem) Update(dt float32) {
+	if cs.contacts == nil {
+		cs.contacts = make(map[contactKey]*contact)
+	}
+	cs.frame++
+
+	// Other systems may have moved entities since the last frame
+	cs.Refresh()
+
 	for i1, e1 := range cs.entities {
 		if !e1.CollisionComponent.Main {
 			continue // with other entities
 		}
 
-		entityAABB := e1.SpaceComponent.AABB()
-		offset := Point{e1.CollisionComponent.Extra.X / 2, e1.CollisionComponent.Extra.Y / 2}
-		entityAABB.Min.X -= offset.X
-		entityAABB.Min.Y -= offset.Y
-		entityAABB.Max.X += offset.X
-		entityAABB.Max.Y += offset.Y
+		if e1.CollisionComponent.Continuous && e1.SpaceComponent.Position != e1.previous {
+			cs.sweep(i1, e1)
+			cs.refresh(i1)
+			continue // with other entities
+		}
+
+		entityAABB := e1.paddedAABB()
+		var entityShape placedShape
+		if e1.CollisionComponent.Shape != nil {
+			entityShape = e1.placedShape()
+		}
 
-		for i2, e2 := range cs.entities {
+		for _, i2 := range cs.query(entityAABB) {
 			if i1 == i2 {
 				continue // with other entities, because we won't collide with ourselves
 			}
+			if i2 >= len(cs.entities) {
+				break // because entities were removed while handling a CollisionMessage
+			}
 
-			otherAABB := e2.SpaceComponent.AABB()
-			offset = Point{e2.CollisionComponent.Extra.X / 2, e2.CollisionComponent.Extra.Y / 2}
-			otherAABB.Min.X -= offset.X
-			otherAABB.Min.Y -= offset.Y
-			otherAABB.Max.X += offset.X
-			otherAABB.Max.Y += offset.Y
+			e2 := cs.entities[i2]
+			if !e1.CollisionComponent.Interacts(e2.CollisionComponent) {
+				continue // with other entities, because their layers don't collide
+			}
 
-			if IsIntersecting(entityAABB, otherAABB) {
-				if e1.CollisionComponent.Solid && e2.CollisionComponent.Solid {
-					mtd := MinimumTranslation(entityAABB, otherAABB)
+			if mtd, ok := intersection(e1, entityAABB, entityShape, e2); ok {
+				if e1.CollisionComponent.SolidAgainst(e2.CollisionComponent) {
 					e1.SpaceComponent.Position.X += mtd.X
 					e1.SpaceComponent.Position.Y += mtd.Y
 				}
 
-				Mailbox.Dispatch(CollisionMessage{Entity: e1, To: e2})
+				cs.dispatch(CollisionMessage{Entity: e1, To: e2, Normal: translationNormal(mtd)})
 			}
 		}
+
+		cs.refresh(i1)
+	}
+
+	for index := range cs.entities {
+		cs.entities[index].previous = cs.entities[index].SpaceComponent.Position
+	}
+	cs.refreshed = false
+
+	cs.endContacts(func(_ contactKey, c *contact) bool {
+		return c.frame != cs.frame
+	})
+}
+
+// query returns the indices of the entities the Broadphase finds for the given box. They are sorted in the order
+// they were added, so the results of the CollisionSystem don't depend on the Broadphase.
+func (cs *CollisionSystem) query(box AABB) []int {
+	cs.candidates = cs.Broadphase.Query(box, cs.candidates[:0])
+	cs.order = cs.order[:0]
+	for _, id := range cs.candidates {
+		cs.order = append(cs.order, cs.indices[id])
+	}
+	sort.Ints(cs.order)
+
+	return cs.order
+}
+
+// dispatch sends the CollisionMessage, and the CollisionStartMessage or CollisionStayMessage that goes with it
+func (cs *CollisionSystem) dispatch(msg CollisionMessage) {
+	e1, e2 := msg.Entity, msg.To
+	Mailbox.Dispatch(msg)
+
+	key := contactKey{e1.BasicEntity.ID(), e2.BasicEntity.ID()}
+	if c, ok := cs.contacts[key]; ok {
+		c.frame = cs.frame
+		Mailbox.Dispatch(CollisionStayMessage{Entity: e1, To: e2})
+	} else {
+		cs.contacts[key] = &contact{entity: e1, to: e2, frame: cs.frame}
+		Mailbox.Dispatch(CollisionStartMessage{Entity: e1, To: e2})
+	}
+}
+
+type sweptHit struct {
+	index        int
+	timeOfImpact float32
+	normal       Point
+	overlapping  bool // whether or not the entities were already colliding before moving
+}
+
+// sweep does the collision detection for a Continuous entity, by sweeping its AABB from the previous position to
+// the current one. If it hits anything solid, it's moved back to the first point of contact.
+func (cs *CollisionSystem) sweep(i1 int, e1 collisionEntity) {
+	entityAABB := e1.paddedAABB()
+	movement := e1.SpaceComponent.Position
+	movement.Subtract(e1.previous)
+
+	previousAABB := entityAABB
+	previousAABB.Min.Subtract(movement)
+	previousAABB.Max.Subtract(movement)
+
+	swept := AABB{
+		Min: Point{math.Min(entityAABB.Min.X, previousAABB.Min.X), math.Min(entityAABB.Min.Y, previousAABB.Min.Y)},
+		Max: Point{math.Max(entityAABB.Max.X, previousAABB.Max.X), math.Max(entityAABB.Max.Y, previousAABB.Max.Y)},
+	}
+
+	var hits []sweptHit
+	first := float32(1)
+	solidHit := false
+
+	for _, i2 := range cs.query(swept) {
+		if i1 == i2 {
+			continue // with other entities, because we won't collide with ourselves
+		}
+
+		e2 := cs.entities[i2]
+		if !e1.CollisionComponent.Interacts(e2.CollisionComponent) {
+			continue // with other entities, because their layers don't collide
+		}
+
+		otherAABB := e2.paddedAABB()
+
+		if IsIntersecting(previousAABB, otherAABB) {
+			hits = append(hits, sweptHit{index: i2, overlapping: true})
+			continue // with other entities, because there's nothing to sweep
+		}
+
+		toi, normal, ok := SweptAABB(previousAABB, movement, otherAABB)
+		if !ok {
+			continue // with other entities
+		}
+
+		hits = append(hits, sweptHit{index: i2, timeOfImpact: toi, normal: normal})
+		if e1.CollisionComponent.SolidAgainst(e2.CollisionComponent) && (!solidHit || toi < first) {
+			first = toi
+			solidHit = true
+		}
+	}
+
+	if solidHit {
+		e1.SpaceComponent.Position.X = e1.previous.X + movement.X*first
+		e1.SpaceComponent.Position.Y = e1.previous.Y + movement.Y*first
+		entityAABB = e1.paddedAABB()
+	}
+
+	for _, hit := range hits {
+		if hit.index >= len(cs.entities) {
+			break // because entities were removed while handling a CollisionMessage
+		}
+
+		e2 := cs.entities[hit.index]
+
+		if hit.overlapping {
+			// Those were colliding already, so they're handled like any other collision
+			mtd, ok := intersection(e1, entityAABB, e1.placedShape(), e2)
+			if !ok {
+				continue // with other entities
+			}
+
+			if e1.CollisionComponent.SolidAgainst(e2.CollisionComponent) {
+				e1.SpaceComponent.Position.X += mtd.X
+				e1.SpaceComponent.Position.Y += mtd.Y
+			}
+			hit.normal = translationNormal(mtd)
+		} else if solidHit && hit.timeOfImpact > first {
+			continue // with other entities, because we've stopped before reaching those
+		}
+
+		cs.dispatch(CollisionMessage{Entity: e1, To: e2, TimeOfImpact: hit.timeOfImpact, Normal: hit.normal})
+	}
+}
+
+// SweptAABB calculates when the moving AABB, when moved by movement, first touches the static AABB. The time of
+// impact is a fraction of the movement between 0 and 1, and the normal points away from the surface that was hit.
+// It returns false if the boxes don't touch during the movement, or if they were already intersecting.
+func SweptAABB(moving AABB, movement Point, static AABB) (float32, Point, bool) {
+	xEntry, xExit, ok := sweepAxis(moving.Min.X, moving.Max.X, static.Min.X, static.Max.X, movement.X)
+	if !ok {
+		return 0, Point{}, false
+	}
+	yEntry, yExit, ok := sweepAxis(moving.Min.Y, moving.Max.Y, static.Min.Y, static.Max.Y, movement.Y)
+	if !ok {
+		return 0, Point{}, false
+	}
+
+	entry := math.Max(xEntry, yEntry)
+	exit := math.Min(xExit, yExit)
+	if entry >= exit || entry < 0 || entry > 1 {
+		return 0, Point{}, false
+	}
+
+	normal := Point{}
+	if xEntry > yEntry {
+		if movement.X > 0 {
+			normal.X = -1
+		} else {
+			normal.X = 1
+		}
+	} else {
+		if movement.Y > 0 {
+			normal.Y = -1
+		} else {
+			normal.Y = 1
+		}
+	}
+
+	return entry, normal, true
+}
+
+// sweepAxis returns the times at which the moving interval enters and exits the static one on a single axis
+func sweepAxis(min, max, staticMin, staticMax, movement float32) (float32, float32, bool) {
+	switch {
+	case movement > 0:
+		return (staticMin - max) / movement, (staticMax - min) / movement, true
+	case movement < 0:
+		return (staticMax - min) / movement, (staticMin - max) / movement, true
+	case max <= staticMin || min >= staticMax:
+		return 0, 0, false
+	default:
+		return math.Inf(-1), math.Inf(1), true
 	}
 }
 
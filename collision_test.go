@@ -0,0 +1,326 @@
+package engo
+
+import (
+	"math/rand"
+	"testing"
+
+	"engo.io/ecs"
+)
+
+type collisionTestEntity struct {
+	ecs.BasicEntity
+	CollisionComponent
+	SpaceComponent
+}
+
+// newCollisionTestWorld fills a CollisionSystem with a grid of static tiles and some moving Main entities
+func newCollisionTestWorld(broadphase Broadphase, tiles, movers int) (*CollisionSystem, []*collisionTestEntity) {
+	Mailbox = &MessageManager{}
+	rng := rand.New(rand.NewSource(42))
+
+	sys := &CollisionSystem{Broadphase: broadphase}
+	var moving []*collisionTestEntity
+
+	for i := 0; i < tiles; i++ {
+		e := &collisionTestEntity{BasicEntity: ecs.NewBasic()}
+		e.SpaceComponent = SpaceComponent{Position: Point{float32(i%100) * 32, float32(i/100) * 32}, Width: 32, Height: 32}
+		e.CollisionComponent = CollisionComponent{Solid: i%3 == 0}
+		sys.Add(&e.BasicEntity, &e.CollisionComponent, &e.SpaceComponent)
+	}
+
+	for i := 0; i < movers; i++ {
+		e := &collisionTestEntity{BasicEntity: ecs.NewBasic()}
+		e.SpaceComponent = SpaceComponent{Position: Point{rng.Float32() * 3200, rng.Float32() * 3200}, Width: 20, Height: 20}
+		e.CollisionComponent = CollisionComponent{Solid: true, Main: true, Extra: Point{4, 4}}
+		sys.Add(&e.BasicEntity, &e.CollisionComponent, &e.SpaceComponent)
+		moving = append(moving, e)
+	}
+
+	return sys, moving
+}
+
+// collisionPair holds the indices of two colliding entities, so different worlds can be compared
+type collisionPair struct {
+	entity, to int
+}
+
+// runCollisionFrames moves the Main entities around, and records every CollisionMessage that was dispatched
+func runCollisionFrames(broadphase Broadphase, frames int) []collisionPair {
+	sys, moving := newCollisionTestWorld(broadphase, 2000, 50)
+	rng := rand.New(rand.NewSource(7))
+
+	var pairs []collisionPair
+	Mailbox.Listen("CollisionMessage", func(msg Message) {
+		c := msg.(CollisionMessage)
+		pairs = append(pairs, collisionPair{sys.indices[c.Entity.ID()], sys.indices[c.To.ID()]})
+	})
+
+	for i := 0; i < frames; i++ {
+		for _, e := range moving {
+			e.Position.X += rng.Float32()*40 - 20
+			e.Position.Y += rng.Float32()*40 - 20
+		}
+		sys.Update(1.0 / 60)
+	}
+
+	return pairs
+}
+
+func TestCollisionBroadphasesMatchNaive(t *testing.T) {
+	expected := runCollisionFrames(&NaiveBroadphase{}, 20)
+	if len(expected) == 0 {
+		t.Fatal("Expected the test world to have collisions")
+	}
+
+	broadphases := map[string]Broadphase{
+		"SpatialHash": NewSpatialHash(DefaultCellSize),
+		"QuadTree":    NewQuadTree(AABB{Point{0, 0}, Point{3200, 3200}}, 8, 6),
+	}
+	for name, broadphase := range broadphases {
+		actual := runCollisionFrames(broadphase, 20)
+		if len(actual) != len(expected) {
+			t.Errorf("%s dispatched %d messages, expected %d", name, len(actual), len(expected))
+			continue // with other broadphases
+		}
+		for i := range expected {
+			if actual[i] != expected[i] {
+				t.Errorf("%s message %d was %v, expected %v", name, i, actual[i], expected[i])
+				break
+			}
+		}
+	}
+}
+
+func TestCollisionSystemRemove(t *testing.T) {
+	sys, moving := newCollisionTestWorld(NewSpatialHash(DefaultCellSize), 0, 2)
+	moving[0].Solid = false
+	moving[1].Position = moving[0].Position
+
+	collisions := 0
+	Mailbox.Listen("CollisionMessage", func(Message) { collisions++ })
+
+	sys.Update(1)
+	if collisions != 2 {
+		t.Errorf("Expected 2 collisions before removing, got %d", collisions)
+	}
+
+	collisions = 0
+	sys.Remove(moving[1].BasicEntity)
+	sys.Update(1)
+	if collisions != 0 {
+		t.Errorf("Expected no collisions after removing, got %d", collisions)
+	}
+}
+
+func TestCollisionLayers(t *testing.T) {
+	const (
+		bulletLayer CollisionLayer = DefaultCollisionLayer << (iota + 1)
+		wallLayer
+	)
+
+	sys, moving := newCollisionTestWorld(NewSpatialHash(DefaultCellSize), 0, 3)
+	bullet1, bullet2, wall := moving[0], moving[1], moving[2]
+	bullet1.Category, bullet1.Mask = bulletLayer, wallLayer
+	bullet2.Category, bullet2.Mask = bulletLayer, wallLayer
+	wall.Category, wall.SolidMask = wallLayer, DefaultCollisionLayer
+	bullet2.Position = bullet1.Position
+	wall.Position = bullet1.Position
+
+	var pairs []collisionPair
+	Mailbox.Listen("CollisionMessage", func(msg Message) {
+		c := msg.(CollisionMessage)
+		pairs = append(pairs, collisionPair{sys.indices[c.Entity.ID()], sys.indices[c.To.ID()]})
+	})
+
+	sys.Update(1)
+
+	expected := []collisionPair{{0, 2}, {1, 2}, {2, 0}, {2, 1}}
+	if len(pairs) != len(expected) {
+		t.Fatalf("Expected collisions %v, got %v", expected, pairs)
+	}
+	for i := range expected {
+		if pairs[i] != expected[i] {
+			t.Errorf("Expected collisions %v, got %v", expected, pairs)
+			break
+		}
+	}
+
+	if bullet1.Position != wall.Position || bullet2.Position != wall.Position {
+		t.Error("Bullets should not be pushed away by a wall that's only solid against the default layer")
+	}
+}
+
+func TestCollisionStartStayEnd(t *testing.T) {
+	sys, moving := newCollisionTestWorld(NewSpatialHash(DefaultCellSize), 0, 2)
+	moving[0].Solid = false
+	moving[1].Main = false
+	moving[1].Position = moving[0].Position
+
+	var events []string
+	for _, kind := range []string{"CollisionStartMessage", "CollisionStayMessage", "CollisionEndMessage"} {
+		Mailbox.Listen(kind, func(msg Message) { events = append(events, msg.Type()) })
+	}
+
+	sys.Update(1)
+	sys.Update(1)
+	sys.Update(1)
+	moving[0].Position.X += 100
+	sys.Update(1)
+	sys.Update(1)
+	moving[0].Position.X -= 100
+	sys.Update(1)
+	sys.Remove(moving[1].BasicEntity)
+
+	expected := []string{
+		"CollisionStartMessage", "CollisionStayMessage", "CollisionStayMessage", "CollisionEndMessage",
+		"CollisionStartMessage", "CollisionEndMessage",
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Expected events %v, got %v", expected, events)
+			break
+		}
+	}
+	if len(sys.contacts) != 0 {
+		t.Errorf("Expected no contacts to be tracked after removing, got %d", len(sys.contacts))
+	}
+}
+
+func TestCollisionContinuous(t *testing.T) {
+	for _, continuous := range []bool{false, true} {
+		sys, _ := newCollisionTestWorld(NewSpatialHash(DefaultCellSize), 0, 0)
+
+		bullet := &collisionTestEntity{BasicEntity: ecs.NewBasic()}
+		bullet.SpaceComponent = SpaceComponent{Width: 20, Height: 20}
+		bullet.CollisionComponent = CollisionComponent{Solid: true, Main: true, Continuous: continuous}
+		sys.Add(&bullet.BasicEntity, &bullet.CollisionComponent, &bullet.SpaceComponent)
+
+		wall := &collisionTestEntity{BasicEntity: ecs.NewBasic()}
+		wall.SpaceComponent = SpaceComponent{Position: Point{100, 0}, Width: 2, Height: 20}
+		wall.CollisionComponent = CollisionComponent{Solid: true}
+		sys.Add(&wall.BasicEntity, &wall.CollisionComponent, &wall.SpaceComponent)
+
+		var hit *CollisionMessage
+		Mailbox.Listen("CollisionMessage", func(msg Message) {
+			c := msg.(CollisionMessage)
+			hit = &c
+		})
+
+		sys.Update(1)
+		bullet.Position.X += 200
+		sys.Update(1)
+
+		if !continuous {
+			if hit != nil {
+				t.Error("Expected the bullet to tunnel through the wall without continuous collision detection")
+			}
+			continue // with continuous collision detection
+		}
+
+		if hit == nil {
+			t.Fatal("Expected the bullet to hit the wall")
+		}
+		if hit.TimeOfImpact != 0.4 {
+			t.Errorf("Expected time of impact 0.4, got %v", hit.TimeOfImpact)
+		}
+		if hit.Normal != (Point{-1, 0}) {
+			t.Errorf("Expected normal (-1, 0), got %v", hit.Normal)
+		}
+		if bullet.Position != (Point{80, 0}) {
+			t.Errorf("Expected the bullet to be resolved to the wall at (80, 0), got %v", bullet.Position)
+		}
+	}
+}
+
+func TestCollisionShapes(t *testing.T) {
+	sys, moving := newCollisionTestWorld(NewSpatialHash(DefaultCellSize), 0, 2)
+	for _, e := range moving {
+		e.Extra = Point{}
+		e.Shape = CircleShape{Center: Point{10, 10}, Radius: 10}
+	}
+	moving[0].Position = Point{0, 0}
+	moving[1].Position = Point{16, 16}
+
+	collisions := 0
+	Mailbox.Listen("CollisionMessage", func(Message) { collisions++ })
+
+	sys.Update(1)
+	if collisions != 0 {
+		t.Errorf("Expected circles with overlapping AABBs not to collide, got %d collisions", collisions)
+	}
+
+	moving[1].Position = Point{9, 12}
+	sys.Update(1)
+	if collisions != 1 {
+		t.Errorf("Expected 1 collision, got %d", collisions)
+	}
+	if distance := moving[0].Position.PointDistance(moving[1].Position); distance < 19.99 {
+		t.Errorf("Expected the solid circles to be pushed apart, but they're only %v apart", distance)
+	}
+}
+
+func TestSpaceComponentRotation(t *testing.T) {
+	space := SpaceComponent{Width: 20, Height: 10, Pivot: Point{10, 5}}
+	if !space.Contains(Point{18, 5}) || space.Contains(Point{10, -3}) {
+		t.Error("Expected the unrotated space to be wide")
+	}
+
+	space.Rotation = 90
+	aabb := space.AABB()
+	if !pointsAlmostEqual(aabb.Min, Point{5, -5}) || !pointsAlmostEqual(aabb.Max, Point{15, 15}) {
+		t.Errorf("Expected the rotated AABB to be tall, got %v", aabb)
+	}
+	if space.Contains(Point{18, 5}) || !space.Contains(Point{10, -3}) {
+		t.Error("Expected the rotated space to be tall")
+	}
+
+	space.Pivot = Point{}
+	corners := space.Corners()
+	if !pointsAlmostEqual(corners[1], Point{0, 20}) || !pointsAlmostEqual(corners[3], Point{-10, 0}) {
+		t.Errorf("Expected the corners to be rotated around the top-left corner, got %v", corners)
+	}
+}
+
+func TestSpatialHashQueryUnique(t *testing.T) {
+	h := NewSpatialHash(10)
+	h.Insert(1, AABB{Point{0, 0}, Point{35, 35}})
+	h.Insert(2, AABB{Point{100, 100}, Point{110, 110}})
+
+	ids := h.Query(AABB{Point{5, 5}, Point{25, 25}}, nil)
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("Expected only entity 1 once, got %v", ids)
+	}
+
+	h.Update(1, AABB{Point{100, 100}, Point{101, 101}})
+	if ids = h.Query(AABB{Point{5, 5}, Point{25, 25}}, ids[:0]); len(ids) != 0 {
+		t.Errorf("Expected no entities after moving, got %v", ids)
+	}
+}
+
+func benchmarkCollisionSystem(b *testing.B, broadphase Broadphase) {
+	headless = true
+	sys, moving := newCollisionTestWorld(broadphase, 5000, 100)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range moving {
+			e.Position.X += 1
+		}
+		sys.Update(1.0 / 60)
+	}
+}
+
+func BenchmarkCollisionNaive(b *testing.B) {
+	benchmarkCollisionSystem(b, &NaiveBroadphase{})
+}
+
+func BenchmarkCollisionSpatialHash(b *testing.B) {
+	benchmarkCollisionSystem(b, NewSpatialHash(DefaultCellSize))
+}
+
+func BenchmarkCollisionQuadTree(b *testing.B) {
+	benchmarkCollisionSystem(b, NewQuadTree(AABB{Point{0, 0}, Point{3200, 3200}}, 8, 6))
+}
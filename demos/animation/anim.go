@@ -66,7 +66,7 @@ func (*DefaultScene) Type() string { return "GameWorld" }
 func (*DefaultScene) CreateEntity(point *engo.Point, spriteSheet *engo.Spritesheet, action *engo.AnimationAction) *Animation {
 	entity := &Animation{BasicEntity: ecs.NewBasic()}
 
-	entity.SpaceComponent = engo.SpaceComponent{*point, 150, 150}
+	entity.SpaceComponent = engo.SpaceComponent{Position: *point, Width: 150, Height: 150}
 	entity.RenderComponent = engo.NewRenderComponent(spriteSheet.Cell(action.Frames[0]), engo.Point{3, 3}, "hero")
 	entity.AnimationComponent = engo.NewAnimationComponent(spriteSheet.Drawables(), 0.1)
 	entity.AnimationComponent.AddAnimationActions(actions)
@@ -19,6 +19,7 @@ type Guy struct {
 type Rock struct {
 	ecs.BasicEntity
 	engo.CollisionComponent
+	engo.PhysicsComponent
 	engo.RenderComponent
 	engo.SpaceComponent
 }
@@ -37,7 +38,7 @@ func (*DefaultScene) Setup(w *ecs.World) {
 	w.AddSystem(&engo.RenderSystem{})
 	w.AddSystem(&engo.CollisionSystem{})
 	w.AddSystem(&DeathSystem{})
-	w.AddSystem(&FallingSystem{})
+	w.AddSystem(&engo.PhysicsSystem{})
 	w.AddSystem(&ControlSystem{})
 	w.AddSystem(&RockSpawnSystem{})
 
@@ -153,48 +154,20 @@ func NewRock(world *ecs.World, position engo.Point) {
 	}
 	rock.CollisionComponent = engo.CollisionComponent{Solid: true}
 
+	// Rocks fall at a constant speed, and can't be pushed around
+	rock.PhysicsComponent = engo.NewPhysicsComponent(engo.KinematicBody, 0)
+	rock.PhysicsComponent.Velocity = engo.Point{X: 0, Y: 200}
+
 	for _, system := range world.Systems() {
 		switch sys := system.(type) {
 		case *engo.RenderSystem:
 			sys.Add(&rock.BasicEntity, &rock.RenderComponent, &rock.SpaceComponent)
 		case *engo.CollisionSystem:
 			sys.Add(&rock.BasicEntity, &rock.CollisionComponent, &rock.SpaceComponent)
-		case *FallingSystem:
-			sys.Add(&rock.BasicEntity, &rock.SpaceComponent)
-		}
-	}
-}
-
-type fallingEntity struct {
-	*ecs.BasicEntity
-	*engo.SpaceComponent
-}
-
-type FallingSystem struct {
-	entities []fallingEntity
-}
-
-func (f *FallingSystem) Add(basic *ecs.BasicEntity, space *engo.SpaceComponent) {
-	f.entities = append(f.entities, fallingEntity{basic, space})
-}
-
-func (f *FallingSystem) Remove(basic ecs.BasicEntity) {
-	delete := -1
-	for index, e := range f.entities {
-		if e.BasicEntity.ID() == basic.ID() {
-			delete = index
-			break
+		case *engo.PhysicsSystem:
+			sys.Add(&rock.BasicEntity, &rock.PhysicsComponent, &rock.SpaceComponent)
 		}
 	}
-	if delete >= 0 {
-		f.entities = append(f.entities[:delete], f.entities[delete+1:]...)
-	}
-}
-
-func (f *FallingSystem) Update(dt float32) {
-	for _, e := range f.entities {
-		e.SpaceComponent.Position.Y += 200 * dt
-	}
 }
 
 type DeathSystem struct{}
@@ -22,7 +22,7 @@ type Ball struct {
 	engo.RenderComponent
 	engo.SpaceComponent
 	engo.CollisionComponent
-	SpeedComponent
+	engo.PhysicsComponent
 }
 
 type Score struct {
@@ -35,6 +35,7 @@ type Paddle struct {
 	ecs.BasicEntity
 	ControlComponent
 	engo.CollisionComponent
+	engo.PhysicsComponent
 	engo.RenderComponent
 	engo.SpaceComponent
 }
@@ -47,7 +48,7 @@ func (pong *PongGame) Setup(w *ecs.World) {
 	engo.SetBackground(color.Black)
 	w.AddSystem(&engo.RenderSystem{})
 	w.AddSystem(&engo.CollisionSystem{})
-	w.AddSystem(&SpeedSystem{})
+	w.AddSystem(&engo.PhysicsSystem{})
 	w.AddSystem(&ControlSystem{})
 	w.AddSystem(&BallSystem{})
 	w.AddSystem(&ScoreSystem{})
@@ -67,7 +68,9 @@ func (pong *PongGame) Setup(w *ecs.World) {
 		Height:   ballTexture.Height() * ball.RenderComponent.Scale().Y,
 	}
 	ball.CollisionComponent = engo.CollisionComponent{Main: true, Solid: true}
-	ball.SpeedComponent = SpeedComponent{Point: engo.Point{300, 1000}}
+	// The ball bounces off the paddles without losing speed, and isn't affected by gravity
+	ball.PhysicsComponent = engo.PhysicsComponent{Type: engo.DynamicBody, Restitution: 1}
+	ball.PhysicsComponent.Velocity = engo.Point{300, 1000}
 
 	// Add our entity to the appropriate systems
 	for _, system := range w.Systems() {
@@ -76,16 +79,16 @@ func (pong *PongGame) Setup(w *ecs.World) {
 			sys.Add(&ball.BasicEntity, &ball.RenderComponent, &ball.SpaceComponent)
 		case *engo.CollisionSystem:
 			sys.Add(&ball.BasicEntity, &ball.CollisionComponent, &ball.SpaceComponent)
-		case *SpeedSystem:
-			sys.Add(&ball.BasicEntity, &ball.SpeedComponent, &ball.SpaceComponent)
+		case *engo.PhysicsSystem:
+			sys.Add(&ball.BasicEntity, &ball.PhysicsComponent, &ball.SpaceComponent)
 		case *BallSystem:
-			sys.Add(&ball.BasicEntity, &ball.SpeedComponent, &ball.SpaceComponent)
+			sys.Add(&ball.BasicEntity, &ball.PhysicsComponent, &ball.SpaceComponent)
 		}
 	}
 
 	score := Score{BasicEntity: ecs.NewBasic()}
 	score.RenderComponent = engo.NewRenderComponent(basicFont.Render(" "), engo.Point{1, 1}, "YOLO <3")
-	score.SpaceComponent = engo.SpaceComponent{engo.Point{100, 100}, 100, 100}
+	score.SpaceComponent = engo.SpaceComponent{Position: engo.Point{100, 100}, Width: 100, Height: 100}
 
 	// Add our entity to the appropriate systems
 	for _, system := range w.Systems() {
@@ -116,6 +119,7 @@ func (pong *PongGame) Setup(w *ecs.World) {
 		}
 		paddle.ControlComponent = ControlComponent{schemes[i]}
 		paddle.CollisionComponent = engo.CollisionComponent{Main: false, Solid: true}
+		paddle.PhysicsComponent = engo.PhysicsComponent{Type: engo.KinematicBody, Restitution: 1}
 
 		// Add our entity to the appropriate systems
 		for _, system := range w.Systems() {
@@ -124,8 +128,10 @@ func (pong *PongGame) Setup(w *ecs.World) {
 				sys.Add(&paddle.BasicEntity, &paddle.RenderComponent, &paddle.SpaceComponent)
 			case *engo.CollisionSystem:
 				sys.Add(&paddle.BasicEntity, &paddle.CollisionComponent, &paddle.SpaceComponent)
+			case *engo.PhysicsSystem:
+				sys.Add(&paddle.BasicEntity, &paddle.PhysicsComponent, &paddle.SpaceComponent)
 			case *ControlSystem:
-				sys.Add(&paddle.BasicEntity, &paddle.ControlComponent, &paddle.SpaceComponent)
+				sys.Add(&paddle.BasicEntity, &paddle.ControlComponent, &paddle.PhysicsComponent, &paddle.SpaceComponent)
 			}
 		}
 	}
@@ -133,67 +139,13 @@ func (pong *PongGame) Setup(w *ecs.World) {
 
 func (*PongGame) Type() string { return "PongGame" }
 
-type SpeedComponent struct {
-	engo.Point
-}
-
 type ControlComponent struct {
 	Scheme string
 }
 
-type speedEntity struct {
-	*ecs.BasicEntity
-	*SpeedComponent
-	*engo.SpaceComponent
-}
-
-type SpeedSystem struct {
-	entities []speedEntity
-}
-
-func (s *SpeedSystem) New(*ecs.World) {
-	engo.Mailbox.Listen("CollisionMessage", func(message engo.Message) {
-		log.Println("collision")
-
-		collision, isCollision := message.(engo.CollisionMessage)
-		if isCollision {
-			// See if we also have that Entity, and if so, change the speed
-			for _, e := range s.entities {
-				if e.ID() == collision.Entity.BasicEntity.ID() {
-					e.SpeedComponent.X *= -1
-				}
-			}
-		}
-	})
-}
-
-func (s *SpeedSystem) Add(basic *ecs.BasicEntity, speed *SpeedComponent, space *engo.SpaceComponent) {
-	s.entities = append(s.entities, speedEntity{basic, speed, space})
-}
-
-func (s *SpeedSystem) Remove(basic ecs.BasicEntity) {
-	delete := -1
-	for index, e := range s.entities {
-		if e.BasicEntity.ID() == basic.ID() {
-			delete = index
-			break
-		}
-	}
-	if delete >= 0 {
-		s.entities = append(s.entities[:delete], s.entities[delete+1:]...)
-	}
-}
-
-func (s *SpeedSystem) Update(dt float32) {
-	for _, e := range s.entities {
-		e.SpaceComponent.Position.X += e.SpeedComponent.X * dt
-		e.SpaceComponent.Position.Y += e.SpeedComponent.Y * dt
-	}
-}
-
 type ballEntity struct {
 	*ecs.BasicEntity
-	*SpeedComponent
+	*engo.PhysicsComponent
 	*engo.SpaceComponent
 }
 
@@ -201,8 +153,8 @@ type BallSystem struct {
 	entities []ballEntity
 }
 
-func (b *BallSystem) Add(basic *ecs.BasicEntity, speed *SpeedComponent, space *engo.SpaceComponent) {
-	b.entities = append(b.entities, ballEntity{basic, speed, space})
+func (b *BallSystem) Add(basic *ecs.BasicEntity, physics *engo.PhysicsComponent, space *engo.SpaceComponent) {
+	b.entities = append(b.entities, ballEntity{basic, physics, space})
 }
 
 func (b *BallSystem) Remove(basic ecs.BasicEntity) {
@@ -225,13 +177,13 @@ func (b *BallSystem) Update(dt float32) {
 
 			e.SpaceComponent.Position.X = 400 - 16
 			e.SpaceComponent.Position.Y = 400 - 16
-			e.SpeedComponent.X = 800 * rand.Float32()
-			e.SpeedComponent.Y = 800 * rand.Float32()
+			e.PhysicsComponent.Velocity.X = 800 * rand.Float32()
+			e.PhysicsComponent.Velocity.Y = 800 * rand.Float32()
 		}
 
 		if e.SpaceComponent.Position.Y < 0 {
 			e.SpaceComponent.Position.Y = 0
-			e.SpeedComponent.Y *= -1
+			e.PhysicsComponent.Velocity.Y *= -1
 		}
 
 		if e.SpaceComponent.Position.X > (800 - 16) {
@@ -239,13 +191,13 @@ func (b *BallSystem) Update(dt float32) {
 
 			e.SpaceComponent.Position.X = 400 - 16
 			e.SpaceComponent.Position.Y = 400 - 16
-			e.SpeedComponent.X = 800 * rand.Float32()
-			e.SpeedComponent.Y = 800 * rand.Float32()
+			e.PhysicsComponent.Velocity.X = 800 * rand.Float32()
+			e.PhysicsComponent.Velocity.Y = 800 * rand.Float32()
 		}
 
 		if e.SpaceComponent.Position.Y > (800 - 16) {
 			e.SpaceComponent.Position.Y = 800 - 16
-			e.SpeedComponent.Y *= -1
+			e.PhysicsComponent.Velocity.Y *= -1
 		}
 	}
 }
@@ -253,6 +205,7 @@ func (b *BallSystem) Update(dt float32) {
 type controlEntity struct {
 	*ecs.BasicEntity
 	*ControlComponent
+	*engo.PhysicsComponent
 	*engo.SpaceComponent
 }
 
@@ -260,8 +213,9 @@ type ControlSystem struct {
 	entities []controlEntity
 }
 
-func (c *ControlSystem) Add(basic *ecs.BasicEntity, control *ControlComponent, space *engo.SpaceComponent) {
-	c.entities = append(c.entities, controlEntity{basic, control, space})
+func (c *ControlSystem) Add(basic *ecs.BasicEntity, control *ControlComponent, physics *engo.PhysicsComponent,
+	space *engo.SpaceComponent) {
+	c.entities = append(c.entities, controlEntity{basic, control, physics, space})
 }
 
 func (c *ControlSystem) Remove(basic ecs.BasicEntity) {
@@ -289,16 +243,13 @@ func (c *ControlSystem) Update(dt float32) {
 			down = engo.Keys.Get(engo.ArrowDown).Down()
 		}
 
-		if up {
-			if e.SpaceComponent.Position.Y > 0 {
-				e.SpaceComponent.Position.Y -= 800 * dt
-			}
+		// The PhysicsSystem moves the paddles, so they push the ball along while moving
+		e.PhysicsComponent.Velocity.Y = 0
+		if up && e.SpaceComponent.Position.Y > 0 {
+			e.PhysicsComponent.Velocity.Y = -800
 		}
-
-		if down {
-			if (e.SpaceComponent.Height + e.SpaceComponent.Position.Y) < 800 {
-				e.SpaceComponent.Position.Y += 800 * dt
-			}
+		if down && (e.SpaceComponent.Height+e.SpaceComponent.Position.Y) < 800 {
+			e.PhysicsComponent.Velocity.Y = 800
 		}
 	}
 }
@@ -7,7 +7,6 @@ import (
 	"image/draw"
 	_ "image/png"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
@@ -180,7 +179,8 @@ func RunIteration() {
 		keysUpdate()
 	}
 
-	// Then update the world and all Systems
+	// Then store the resources that were loaded in the background, and update the world and all Systems
+	Files.update()
 	currentWorld.Update(Time.Delta())
 
 	// Lastly, forget keypresses and swap buffers
@@ -430,8 +430,8 @@ func (i *ImageObject) Height() int {
 	return i.data.Rect.Max.Y
 }
 
-func loadImage(r Resource) (Image, error) {
-	file, err := os.Open(r.url)
+func loadImage(fs FileSystem, r Resource) (Image, error) {
+	file, err := fs.Open(r.url)
 	if err != nil {
 		return nil, err
 	}
@@ -448,16 +448,16 @@ func loadImage(r Resource) (Image, error) {
 	return &ImageObject{newm}, nil
 }
 
-func loadJSON(r Resource) (string, error) {
-	file, err := ioutil.ReadFile(r.url)
+func loadJSON(fs FileSystem, r Resource) (string, error) {
+	file, err := readFile(fs, r.url)
 	if err != nil {
 		return "", err
 	}
 	return string(file), nil
 }
 
-func loadFont(r Resource) (*truetype.Font, error) {
-	ttfBytes, err := ioutil.ReadFile(r.url)
+func loadFont(fs FileSystem, r Resource) (*truetype.Font, error) {
+	ttfBytes, err := readFile(fs, r.url)
 	if err != nil {
 		return nil, err
 	}
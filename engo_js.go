@@ -153,6 +153,7 @@ func height() float32 {
 
 func animate(dt float32) {
 	RequestAnimationFrame(animate)
+	Files.update()
 	responder.Update(Time.Delta())
 	Time.Tick()
 	keysUpdate()
@@ -206,7 +207,8 @@ func CancelAnimationFrame(id int) {
 	js.Global.Call("cancelAnimationFrame")
 }
 
-func loadImage(r Resource) (Image, error) {
+// loadImage lets the browser load the image; it can't use the FileSystem, because WebGL needs an HTML image
+func loadImage(fs FileSystem, r Resource) (Image, error) {
 	ch := make(chan error, 1)
 
 	img := js.Global.Get("Image").New()
@@ -0,0 +1,289 @@
+package engo
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileSystem is where the Loader reads its files from. Names are the URLs passed to Files.Add, so the same names
+// work for every FileSystem.
+type FileSystem interface {
+	// Open opens the file for reading
+	Open(name string) (ReadSeekCloser, error)
+	// Stat returns information about the file or directory
+	Stat(name string) (os.FileInfo, error)
+	// ReadDir returns the contents of the directory, sorted by name
+	ReadDir(name string) ([]os.FileInfo, error)
+}
+
+// SetFileSystem makes the Loader read all files from fs, which should not be changed while loading
+func (l *Loader) SetFileSystem(fs FileSystem) {
+	l.fs = fs
+}
+
+// FileSystem returns the FileSystem the Loader reads its files from
+func (l *Loader) FileSystem() FileSystem {
+	return l.fs
+}
+
+// readFile reads the whole file from the FileSystem
+func readFile(fs FileSystem, name string) ([]byte, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ioutil.ReadAll(f)
+}
+
+// OSFileSystem reads files from disk, relative to the working directory. The Loader uses it by default.
+type OSFileSystem struct{}
+
+func (OSFileSystem) Open(name string) (ReadSeekCloser, error) {
+	f, err := os.Open(filepath.FromSlash(name))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (OSFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(filepath.FromSlash(name))
+}
+
+func (OSFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(filepath.FromSlash(name))
+}
+
+// MemoryFileSystem serves files from memory, by their slash-separated name (like "assets/icon.png"). It can be used
+// in tests, or to embed assets in the binary.
+type MemoryFileSystem map[string][]byte
+
+func (m MemoryFileSystem) Open(name string) (ReadSeekCloser, error) {
+	data, ok := m.file(name)
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return readSeekNopCloser{bytes.NewReader(data)}, nil
+}
+
+func (m MemoryFileSystem) Stat(name string) (os.FileInfo, error) {
+	return statNames(m.names(), name, func(name string) os.FileInfo {
+		data, _ := m.file(name)
+		return fileInfo{name: path.Base(name), size: int64(len(data))}
+	})
+}
+
+func (m MemoryFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return readDirNames(m.names(), name, func(name string) os.FileInfo {
+		data, _ := m.file(name)
+		return fileInfo{name: path.Base(name), size: int64(len(data))}
+	})
+}
+
+// file returns the contents of the file called name. The names in the map don't have to be clean, so "./icon.png"
+// can be opened as "icon.png".
+func (m MemoryFileSystem) file(name string) ([]byte, bool) {
+	name = cleanName(name)
+	if data, ok := m[name]; ok {
+		return data, true
+	}
+
+	for n, data := range m {
+		if cleanName(n) == name {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+func (m MemoryFileSystem) names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, cleanName(name))
+	}
+	return names
+}
+
+// ZipFileSystem serves files from a zip archive. Files are decompressed whenever they're opened.
+type ZipFileSystem struct {
+	files  map[string]*zip.File
+	closer io.Closer
+}
+
+// NewZipFileSystem reads the zip archive of the given size from r; it can be used for an archive embedded in the
+// binary, by passing a bytes.Reader
+func NewZipFileSystem(r io.ReaderAt, size int64) (*ZipFileSystem, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return newZipFileSystem(archive.File, nil), nil
+}
+
+// OpenZipFileSystem opens the zip archive at the path on disk; it should be closed when it's no longer needed
+func OpenZipFileSystem(name string) (*ZipFileSystem, error) {
+	archive, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newZipFileSystem(archive.File, archive), nil
+}
+
+func newZipFileSystem(files []*zip.File, closer io.Closer) *ZipFileSystem {
+	z := &ZipFileSystem{files: make(map[string]*zip.File, len(files)), closer: closer}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, "/") {
+			continue // because directories are derived from the names of the files
+		}
+		z.files[cleanName(f.Name)] = f
+	}
+	return z
+}
+
+func (z *ZipFileSystem) Open(name string) (ReadSeekCloser, error) {
+	f, ok := z.files[cleanName(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	// Compressed files can't seek, so the whole file is decompressed at once
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return readSeekNopCloser{bytes.NewReader(data)}, nil
+}
+
+func (z *ZipFileSystem) Stat(name string) (os.FileInfo, error) {
+	return statNames(z.names(), name, func(name string) os.FileInfo {
+		return z.files[name].FileInfo()
+	})
+}
+
+func (z *ZipFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return readDirNames(z.names(), name, func(name string) os.FileInfo {
+		return z.files[name].FileInfo()
+	})
+}
+
+// Close closes the archive, if it was opened by OpenZipFileSystem
+func (z *ZipFileSystem) Close() error {
+	if z.closer == nil {
+		return nil
+	}
+	return z.closer.Close()
+}
+
+func (z *ZipFileSystem) names() []string {
+	names := make([]string, 0, len(z.files))
+	for name := range z.files {
+		names = append(names, name)
+	}
+	return names
+}
+
+// cleanName turns the name into the form used by the MemoryFileSystem and ZipFileSystem, so "./assets/icon.png"
+// and "assets/icon.png" refer to the same file
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// statNames returns information about the file or directory called name, where names are all files that exist
+func statNames(names []string, name string, stat func(string) os.FileInfo) (os.FileInfo, error) {
+	name = cleanName(name)
+	for _, n := range names {
+		if n == name {
+			return stat(n), nil
+		}
+		if name == "" || strings.HasPrefix(n, name+"/") {
+			return fileInfo{name: path.Base(name), dir: true}, nil
+		}
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+// readDirNames returns the files and directories in the directory called name, where names are all files that exist
+func readDirNames(names []string, name string, stat func(string) os.FileInfo) ([]os.FileInfo, error) {
+	prefix := cleanName(name)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var infos []os.FileInfo
+	dirs := make(map[string]bool)
+	for _, n := range names {
+		if !strings.HasPrefix(n, prefix) {
+			continue // with other names
+		}
+
+		rest := n[len(prefix):]
+		if i := strings.Index(rest, "/"); i >= 0 {
+			// The file is in a subdirectory
+			if dir := rest[:i]; !dirs[dir] {
+				dirs[dir] = true
+				infos = append(infos, fileInfo{name: dir, dir: true})
+			}
+			continue // with other names
+		}
+		infos = append(infos, stat(n))
+	}
+
+	if infos == nil {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+
+	sort.Sort(fileInfos(infos))
+	return infos, nil
+}
+
+// fileInfo describes a file or directory of a MemoryFileSystem or ZipFileSystem
+type fileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return f.size }
+func (f fileInfo) ModTime() time.Time { return time.Time{} }
+func (f fileInfo) IsDir() bool        { return f.dir }
+func (f fileInfo) Sys() interface{}   { return nil }
+
+func (f fileInfo) Mode() os.FileMode {
+	if f.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+// fileInfos sorts files by name, like ioutil.ReadDir does
+type fileInfos []os.FileInfo
+
+func (f fileInfos) Len() int           { return len(f) }
+func (f fileInfos) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f fileInfos) Less(i, j int) bool { return f[i].Name() < f[j].Name() }
+
+// readSeekNopCloser is a ReadSeekCloser for data in memory, which doesn't have to be closed
+type readSeekNopCloser struct {
+	io.ReadSeeker
+}
+
+func (readSeekNopCloser) Close() error { return nil }
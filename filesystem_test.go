@@ -0,0 +1,130 @@
+package engo
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"testing"
+)
+
+func testPNG(t *testing.T, size int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testFileSystem(t *testing.T, fs FileSystem) {
+	data, err := readFile(fs, "./assets/data.json")
+	if err != nil || string(data) != `{}` {
+		t.Errorf("Unexpected contents of data.json: %q, %v", data, err)
+	}
+
+	if _, err := fs.Open("assets/missing.json"); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	infos, err := fs.ReadDir("assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		name string
+		dir  bool
+	}{{"data.json", false}, {"icon.png", false}, {"sounds", true}}
+	if len(infos) != len(expected) {
+		t.Fatalf("Expected %d files, got %d", len(expected), len(infos))
+	}
+	for i, e := range expected {
+		if infos[i].Name() != e.name || infos[i].IsDir() != e.dir {
+			t.Errorf("File %d: expected %s (dir: %v), got %s (dir: %v)", i, e.name, e.dir, infos[i].Name(), infos[i].IsDir())
+		}
+	}
+
+	if info, err := fs.Stat("assets/sounds"); err != nil || !info.IsDir() {
+		t.Errorf("Expected assets/sounds to be a directory, got %v, %v", info, err)
+	}
+	if info, err := fs.Stat("assets/data.json"); err != nil || info.IsDir() || info.Size() != 2 {
+		t.Errorf("Expected assets/data.json to be a file of 2 bytes, got %v, %v", info, err)
+	}
+}
+
+func TestMemoryFileSystem(t *testing.T) {
+	testFileSystem(t, MemoryFileSystem{
+		"assets/data.json":       []byte(`{}`),
+		"assets/icon.png":        testPNG(t, 4),
+		"assets/sounds/beep.wav": []byte("RIFF"),
+	})
+}
+
+func TestMemoryFileSystemUncleanNames(t *testing.T) {
+	// The same files, but with names that aren't clean
+	testFileSystem(t, MemoryFileSystem{
+		"./assets/data.json":      []byte(`{}`),
+		"assets//icon.png":        testPNG(t, 4),
+		"/assets/sounds/beep.wav": []byte("RIFF"),
+	})
+}
+
+func TestZipFileSystem(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range map[string][]byte{
+		"assets/data.json":       []byte(`{}`),
+		"assets/icon.png":        testPNG(t, 4),
+		"assets/sounds/beep.wav": []byte("RIFF"),
+	} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := NewZipFileSystem(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFileSystem(t, fs)
+}
+
+func TestLoaderFileSystem(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	l := NewLoader()
+	l.SetFileSystem(MemoryFileSystem{
+		"assets/data.json":       []byte(`{}`),
+		"assets/icon.png":        testPNG(t, 4),
+		"assets/sounds/beep.wav": []byte("RIFF"),
+	})
+
+	if err := l.AddFromDir("assets", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.TryLoad(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tex, err := l.TryImage("icon.png"); err != nil || tex.Width() != 4 {
+		t.Errorf("Expected icon.png to be loaded, got %v, %v", tex, err)
+	}
+	if data, err := l.TryJson("data.json"); err != nil || data != `{}` {
+		t.Errorf("Expected data.json to be loaded, got %q, %v", data, err)
+	}
+
+	f, err := l.TrySound("beep.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if data, _ := ioutil.ReadAll(f); string(data) != "RIFF" {
+		t.Errorf("Unexpected contents of beep.wav: %q", data)
+	}
+}
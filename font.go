@@ -5,7 +5,6 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"io/ioutil"
 	"log"
 
 	"github.com/golang/freetype"
@@ -27,10 +26,15 @@ type Font struct {
 	TTF  *truetype.Font
 }
 
-// Create is for loading fonts from the disk, given a location
+// Create is for loading fonts from the FileSystem of Files (or the disk), given a location
 func (f *Font) Create() error {
+	var fs FileSystem = OSFileSystem{}
+	if Files != nil {
+		fs = Files.fs
+	}
+
 	// Read and parse the font
-	ttfBytes, err := ioutil.ReadFile(f.URL)
+	ttfBytes, err := readFile(fs, f.URL)
 	if err != nil {
 		return err
 	}
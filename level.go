@@ -1,5 +1,10 @@
 package engo
 
+import (
+	"strconv"
+	"time"
+)
+
 type Level struct {
 	Width      int
 	Height     int
@@ -8,16 +13,180 @@ type Level struct {
 	Tiles      []*tile
 	LineBounds []Line
 	Images     []*tile
+
+	// StartX and StartY are the location of the top-left tile in tiles, which is only non-zero for infinite maps
+	StartX, StartY int
+
+	// Orientation is the way the tiles are laid out. Staggered and Hexagonal maps shift every other row, or every
+	// other column when StaggerX is true; those are the odd ones, or the even ones when StaggerEven is true.
+	Orientation Orientation
+	StaggerX    bool
+	StaggerEven bool
+	// HexSideLength is the length of the straight (horizontal or vertical) sides of the tiles of Hexagonal maps
+	HexSideLength int
+
+	// Properties are the custom properties of the map
+	Properties Properties
+	// Tilesets are the tilesets used by the map, ordered by their Firstgid
+	Tilesets []*Tileset
+	// TileLayers are the layers of tiles, in the order they're drawn
+	TileLayers []*TileLayer
+	// ImageLayers are the layers consisting of a single image, in the order they're drawn
+	ImageLayers []*ImageLayer
+	// ObjectGroups are the layers of objects, in the order they're drawn
+	ObjectGroups []*ObjectGroup
+	// Animations contains the animation of every animated tile, by gid
+	Animations map[uint32]*TileAnimation
+}
+
+// Properties are the custom properties of a map, layer, tileset, tile or object, by name
+type Properties map[string]string
+
+// Bool returns the property as a bool, or false if it isn't one
+func (p Properties) Bool(name string) bool {
+	b, _ := strconv.ParseBool(p[name])
+	return b
+}
+
+// Int returns the property as an int, or 0 if it isn't one
+func (p Properties) Int(name string) int {
+	i, _ := strconv.Atoi(p[name])
+	return i
+}
+
+// Float returns the property as a float32, or 0 if it isn't one
+func (p Properties) Float(name string) float32 {
+	f, _ := strconv.ParseFloat(p[name], 32)
+	return float32(f)
+}
+
+// Tileset is a set of tiles, cut from a single image
+type Tileset struct {
+	Name                  string
+	Firstgid              int
+	TileWidth, TileHeight int
+	// Spacing is the number of pixels between the tiles, and Margin the number of pixels around them
+	Spacing, Margin int
+	Image           *Texture
+	Properties      Properties
+
+	// Tiles contains the tiles which have a type or custom properties, by their ID within the tileset
+	Tiles map[int]*TileInfo
+}
+
+// TileInfo contains the type, custom properties, collision shapes and animation of a single tile of a Tileset
+type TileInfo struct {
+	ID         int
+	Type       string
+	Properties Properties
+
+	// Shapes are the collision shapes of the tile, of which the positions are relative to the top-left corner of the
+	// tile
+	Shapes []*Object
+	// Animation contains the frames of an animated tile, or nothing if the tile isn't animated
+	Animation []TileFrame
+}
+
+// TileFrame is a single frame of the animation of a tile
+type TileFrame struct {
+	// TileID is the ID of the tile that is shown, within the same Tileset
+	TileID   int
+	Duration time.Duration
 }
 
+// TileLayer is a layer of tiles
+type TileLayer struct {
+	Name          string
+	Width, Height int
+	Visible       bool
+	Opacity       float32
+	Properties    Properties
+
+	// Tiles contains a tile for every cell, row by row; the tiles of empty cells have no Image
+	Tiles []*tile
+}
+
+// ImageLayer is a layer consisting of a single image
+type ImageLayer struct {
+	Name       string
+	Properties Properties
+	Image      *tile
+}
+
+// TileInfo returns the type and properties of the tile with the global ID, or nil if it has none
+func (lvl *Level) TileInfo(gid int) *TileInfo {
+	for i := len(lvl.Tilesets) - 1; i >= 0; i-- {
+		if ts := lvl.Tilesets[i]; gid >= ts.Firstgid {
+			return ts.Tiles[gid-ts.Firstgid]
+		}
+	}
+	return nil
+}
+
+// TileLayer returns the first TileLayer with the name, or nil if there's none
+func (lvl *Level) TileLayer(name string) *TileLayer {
+	for _, l := range lvl.TileLayers {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// ObjectGroup returns the first ObjectGroup with the name, or nil if there's none
+func (lvl *Level) ObjectGroup(name string) *ObjectGroup {
+	for _, g := range lvl.ObjectGroups {
+		if g.Name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// The flags Tiled stores in the highest bits of a gid
+const (
+	FlippedHorizontally uint32 = 0x80000000
+	FlippedVertically   uint32 = 0x40000000
+	FlippedDiagonally   uint32 = 0x20000000
+
+	// rotatedHexagonal is only used by hexagonal maps, which rotate tiles by 120 degrees instead of flipping them
+	// diagonally
+	rotatedHexagonal uint32 = 0x10000000
+	flipFlags               = FlippedHorizontally | FlippedVertically | FlippedDiagonally | rotatedHexagonal
+)
+
 type tile struct {
 	Point
 	Image *Region
+	// Rotation is the clockwise rotation in degrees the Image has to be drawn with, which is 90 for tiles flipped
+	// diagonally
+	Rotation float32
+
+	// Gid is the global ID of the tile, without the flip flags
+	Gid                 uint32
+	FlippedHorizontally bool
+	FlippedVertically   bool
+	FlippedDiagonally   bool
+	// Info contains the type, properties, collision shapes and animation of the tile, or nil if it has none
+	Info *TileInfo
+	// Animation is the animation of an animated tile, which is shared by all tiles of the same type
+	Animation *TileAnimation
+}
+
+// CurrentImage returns the image the tile shows right now, which is the current frame of its Animation (flipped
+// like the tile) for animated tiles
+func (t *tile) CurrentImage() *Region {
+	if t.Animation == nil || len(t.Animation.Frames) == 0 {
+		return t.Image
+	}
+	return t.flip(t.Animation.Region())
 }
 
 type tilesheet struct {
-	Image    *Texture
-	Firstgid int
+	Image                 *Texture
+	Firstgid              int
+	TileWidth, TileHeight int
+	Spacing, Margin       int
 }
 
 type layer struct {
@@ -25,23 +194,53 @@ type layer struct {
 	TileMapping []uint32
 }
 
+// createTileset cuts all sheets into tiles; the index of a tile is its gid - 1, so there are nil tiles for gids
+// which aren't used
 func createTileset(lvl *Level, sheets []*tilesheet) []*tile {
 	tileset := make([]*tile, 0)
-	tw := lvl.TileWidth
-	th := lvl.TileHeight
 
 	for _, sheet := range sheets {
-		setWidth := int(sheet.Image.Width()) / tw
-		setHeight := int(sheet.Image.Height()) / th
+		tw, th := sheet.TileWidth, sheet.TileHeight
+		if tw <= 0 || th <= 0 {
+			tw, th = lvl.TileWidth, lvl.TileHeight
+		}
+
+		for len(tileset) < sheet.Firstgid-1 {
+			tileset = append(tileset, nil)
+		}
+
+		setWidth := (int(sheet.Image.Width()) - 2*sheet.Margin + sheet.Spacing) / (tw + sheet.Spacing)
+		setHeight := (int(sheet.Image.Height()) - 2*sheet.Margin + sheet.Spacing) / (th + sheet.Spacing)
 		totalTiles := setWidth * setHeight
 
 		for i := 0; i < totalTiles; i++ {
-			t := &tile{}
-			t.Image = regionFromSheet(sheet.Image, tw, th, i)
+			t := &tile{Gid: uint32(sheet.Firstgid + i)}
+			t.Image = regionFromSheet(sheet.Image, tw, th, sheet.Spacing, sheet.Margin, i)
+			t.Info = lvl.TileInfo(int(t.Gid))
 			tileset = append(tileset, t)
 		}
 	}
 
+	// The frames of animations can only be found once all tiles are there
+	for _, t := range tileset {
+		if t == nil || t.Info == nil || len(t.Info.Animation) == 0 {
+			continue // with other tiles, because it isn't animated
+		}
+
+		firstgid := int(t.Gid) - t.Info.ID
+		t.Animation = newTileAnimation(t.Info.Animation, func(id int) *Region {
+			if idx := firstgid + id - 1; idx >= 0 && idx < len(tileset) && tileset[idx] != nil {
+				return tileset[idx].Image
+			}
+			return t.Image
+		})
+
+		if lvl.Animations == nil {
+			lvl.Animations = make(map[uint32]*TileAnimation)
+		}
+		lvl.Animations[t.Gid] = t.Animation
+	}
+
 	return tileset
 }
 
@@ -49,27 +248,75 @@ func createLevelTiles(lvl *Level, layers []*layer, ts []*tile) []*tile {
 	tilemap := make([]*tile, 0)
 
 	for _, lay := range layers {
-		mapping := lay.TileMapping
-		for y := 0; y < lvl.Height; y++ {
-			for x := 0; x < lvl.Width; x++ {
-				idx := x + y*lvl.Width
-				t := &tile{}
-				if tileIdx := int(mapping[idx]) - 1; tileIdx >= 0 {
-					t.Image = ts[tileIdx].Image
-					t.Point = Point{float32(x * lvl.TileWidth), float32(y * lvl.TileHeight)}
-				}
-				tilemap = append(tilemap, t)
+		tilemap = append(tilemap, createLayerTiles(lvl, lay, ts)...)
+	}
+
+	return tilemap
+}
+
+// createLayerTiles creates a tile for every cell of the layer
+func createLayerTiles(lvl *Level, lay *layer, ts []*tile) []*tile {
+	tiles := make([]*tile, 0, lvl.Width*lvl.Height)
+
+	mapping := lay.TileMapping
+	for y := 0; y < lvl.Height; y++ {
+		for x := 0; x < lvl.Width; x++ {
+			idx := x + y*lvl.Width
+			t := &tile{}
+			if idx >= len(mapping) {
+				tiles = append(tiles, t)
+				continue // because the layer doesn't have data for this cell
 			}
+
+			gid := mapping[idx] &^ flipFlags
+			if tileIdx := int(gid) - 1; tileIdx >= 0 && tileIdx < len(ts) && ts[tileIdx] != nil {
+				t.Point = lvl.TileToWorld(x+lvl.StartX, y+lvl.StartY)
+				t.Gid = gid
+				t.Info = ts[tileIdx].Info
+				t.Animation = ts[tileIdx].Animation
+				flipTile(t, ts[tileIdx].Image, mapping[idx])
+
+				// Tiles which are larger than the grid stick out at the top
+				t.Y += float32(lvl.TileHeight) - ts[tileIdx].Image.Height()
+			}
+			tiles = append(tiles, t)
 		}
 	}
 
-	return tilemap
+	return tiles
+}
+
+// flipTile sets the Image and Rotation of the tile, so it's drawn like Tiled does with the flip flags of the gid.
+// Tiled flips diagonally first, which is the same as flipping vertically and then rotating 90 degrees clockwise.
+// Flipping horizontally after that rotation is the same as flipping vertically before it, and vice versa.
+func flipTile(t *tile, image *Region, gid uint32) {
+	t.FlippedHorizontally = gid&FlippedHorizontally != 0
+	t.FlippedVertically = gid&FlippedVertically != 0
+	t.FlippedDiagonally = gid&FlippedDiagonally != 0
+
+	t.Image = t.flip(image)
+	if !t.FlippedDiagonally {
+		return
+	}
+
+	t.Rotation = 90
+
+	// Rotating around the top-left corner moves the image to the left of it
+	t.X += image.Height()
+}
+
+// flip returns the image flipped like the tile, apart from its Rotation
+func (t *tile) flip(image *Region) *Region {
+	if t.FlippedDiagonally {
+		return image.Flipped(t.FlippedVertically, !t.FlippedHorizontally)
+	}
+	return image.Flipped(t.FlippedHorizontally, t.FlippedVertically)
 }
 
 // Works for tiles rendered right-down
-func regionFromSheet(sheet *Texture, tw, th int, index int) *Region {
-	setWidth := int(sheet.Width()) / tw
-	x := (index % setWidth) * tw
-	y := (index / setWidth) * th
+func regionFromSheet(sheet *Texture, tw, th, spacing, margin int, index int) *Region {
+	setWidth := (int(sheet.Width()) - 2*margin + spacing) / (tw + spacing)
+	x := margin + (index%setWidth)*(tw+spacing)
+	y := margin + (index/setWidth)*(th+spacing)
 	return NewRegion(sheet, float32(x), float32(y), float32(tw), float32(th))
 }
@@ -0,0 +1,137 @@
+package engo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRegionFlipped(t *testing.T) {
+	r := &Region{u: 0.25, v: 0.5, u2: 0.75, v2: 1, width: 8, height: 8}
+
+	if r.Flipped(false, false) != r {
+		t.Error("Expected a Region which isn't flipped to be returned as is")
+	}
+
+	u, v, u2, v2 := r.Flipped(true, false).View()
+	if u != 0.75 || v != 0.5 || u2 != 0.25 || v2 != 1 {
+		t.Errorf("Unexpected view when flipped horizontally: %v %v %v %v", u, v, u2, v2)
+	}
+	u, v, u2, v2 = r.Flipped(false, true).View()
+	if u != 0.25 || v != 1 || u2 != 0.75 || v2 != 0.5 {
+		t.Errorf("Unexpected view when flipped vertically: %v %v %v %v", u, v, u2, v2)
+	}
+	if u, _, _, _ := r.View(); u != 0.25 {
+		t.Error("Expected the original Region not to change")
+	}
+}
+
+// TestFlipTile checks every combination of flip flags, by comparing where the corners of the texture end up when
+// drawn, with where Tiled draws them
+func TestFlipTile(t *testing.T) {
+	region := &Region{u: 0, v: 0, u2: 1, v2: 1, width: 1, height: 1}
+
+	for flags := uint32(0); flags < 8; flags++ {
+		gid := flags<<29 | 1
+		tl := &tile{}
+		flipTile(tl, region, gid)
+
+		// Where the corners of the quad end up, and which corners of the texture are drawn there
+		u, v, u2, v2 := tl.Image.View()
+		corners := map[Point]Point{
+			{0, 0}: {u, v},
+			{1, 0}: {u2, v},
+			{1, 1}: {u2, v2},
+			{0, 1}: {u, v2},
+		}
+
+		for corner, tex := range corners {
+			drawn := (&Object{Position: tl.Point, Rotation: tl.Rotation}).toWorld(corner)
+
+			// Tiled flips diagonally first, then horizontally and then vertically
+			expected := tex
+			if gid&FlippedDiagonally != 0 {
+				expected = Point{expected.Y, expected.X}
+			}
+			if gid&FlippedHorizontally != 0 {
+				expected.X = 1 - expected.X
+			}
+			if gid&FlippedVertically != 0 {
+				expected.Y = 1 - expected.Y
+			}
+
+			if !pointsAlmostEqual(drawn, expected) {
+				t.Errorf("Flags %03b: expected texture corner %v at %v, got %v", flags, tex, expected, drawn)
+			}
+		}
+	}
+}
+
+func TestTilesetSpacingAndFlags(t *testing.T) {
+	// Two columns and rows of 8x8 tiles, with a margin of 1 and spacing of 2
+	spaced := testPNG(t, 20)
+
+	tmx := fmt.Sprintf(`<map width="3" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" name="spaced" tilewidth="8" tileheight="8" spacing="2" margin="1">
+  <image source="spaced.png" width="20" height="20"/>
+  <tile id="3" type="torch">
+   <objectgroup>
+    <object id="1" x="2" y="1" width="4" height="6"/>
+    <object id="2" x="0" y="0"><polygon points="0,0 8,0 0,8"/></object>
+   </objectgroup>
+   <animation>
+    <frame tileid="3" duration="100"/>
+    <frame tileid="2" duration="150"/>
+   </animation>
+  </tile>
+ </tileset>
+ <layer name="ground" width="3" height="1">
+  <data encoding="csv">%d,%d,%d</data>
+ </layer>
+ <objectgroup name="objects">
+  <object id="1" gid="%d" x="0" y="8" width="8" height="8"/>
+ </objectgroup>
+</map>`, 2, FlippedHorizontally|4, FlippedDiagonally|FlippedVertically|4, FlippedVertically|4)
+
+	lvl := loadTestFiles(t, "level.tmx", MemoryFileSystem{"level.tmx": []byte(tmx), "spaced.png": spaced})
+
+	ts := lvl.Tilesets[0]
+	if ts.Spacing != 2 || ts.Margin != 1 {
+		t.Errorf("Unexpected spacing and margin: %d, %d", ts.Spacing, ts.Margin)
+	}
+
+	// The second tile starts at x=11 of the image
+	tiles := lvl.TileLayers[0].Tiles
+	if u, v, u2, v2 := tiles[0].Image.View(); !pointsAlmostEqual(Point{u, v}, Point{11.0 / 20, 1.0 / 20}) ||
+		!pointsAlmostEqual(Point{u2, v2}, Point{19.0 / 20, 9.0 / 20}) {
+		t.Errorf("Unexpected view of tile 2: %v %v %v %v", u, v, u2, v2)
+	}
+
+	if tiles[1].Gid != 4 || !tiles[1].FlippedHorizontally || tiles[1].FlippedVertically || tiles[1].Rotation != 0 {
+		t.Errorf("Unexpected flipped tile: %+v", tiles[1])
+	}
+	if u, _, u2, _ := tiles[1].Image.View(); u <= u2 {
+		t.Errorf("Expected the texture to be mirrored, got u=%v, u2=%v", u, u2)
+	}
+	if !tiles[2].FlippedDiagonally || tiles[2].Rotation != 90 || tiles[2].Point != (Point{24, 0}) {
+		t.Errorf("Unexpected diagonally flipped tile: %+v", tiles[2])
+	}
+
+	info := tiles[1].Info
+	if info == nil || info.Type != "torch" || info != tiles[2].Info {
+		t.Fatalf("Expected the info of tile 4 on both tiles, got %v", info)
+	}
+	if len(info.Shapes) != 2 || info.Shapes[0].Kind != RectangleObject || info.Shapes[1].Kind != PolygonObject ||
+		info.Shapes[0].Position != (Point{2, 1}) {
+		t.Errorf("Unexpected collision shapes: %v", info.Shapes)
+	}
+	expected := []TileFrame{{3, 100 * time.Millisecond}, {2, 150 * time.Millisecond}}
+	if len(info.Animation) != 2 || info.Animation[0] != expected[0] || info.Animation[1] != expected[1] {
+		t.Errorf("Expected animation %v, got %v", expected, info.Animation)
+	}
+
+	obj := lvl.ObjectGroup("objects").Objects[0]
+	if obj.Kind != TileObject || obj.Gid != 4 || !obj.FlippedVertically || obj.FlippedHorizontally {
+		t.Errorf("Unexpected tile object: %+v", obj)
+	}
+}
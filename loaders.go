@@ -0,0 +1,306 @@
+package engo
+
+import (
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+)
+
+// FileLoader loads the resources of a specific kind; it's registered for one or more extensions with
+// Loader.Register
+type FileLoader interface {
+	// Decode reads and decodes the resource from the FileSystem. LoadAsync calls it from a background goroutine, so
+	// it shouldn't create textures or use other resources.
+	Decode(fs FileSystem, r Resource) (interface{}, error)
+	// Load stores the data returned by Decode, so it can be retrieved with Resource. It's called on the render
+	// thread.
+	Load(r Resource, data interface{}) error
+	// Unload removes the resource, and releases whatever it holds on to
+	Unload(r Resource) error
+	// Resource returns the resource that was loaded with the given name
+	Resource(name string) (interface{}, bool)
+}
+
+// DeferredFileLoader is a FileLoader whose resources use other resources, like a level using the images of its
+// tilesets. When Deferred returns true, its resources are only stored after all other resources of the same Load.
+type DeferredFileLoader interface {
+	FileLoader
+	Deferred() bool
+}
+
+// Register makes the Loader use the FileLoader for all resources with the extension (like "png"), replacing the
+// FileLoader that was registered before. It should not be called while loading.
+func (l *Loader) Register(extension string, loader FileLoader) {
+	l.loaders[strings.TrimPrefix(extension, ".")] = loader
+}
+
+// Resource returns the resource with the given name, from the FileLoader registered for its extension
+func (l *Loader) Resource(name string) (interface{}, error) {
+	r, err := ParseResource(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if loader, ok := l.loaders[r.kind]; ok {
+		if res, ok := loader.Resource(r.name); ok {
+			return res, nil
+		}
+	}
+	return nil, l.notLoaded(r.name)
+}
+
+// registerDefaults registers the FileLoaders for all formats engo supports out of the box
+func (l *Loader) registerDefaults() {
+	l.Register("png", imageLoader{l})
+	l.Register("jpg", imageLoader{l})
+	l.Register("json", jsonLoader{l})
+	l.Register("tmx", levelLoader{l})
+	l.Register("tmj", levelLoader{l})
+	l.Register("wav", soundLoader{l})
+	l.Register("ttf", fontLoader{l})
+}
+
+type imageLoader struct {
+	l *Loader
+}
+
+func (imageLoader) Decode(fs FileSystem, r Resource) (interface{}, error) {
+	return loadImage(fs, r)
+}
+
+func (i imageLoader) Load(r Resource, data interface{}) error {
+	img := data.(Image)
+	if i.l.packable(img) {
+		// The texture is created once all images of this Load can be packed into the atlas
+		i.l.packing[r.name] = img
+		return nil
+	}
+
+	i.l.images[r.name] = NewTexture(img)
+	return nil
+}
+
+func (i imageLoader) Unload(r Resource) error {
+	if img, ok := i.l.images[r.name]; ok {
+		img.Close()
+		delete(i.l.images, r.name)
+	}
+	return nil
+}
+
+// Reload replaces the OpenGL texture. When the texture was part of an atlas, it gets a texture of its own, so
+// Regions created from it have to be created again.
+func (i imageLoader) Reload(r Resource, data interface{}) error {
+	img, ok := i.l.images[r.name]
+	if !ok {
+		return i.Load(r, data)
+	}
+
+	img.Close()
+	*img = *NewTexture(data.(Image))
+	return nil
+}
+
+func (i imageLoader) Resource(name string) (interface{}, bool) {
+	img, ok := i.l.images[name]
+	return img, ok
+}
+
+// jsonLoader is deferred, because JSON files may be maps saved by Tiled or sprite sheets, which use images
+type jsonLoader struct {
+	l *Loader
+}
+
+// jsonData is a decoded JSON file, which is also a level when it's a map saved by Tiled, or an AnimationSheet when
+// it's a sprite sheet exported by Aseprite or TexturePacker
+type jsonData struct {
+	text  string
+	level *TMXLevel
+	sheet *animationSheetData
+}
+
+func (jsonLoader) Decode(fs FileSystem, r Resource) (interface{}, error) {
+	text, err := loadJSON(fs, r)
+	if err != nil {
+		return jsonData{text: text}, err
+	}
+
+	switch {
+	case isTiledJSONMap([]byte(text)):
+		level, err := decodeTiledJSON(fs, r, []byte(text))
+		return jsonData{text: text, level: level}, err
+	case isSpriteSheetJSON([]byte(text)):
+		sheet, err := decodeAnimationSheet([]byte(text))
+		return jsonData{text: text, sheet: sheet}, err
+	}
+	return jsonData{text: text}, nil
+}
+
+func (j jsonLoader) Load(r Resource, data interface{}) error {
+	d := data.(jsonData)
+	if d.level != nil {
+		if err := (levelLoader{j.l}).Load(r, d.level); err != nil {
+			return err
+		}
+	}
+	if d.sheet != nil {
+		sheet, err := newAnimationSheet(j.l, d.sheet)
+		if err != nil {
+			return err
+		}
+		j.l.animations[r.name] = sheet
+	}
+
+	j.l.jsons[r.name] = d.text
+	return nil
+}
+
+func (j jsonLoader) Unload(r Resource) error {
+	delete(j.l.jsons, r.name)
+	delete(j.l.levels, r.name)
+	delete(j.l.animations, r.name)
+	return nil
+}
+
+// Reload replaces the contents of the Level or AnimationSheet, so they can still be used by whoever has them
+func (j jsonLoader) Reload(r Resource, data interface{}) error {
+	d := data.(jsonData)
+	if d.level != nil {
+		if err := (levelLoader{j.l}).Reload(r, d.level); err != nil {
+			return err
+		}
+	}
+	if d.sheet != nil {
+		sheet, err := newAnimationSheet(j.l, d.sheet)
+		if err != nil {
+			return err
+		}
+		if old, ok := j.l.animations[r.name]; ok {
+			*old = *sheet
+		} else {
+			j.l.animations[r.name] = sheet
+		}
+	}
+
+	j.l.jsons[r.name] = d.text
+	return nil
+}
+
+// Resource returns the Level or AnimationSheet of the JSON file when it's a map saved by Tiled or a sprite sheet, and
+// its text otherwise
+func (j jsonLoader) Resource(name string) (interface{}, bool) {
+	if level, ok := j.l.levels[name]; ok {
+		return level, true
+	}
+	if sheet, ok := j.l.animations[name]; ok {
+		return sheet, true
+	}
+
+	data, ok := j.l.jsons[name]
+	return data, ok
+}
+
+func (jsonLoader) Deferred() bool { return true }
+
+// levelLoader is deferred, because levels can only be created once the images of their tilesets are loaded
+type levelLoader struct {
+	l *Loader
+}
+
+func (levelLoader) Decode(fs FileSystem, r Resource) (interface{}, error) {
+	if r.kind == "tmx" {
+		return parseTmx(fs, r)
+	}
+	return parseTiledJSON(fs, r)
+}
+
+func (lvl levelLoader) Load(r Resource, data interface{}) error {
+	level, err := createLevelFromTmx(lvl.l, data.(*TMXLevel))
+	if err != nil {
+		return err
+	}
+
+	lvl.l.levels[r.name] = level
+	return nil
+}
+
+func (lvl levelLoader) Unload(r Resource) error {
+	delete(lvl.l.levels, r.name)
+	return nil
+}
+
+func (lvl levelLoader) Reload(r Resource, data interface{}) error {
+	level, err := createLevelFromTmx(lvl.l, data.(*TMXLevel))
+	if err != nil {
+		return err
+	}
+
+	if old, ok := lvl.l.levels[r.name]; ok {
+		*old = *level
+		return nil
+	}
+	lvl.l.levels[r.name] = level
+	return nil
+}
+
+func (lvl levelLoader) Resource(name string) (interface{}, bool) {
+	level, ok := lvl.l.levels[name]
+	return level, ok
+}
+
+func (levelLoader) Deferred() bool { return true }
+
+// soundLoader only remembers the URL, because sounds are streamed from the FileSystem while playing
+type soundLoader struct {
+	l *Loader
+}
+
+func (soundLoader) Decode(fs FileSystem, r Resource) (interface{}, error) {
+	return r.url, nil
+}
+
+func (s soundLoader) Load(r Resource, data interface{}) error {
+	s.l.sounds[r.name] = data.(string)
+	return nil
+}
+
+func (s soundLoader) Unload(r Resource) error {
+	delete(s.l.sounds, r.name)
+	return nil
+}
+
+func (s soundLoader) Resource(name string) (interface{}, bool) {
+	url, ok := s.l.sounds[name]
+	return url, ok
+}
+
+type fontLoader struct {
+	l *Loader
+}
+
+func (fontLoader) Decode(fs FileSystem, r Resource) (interface{}, error) {
+	return loadFont(fs, r)
+}
+
+func (f fontLoader) Load(r Resource, data interface{}) error {
+	f.l.fonts[r.name] = data.(*truetype.Font)
+	return nil
+}
+
+func (f fontLoader) Unload(r Resource) error {
+	delete(f.l.fonts, r.name)
+	return nil
+}
+
+func (f fontLoader) Reload(r Resource, data interface{}) error {
+	if old, ok := f.l.fonts[r.name]; ok {
+		*old = *data.(*truetype.Font)
+		return nil
+	}
+	return f.Load(r, data)
+}
+
+func (f fontLoader) Resource(name string) (interface{}, bool) {
+	font, ok := f.l.fonts[name]
+	return font, ok
+}
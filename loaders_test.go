@@ -0,0 +1,80 @@
+package engo
+
+import (
+	"strings"
+	"testing"
+)
+
+// dialogueLoader is a FileLoader for a custom format: every line of a .dialogue file is a line of dialogue
+type dialogueLoader struct {
+	l         *Loader
+	dialogues map[string][]string
+	portrait  map[string]*Texture // the portrait that was loaded when the dialogue was stored
+	deferred  bool
+}
+
+func (dialogueLoader) Decode(fs FileSystem, r Resource) (interface{}, error) {
+	data, err := readFile(fs, r.URL())
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n"), nil
+}
+
+func (d *dialogueLoader) Load(r Resource, data interface{}) error {
+	d.dialogues[r.Name()] = data.([]string)
+	d.portrait[r.Name()] = d.l.Image("portrait.png")
+	return nil
+}
+
+func (d *dialogueLoader) Unload(r Resource) error {
+	delete(d.dialogues, r.Name())
+	return nil
+}
+
+func (d *dialogueLoader) Resource(name string) (interface{}, bool) {
+	lines, ok := d.dialogues[name]
+	return lines, ok
+}
+
+func (d *dialogueLoader) Deferred() bool { return d.deferred }
+
+func TestLoaderRegister(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	l := NewLoader()
+	dialogues := &dialogueLoader{l: l, dialogues: make(map[string][]string), portrait: make(map[string]*Texture), deferred: true}
+	l.Register(".dialogue", dialogues)
+	l.SetFileSystem(MemoryFileSystem{
+		"intro.dialogue": []byte("Hello!\nWelcome to engo.\n"),
+		"portrait.png":   testPNG(t, 4),
+		"notes.txt":      []byte("not a resource"),
+	})
+
+	// The dialogue is added before the portrait, but uses it
+	l.Add("intro.dialogue", "portrait.png", "notes.txt")
+	if err := l.TryLoad(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := l.Resource("intro.dialogue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := res.([]string); len(lines) != 2 || lines[1] != "Welcome to engo." {
+		t.Errorf("Unexpected dialogue: %q", lines)
+	}
+	if dialogues.portrait["intro.dialogue"] == nil {
+		t.Error("Expected the deferred loader to be able to use the other resources")
+	}
+
+	if _, err := l.Resource("notes.txt"); err == nil {
+		t.Error("Expected resources without a FileLoader not to be loaded")
+	}
+
+	// The built-in formats go through the same registry
+	if res, err := l.Resource("portrait.png"); err != nil || res.(*Texture) != l.Image("portrait.png") {
+		t.Errorf("Expected the texture of portrait.png, got %v, %v", res, err)
+	}
+}
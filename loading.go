@@ -0,0 +1,229 @@
+package engo
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+)
+
+// LoadProgress describes how far the Loader is with loading resources
+type LoadProgress struct {
+	// Loaded is the number of resources that have been handled, including the ones that failed to load
+	Loaded int
+	// Total is the number of resources that are being loaded
+	Total int
+	// Current is the URL of the resource that was handled most recently
+	Current string
+	// Errors contains an error for every resource that failed to load
+	Errors []ResourceError
+	// Done indicates that all resources have been handled
+	Done bool
+}
+
+// Fraction returns the part of the resources that have been handled, between 0 and 1
+func (p LoadProgress) Fraction() float32 {
+	if p.Total == 0 {
+		return 1
+	}
+	return float32(p.Loaded) / float32(p.Total)
+}
+
+// Err returns a LoadErrors listing all resources that failed to load, or nil if there weren't any
+func (p LoadProgress) Err() error {
+	if len(p.Errors) == 0 {
+		return nil
+	}
+	return LoadErrors(p.Errors)
+}
+
+var (
+	// ErrNoExtension is the reason a resource without an extension can't be loaded
+	ErrNoExtension = errors.New("resource has no extension")
+
+	// ErrNotLoaded is returned when a resource is requested that wasn't loaded (yet)
+	ErrNotLoaded = errors.New("resource isn't loaded")
+)
+
+// ResourceError is the reason a resource couldn't be loaded
+type ResourceError struct {
+	Kind string
+	URL  string
+	Err  error
+}
+
+func (e ResourceError) Error() string {
+	if e.Kind == "" {
+		return fmt.Sprintf("failed to load resource %q: %v", e.URL, e.Err)
+	}
+	return fmt.Sprintf("failed to load %s resource %q: %v", e.Kind, e.URL, e.Err)
+}
+
+// LoadErrors lists every resource that failed to load
+type LoadErrors []ResourceError
+
+func (e LoadErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d resource(s) failed to load:\n\t%s", len(e), strings.Join(msgs, "\n\t"))
+}
+
+// LoadProgressMessage is dispatched whenever the Loader has handled a resource, and once more when it's done
+type LoadProgressMessage struct {
+	LoadProgress
+}
+
+func (LoadProgressMessage) Type() string { return "LoadProgressMessage" }
+
+// loadJob is a single resource that's being loaded
+type loadJob struct {
+	index    int
+	resource Resource
+	data     interface{}
+	err      error
+}
+
+// loadJobs sorts jobs in the order their resources were added
+type loadJobs []loadJob
+
+func (j loadJobs) Len() int           { return len(j) }
+func (j loadJobs) Swap(a, b int)      { j[a], j[b] = j[b], j[a] }
+func (j loadJobs) Less(a, b int) bool { return j[a].index < j[b].index }
+
+// loadState is the state of a single call to Load or LoadAsync
+type loadState struct {
+	resources []Resource
+	results   chan loadJob
+	remaining int       // the number of resources that still have to be handled
+	deferred  []loadJob // the jobs of DeferredFileLoaders, which are handled last
+	onFinish  func()
+}
+
+func (l *Loader) newLoadState(onFinish func()) *loadState {
+	state := &loadState{onFinish: onFinish}
+
+	queued := make(map[string]bool)
+	for _, r := range l.resources {
+		if l.skip(r) || queued[r.name] {
+			continue // with other resources
+		}
+		queued[r.name] = true
+		state.resources = append(state.resources, r)
+	}
+	state.remaining = len(state.resources)
+
+	// URLs that were rejected by Add are reported as failed right away
+	l.progress = LoadProgress{
+		Loaded: len(l.invalid),
+		Total:  len(state.resources) + len(l.invalid),
+		Errors: l.invalid,
+	}
+	l.invalid = nil
+
+	return state
+}
+
+// LoadAsync loads all resources which haven't been loaded yet, like Load, but returns immediately. The files are
+// read and decoded by background goroutines, while the textures are created on the render thread, in between
+// frames. Use Progress or listen for LoadProgressMessage to keep track of it; onFinish is called on the render
+// thread once everything has been loaded.
+func (l *Loader) LoadAsync(onFinish func()) {
+	l.wait()
+
+	state := l.newLoadState(onFinish)
+	state.results = make(chan loadJob, len(state.resources))
+	l.loading = state
+
+	// The queue is filled before any worker starts, so a worker can stop as soon as it finds the queue empty
+	queue := make(chan loadJob, len(state.resources))
+	for i, r := range state.resources {
+		queue <- loadJob{index: i, resource: r}
+	}
+
+	for i := 0; i < runtime.NumCPU(); i++ {
+		go func() {
+			for {
+				select {
+				case job := <-queue:
+					job.data, job.err = l.decode(job.resource)
+					state.results <- job
+				default:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Loading returns whether or not LoadAsync is still busy
+func (l *Loader) Loading() bool {
+	return l.loading != nil
+}
+
+// Progress returns how far the Loader is with the current (or last) call to Load or LoadAsync
+func (l *Loader) Progress() LoadProgress {
+	return l.progress
+}
+
+// update is called by the game loop on every frame
+func (l *Loader) update() {
+	l.updateLoading()
+	l.updateWatch()
+}
+
+// updateLoading handles the resources that were decoded since the last frame
+func (l *Loader) updateLoading() {
+	state := l.loading
+	if state == nil {
+		return
+	}
+
+	for state.remaining > 0 {
+		select {
+		case job := <-state.results:
+			l.handle(state, job)
+		default:
+			return // because the other resources are still being decoded
+		}
+	}
+
+	l.loading = nil
+	l.finish(state)
+}
+
+// wait blocks until LoadAsync is done
+func (l *Loader) wait() {
+	state := l.loading
+	if state == nil {
+		return
+	}
+
+	for state.remaining > 0 {
+		l.handle(state, <-state.results)
+	}
+
+	l.loading = nil
+	l.finish(state)
+}
+
+// report marks the resource of the job as handled
+func (l *Loader) report(job loadJob, err error) {
+	l.progress.Loaded++
+	l.progress.Current = job.resource.url
+
+	if err != nil {
+		log.Println("Error loading resource:", err)
+		resourceErr := ResourceError{job.resource.kind, job.resource.url, err}
+		l.progress.Errors = append(l.progress.Errors, resourceErr)
+		l.failed[job.resource.name] = resourceErr
+	} else {
+		delete(l.failed, job.resource.name)
+	}
+
+	if Mailbox != nil {
+		Mailbox.Dispatch(LoadProgressMessage{l.progress})
+	}
+}
@@ -0,0 +1,181 @@
+package engo
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestAssets creates a directory with the given PNG images (by name and size) and a JSON file
+func writeTestAssets(t *testing.T, images map[string]int) string {
+	dir, err := ioutil.TempDir("", "engo-assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, size := range images {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(f, image.NewNRGBA(image.Rect(0, 0, size, size))); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "data.json"), []byte(`{"answer": 42}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestLoaderLoadAsync(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	dir := writeTestAssets(t, map[string]int{"a.png": 4, "b.png": 8, "c.png": 16})
+	defer os.RemoveAll(dir)
+
+	var messages []LoadProgress
+	Mailbox.Listen("LoadProgressMessage", func(msg Message) {
+		messages = append(messages, msg.(LoadProgressMessage).LoadProgress)
+	})
+
+	l := NewLoader()
+	l.Add(filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png"), filepath.Join(dir, "c.png"),
+		filepath.Join(dir, "data.json"), filepath.Join(dir, "missing.png"))
+
+	finished := 0
+	l.LoadAsync(func() { finished++ })
+
+	for frames := 0; l.Loading(); frames++ {
+		if frames > 5000 {
+			t.Fatal("Loading didn't finish")
+		}
+		time.Sleep(time.Millisecond)
+		l.update()
+	}
+
+	if finished != 1 {
+		t.Errorf("Expected onFinish to be called once, got %d", finished)
+	}
+
+	for name, size := range map[string]float32{"a.png": 4, "b.png": 8, "c.png": 16} {
+		if tex := l.Image(name); tex == nil || tex.Width() != size {
+			t.Errorf("Expected %s to be loaded with width %v, got %v", name, size, tex)
+		}
+	}
+	if l.Json("data.json") != `{"answer": 42}` {
+		t.Errorf("Unexpected JSON: %q", l.Json("data.json"))
+	}
+
+	progress := l.Progress()
+	if progress.Loaded != 5 || progress.Total != 5 || !progress.Done || progress.Fraction() != 1 {
+		t.Errorf("Unexpected progress: %+v", progress)
+	}
+	if len(progress.Errors) != 1 || progress.Errors[0].URL != filepath.Join(dir, "missing.png") || progress.Errors[0].Kind != "png" {
+		t.Errorf("Expected a single error for missing.png, got %v", progress.Errors)
+	}
+
+	// One message per resource, and one more when done
+	if len(messages) != 6 {
+		t.Fatalf("Expected 6 progress messages, got %d", len(messages))
+	}
+	for i, msg := range messages[:5] {
+		if msg.Loaded != i+1 || msg.Done {
+			t.Errorf("Message %d: unexpected progress %+v", i, msg)
+		}
+	}
+	if !messages[5].Done {
+		t.Errorf("Expected the last message to be Done")
+	}
+}
+
+func TestLoaderLoadAfterLoadAsync(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	dir := writeTestAssets(t, map[string]int{"a.png": 4, "b.png": 8})
+	defer os.RemoveAll(dir)
+
+	l := NewLoader()
+	l.Add(filepath.Join(dir, "a.png"))
+
+	finished := []string{}
+	l.LoadAsync(func() { finished = append(finished, "async") })
+
+	// Load waits for LoadAsync, and only loads what's new
+	l.Add(filepath.Join(dir, "b.png"))
+	l.Load(func() { finished = append(finished, "sync") })
+
+	if len(finished) != 2 || finished[0] != "async" || finished[1] != "sync" {
+		t.Errorf("Unexpected order of onFinish calls: %v", finished)
+	}
+	if l.Loading() {
+		t.Error("Expected LoadAsync to be done")
+	}
+	if l.Image("a.png") == nil || l.Image("b.png") == nil {
+		t.Error("Expected both images to be loaded")
+	}
+	if progress := l.Progress(); progress.Total != 1 || progress.Loaded != 1 {
+		t.Errorf("Expected only b.png to be loaded by Load, got %+v", progress)
+	}
+}
+
+func TestLoaderLoadAsyncAtlas(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	dir := writeTestAssets(t, map[string]int{"a.png": 4, "b.png": 8, "huge.png": 64})
+	defer os.RemoveAll(dir)
+
+	l := NewLoader()
+	l.UseAtlas(AtlasOptions{Width: 32, Height: 32})
+	l.Add(filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png"), filepath.Join(dir, "huge.png"))
+	l.LoadAsync(nil)
+	l.wait()
+
+	if len(l.AtlasPages()) != 1 {
+		t.Fatalf("Expected a single atlas page, got %d", len(l.AtlasPages()))
+	}
+	page := l.AtlasPages()[0]
+	if l.Image("a.png").id != page.id || l.Image("b.png").id != page.id {
+		t.Error("Expected the small images to share the atlas page")
+	}
+	if huge := l.Image("huge.png"); huge == nil || huge.Width() != 64 {
+		t.Errorf("Expected huge.png to get a texture of its own, got %v", huge)
+	}
+	if progress := l.Progress(); progress.Loaded != 3 || len(progress.Errors) != 0 {
+		t.Errorf("Unexpected progress: %+v", progress)
+	}
+}
+
+func TestLoaderAtlasFailure(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	l := NewLoader()
+
+	// The images were packable when they were loaded, but the atlas was made smaller before it was packed
+	images := map[string]Image{
+		"a.png": &ImageObject{image.NewNRGBA(image.Rect(0, 0, 4, 4))},
+		"b.png": &ImageObject{image.NewNRGBA(image.Rect(0, 0, 8, 8))},
+	}
+	l.UseAtlas(AtlasOptions{Width: 6, Height: 6})
+	l.packAtlas(images)
+
+	if len(l.AtlasPages()) != 0 {
+		t.Errorf("Expected no atlas pages, got %d", len(l.AtlasPages()))
+	}
+	for name := range images {
+		if img := l.Image(name); img == nil || img.page != nil {
+			t.Errorf("Expected %s to get a texture of its own, got %v", name, img)
+		}
+	}
+}
@@ -131,11 +131,8 @@ func (m *MouseSystem) Update(dt float32) {
 		}
 
 		// if the Mouse component is a tracker we always update it
-		// Check if the X-value is within range
-		// and if the Y-value is within range
-		if e.MouseComponent.Track ||
-			mx > e.SpaceComponent.Position.X && mx < (e.SpaceComponent.Position.X+e.SpaceComponent.Width) &&
-				my > e.SpaceComponent.Position.Y && my < (e.SpaceComponent.Position.Y+e.SpaceComponent.Height) {
+		// Check if the mouse is within the (possibly rotated) space
+		if e.MouseComponent.Track || e.SpaceComponent.Contains(Point{mx, my}) {
 
 			e.MouseComponent.Enter = !e.MouseComponent.Hovered
 			e.MouseComponent.Hovered = true
@@ -0,0 +1,234 @@
+package engo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/luxengine/math"
+)
+
+// ObjectKind is the kind of shape of an Object
+type ObjectKind uint8
+
+const (
+	// RectangleObject is a rectangle of Width by Height
+	RectangleObject ObjectKind = iota
+	// EllipseObject is an ellipse, which fits in a rectangle of Width by Height
+	EllipseObject
+	// PointObject is a single point, without a size
+	PointObject
+	// PolygonObject is a closed shape through its Points
+	PolygonObject
+	// PolylineObject is a line through its Points
+	PolylineObject
+	// TileObject is a tile, which is drawn in a rectangle of Width by Height
+	TileObject
+)
+
+// ObjectGroup is a layer of objects
+type ObjectGroup struct {
+	Name       string
+	Color      string
+	Opacity    float32
+	Visible    bool
+	Offset     Point
+	Properties Properties
+	Objects    []*Object
+}
+
+// Object returns the first object with the name, or nil if there's none
+func (g *ObjectGroup) Object(name string) *Object {
+	for _, o := range g.Objects {
+		if o.Name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+// ObjectsOfType returns all objects of the type
+func (g *ObjectGroup) ObjectsOfType(typ string) []*Object {
+	var objects []*Object
+	for _, o := range g.Objects {
+		if o.Type == typ {
+			objects = append(objects, o)
+		}
+	}
+	return objects
+}
+
+// Object is a single object of an ObjectGroup
+type Object struct {
+	ID   int
+	Name string
+	Type string
+	Kind ObjectKind
+
+	// Position is the location of the object: the bottom-left corner for tile objects, and the top-left corner for
+	// all others
+	Position      Point
+	Width, Height float32
+	// Rotation is the rotation in degrees, clockwise around Position
+	Rotation float32
+	// Gid is the global ID of the tile of a TileObject, without the flip flags
+	Gid                 uint32
+	FlippedHorizontally bool
+	FlippedVertically   bool
+	FlippedDiagonally   bool
+	Visible             bool
+
+	// Points are the points of a PolygonObject or PolylineObject, relative to Position
+	Points     []Point
+	Properties Properties
+}
+
+// Lines returns the edges of a PolygonObject or PolylineObject, in world coordinates
+func (o *Object) Lines() []Line {
+	if len(o.Points) < 2 || (o.Kind != PolygonObject && o.Kind != PolylineObject) {
+		return nil
+	}
+
+	points := make([]Point, len(o.Points))
+	for i, p := range o.Points {
+		points[i] = o.toWorld(p)
+	}
+	if o.Kind == PolygonObject {
+		points = append(points, points[0])
+	}
+
+	lines := make([]Line, 0, len(points)-1)
+	for i := 0; i < len(points)-1; i++ {
+		lines = append(lines, Line{points[i], points[i+1]})
+	}
+	return lines
+}
+
+// toWorld rotates the point (relative to Position) around Position, and returns it in world coordinates
+func (o *Object) toWorld(p Point) Point {
+	if o.Rotation != 0 {
+		rot := o.Rotation * (math.Pi / 180.0)
+		cos := math.Cos(rot)
+		sin := math.Sin(rot)
+		p = Point{cos*p.X - sin*p.Y, sin*p.X + cos*p.Y}
+	}
+	return Point{o.Position.X + p.X, o.Position.Y + p.Y}
+}
+
+// newProperties converts the properties of a TMX file
+func newProperties(tmx []TMXProperty) Properties {
+	props := make(Properties, len(tmx))
+	for _, p := range tmx {
+		if p.Value == "" {
+			props[p.Name] = p.Text
+		} else {
+			props[p.Name] = p.Value
+		}
+	}
+	return props
+}
+
+// newObjectGroup converts an object group of a TMX file
+func newObjectGroup(tmx TMXObjGroup) (*ObjectGroup, error) {
+	group := &ObjectGroup{
+		Name:       tmx.Name,
+		Color:      tmx.Color,
+		Opacity:    tmxOpacity(tmx.Opacity),
+		Visible:    tmxVisible(tmx.Visible),
+		Offset:     Point{float32(tmx.OffsetX), float32(tmx.OffsetY)},
+		Properties: newProperties(tmx.Properties),
+		Objects:    make([]*Object, 0, len(tmx.Objects)),
+	}
+
+	for _, o := range tmx.Objects {
+		obj, err := newObject(o)
+		if err != nil {
+			return nil, fmt.Errorf("object %d of object group %q: %v", o.ID, tmx.Name, err)
+		}
+		group.Objects = append(group.Objects, obj)
+	}
+
+	return group, nil
+}
+
+// newObject converts an object of a TMX file
+func newObject(tmx TMXObj) (*Object, error) {
+	obj := &Object{
+		ID:         tmx.ID,
+		Name:       tmx.Name,
+		Type:       tmx.Type,
+		Position:   Point{float32(tmx.X), float32(tmx.Y)},
+		Width:      float32(tmx.Width),
+		Height:     float32(tmx.Height),
+		Rotation:   float32(tmx.Rotation),
+		Gid:        tmx.Gid &^ flipFlags,
+		Visible:    tmxVisible(tmx.Visible),
+		Properties: newProperties(tmx.Properties),
+
+		FlippedHorizontally: tmx.Gid&FlippedHorizontally != 0,
+		FlippedVertically:   tmx.Gid&FlippedVertically != 0,
+		FlippedDiagonally:   tmx.Gid&FlippedDiagonally != 0,
+	}
+	if obj.Type == "" {
+		obj.Type = tmx.Class
+	}
+
+	var err error
+	switch {
+	case tmx.Gid != 0:
+		obj.Kind = TileObject
+	case tmx.Ellipse != nil:
+		obj.Kind = EllipseObject
+	case tmx.Point != nil:
+		obj.Kind = PointObject
+	case len(tmx.Polygons) > 0:
+		obj.Kind = PolygonObject
+		obj.Points, err = parsePoints(tmx.Polygons[0].Points)
+		if err == nil && len(obj.Points) < 3 {
+			err = fmt.Errorf("polygon object %d has %d points, instead of at least 3", obj.ID, len(obj.Points))
+		}
+	case len(tmx.Polylines) > 0:
+		obj.Kind = PolylineObject
+		obj.Points, err = parsePoints(tmx.Polylines[0].Points)
+	default:
+		obj.Kind = RectangleObject
+	}
+
+	return obj, err
+}
+
+// parsePoints parses the points of a polygon or polyline, like "0,0 16,0 16,16"
+func parsePoints(str string) ([]Point, error) {
+	fields := strings.Fields(str)
+	points := make([]Point, len(fields))
+	for i, field := range fields {
+		coords := strings.Split(field, ",")
+		if len(coords) != 2 {
+			return nil, fmt.Errorf("invalid point %q", field)
+		}
+
+		x, err := strconv.ParseFloat(coords[0], 32)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.ParseFloat(coords[1], 32)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = Point{float32(x), float32(y)}
+	}
+	return points, nil
+}
+
+// tmxVisible returns whether or not something of a TMX file is visible; it is, unless it says otherwise
+func tmxVisible(visible *int) bool {
+	return visible == nil || *visible != 0
+}
+
+// tmxOpacity returns the opacity of something of a TMX file, which is 1 unless it says otherwise
+func tmxOpacity(opacity *float64) float32 {
+	if opacity == nil {
+		return 1
+	}
+	return float32(*opacity)
+}
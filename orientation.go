@@ -0,0 +1,223 @@
+package engo
+
+import (
+	"fmt"
+
+	"github.com/luxengine/math"
+)
+
+// Orientation is the way the tiles of a Level are laid out
+type Orientation uint8
+
+const (
+	// Orthogonal maps have rectangular tiles in rows and columns
+	Orthogonal Orientation = iota
+	// Isometric maps have diamond-shaped tiles, of which the x axis goes down to the right and the y axis down to the
+	// left
+	Isometric
+	// Staggered maps have diamond-shaped tiles, of which every other row (or column) is shifted by half a tile
+	Staggered
+	// Hexagonal maps have hexagonal tiles, of which every other row (or column) is shifted by half a tile
+	Hexagonal
+)
+
+// parseOrientation converts the orientation attribute of Tiled, which is orthogonal when it's empty
+func parseOrientation(orientation string) (Orientation, error) {
+	switch orientation {
+	case "", "orthogonal":
+		return Orthogonal, nil
+	case "isometric":
+		return Isometric, nil
+	case "staggered":
+		return Staggered, nil
+	case "hexagonal":
+		return Hexagonal, nil
+	default:
+		return Orthogonal, fmt.Errorf("unknown orientation %q", orientation)
+	}
+}
+
+// staggerParams contains the sizes used to lay out staggered and hexagonal maps, like Tiled does
+type staggerParams struct {
+	tileWidth, tileHeight    float32
+	sideLengthX, sideLengthY float32
+	sideOffsetX, sideOffsetY float32
+	columnWidth, rowHeight   float32
+	staggerX, staggerEven    bool
+}
+
+func (lvl *Level) staggerParams() staggerParams {
+	p := staggerParams{
+		tileWidth:   float32(lvl.TileWidth &^ 1),
+		tileHeight:  float32(lvl.TileHeight &^ 1),
+		staggerX:    lvl.StaggerX,
+		staggerEven: lvl.StaggerEven,
+	}
+	if lvl.Orientation == Hexagonal {
+		if lvl.StaggerX {
+			p.sideLengthX = float32(lvl.HexSideLength)
+		} else {
+			p.sideLengthY = float32(lvl.HexSideLength)
+		}
+	}
+
+	p.sideOffsetX = (p.tileWidth - p.sideLengthX) / 2
+	p.sideOffsetY = (p.tileHeight - p.sideLengthY) / 2
+	p.columnWidth = p.sideOffsetX + p.sideLengthX
+	p.rowHeight = p.sideOffsetY + p.sideLengthY
+	return p
+}
+
+// staggered returns whether or not the row or column with the index is shifted
+func (p staggerParams) staggered(index int) bool {
+	return (index&1 != 0) != p.staggerEven
+}
+
+// TileToWorld returns the top-left corner of the box of TileWidth by TileHeight around the tile at (x, y), using
+// the coordinates of Tiled; for infinite maps, those start at StartX and StartY instead of 0
+func (lvl *Level) TileToWorld(x, y int) Point {
+	tw, th := float32(lvl.TileWidth), float32(lvl.TileHeight)
+
+	switch lvl.Orientation {
+	case Isometric:
+		return Point{float32(x-y+lvl.Height-1) * tw / 2, float32(x+y) * th / 2}
+	case Staggered, Hexagonal:
+		p := lvl.staggerParams()
+		if p.staggerX {
+			world := Point{float32(x) * p.columnWidth, float32(y) * (p.tileHeight + p.sideLengthY)}
+			if p.staggered(x) {
+				world.Y += p.rowHeight
+			}
+			return world
+		}
+
+		world := Point{float32(x) * (p.tileWidth + p.sideLengthX), float32(y) * p.rowHeight}
+		if p.staggered(y) {
+			world.X += p.columnWidth
+		}
+		return world
+	default:
+		return Point{float32(x) * tw, float32(y) * th}
+	}
+}
+
+// WorldToTile returns the coordinates of the tile at the point in the world, using the coordinates of Tiled. The
+// tile doesn't have to be within the Level.
+func (lvl *Level) WorldToTile(world Point) (int, int) {
+	tw, th := float32(lvl.TileWidth), float32(lvl.TileHeight)
+
+	switch lvl.Orientation {
+	case Isometric:
+		x := (world.X - float32(lvl.Height)*tw/2) / tw
+		y := world.Y / th
+		return int(math.Floor(y + x)), int(math.Floor(y - x))
+	case Staggered, Hexagonal:
+		return lvl.staggerParams().worldToTile(world, lvl.Orientation == Staggered)
+	default:
+		return int(math.Floor(world.X / tw)), int(math.Floor(world.Y / th))
+	}
+}
+
+// worldToTile finds the tile of which the center is nearest to the point, like Tiled does for hexagonal maps. The
+// tiles of staggered maps are diamonds, for which the distance is measured as if they were squares.
+func (p staggerParams) worldToTile(world Point, diamonds bool) (int, int) {
+	if p.staggerX {
+		if p.staggerEven {
+			world.X -= p.tileWidth
+		} else {
+			world.X -= p.sideOffsetX
+		}
+	} else {
+		if p.staggerEven {
+			world.Y -= p.tileHeight
+		} else {
+			world.Y -= p.sideOffsetY
+		}
+	}
+
+	// The tiles repeat every two columns and rows; the reference tile is the top-left one of those
+	refX := int(math.Floor(world.X / (p.columnWidth * 2)))
+	refY := int(math.Floor(world.Y / (p.rowHeight * 2)))
+	rel := Point{world.X - float32(refX)*p.columnWidth*2, world.Y - float32(refY)*p.rowHeight*2}
+
+	var centers [4]Point
+	var offsets [4][2]int
+	if p.staggerX {
+		refX *= 2
+		if p.staggerEven {
+			refX++
+		}
+
+		left := p.sideLengthX / 2
+		centerX := left + p.columnWidth
+		centerY := p.tileHeight / 2
+		centers = [4]Point{{left, centerY}, {centerX, centerY - p.rowHeight}, {centerX, centerY + p.rowHeight},
+			{centerX + p.columnWidth, centerY}}
+		offsets = [4][2]int{{0, 0}, {1, -1}, {1, 0}, {2, 0}}
+	} else {
+		refY *= 2
+		if p.staggerEven {
+			refY++
+		}
+
+		top := p.sideLengthY / 2
+		centerX := p.tileWidth / 2
+		centerY := top + p.rowHeight
+		centers = [4]Point{{centerX, top}, {centerX - p.columnWidth, centerY}, {centerX + p.columnWidth, centerY},
+			{centerX, centerY + p.rowHeight}}
+		offsets = [4][2]int{{0, 0}, {-1, 1}, {0, 1}, {0, 2}}
+	}
+
+	// Diamonds are squares which are squashed vertically, so the vertical distance is stretched back
+	scaleY := float32(1)
+	if diamonds {
+		scaleY = p.tileWidth / p.tileHeight
+	}
+
+	nearest := 0
+	var nearestDist float32
+	for i, c := range centers {
+		dx, dy := c.X-rel.X, (c.Y-rel.Y)*scaleY
+		if dist := dx*dx + dy*dy; i == 0 || dist < nearestDist {
+			nearest, nearestDist = i, dist
+		}
+	}
+
+	return refX + offsets[nearest][0], refY + offsets[nearest][1]
+}
+
+// Bounds returns the area covered by all tiles of the Level, in world coordinates
+func (lvl *Level) Bounds() AABB {
+	tw, th := float32(lvl.TileWidth), float32(lvl.TileHeight)
+	w, h := float32(lvl.Width), float32(lvl.Height)
+	startX, startY := float32(lvl.StartX), float32(lvl.StartY)
+
+	var min, size Point
+	switch lvl.Orientation {
+	case Isometric:
+		// The first tile is at the top, and the last tile of the first column is furthest to the left
+		origin := lvl.TileToWorld(lvl.StartX, lvl.StartY)
+		min = Point{origin.X - (h-1)*tw/2, origin.Y}
+		size = Point{(w + h) * tw / 2, (w + h) * th / 2}
+	case Staggered, Hexagonal:
+		p := lvl.staggerParams()
+		if p.staggerX {
+			min = Point{startX * p.columnWidth, startY * (p.tileHeight + p.sideLengthY)}
+			size = Point{w*p.columnWidth + p.sideOffsetX, h * (p.tileHeight + p.sideLengthY)}
+			if lvl.Width > 1 {
+				size.Y += p.rowHeight
+			}
+		} else {
+			min = Point{startX * (p.tileWidth + p.sideLengthX), startY * p.rowHeight}
+			size = Point{w * (p.tileWidth + p.sideLengthX), h*p.rowHeight + p.sideOffsetY}
+			if lvl.Height > 1 {
+				size.X += p.columnWidth
+			}
+		}
+	default:
+		min = Point{startX * tw, startY * th}
+		size = Point{w * tw, h * th}
+	}
+
+	return AABB{Min: min, Max: Point{min.X + size.X, min.Y + size.Y}}
+}
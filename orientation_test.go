@@ -0,0 +1,138 @@
+package engo
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"engo.io/ecs"
+	"github.com/luxengine/math"
+)
+
+// testRoundTrip checks that the center of every tile is converted back to that tile
+func testRoundTrip(t *testing.T, name string, lvl *Level) {
+	for y := -2; y < 6; y++ {
+		for x := -2; x < 6; x++ {
+			p := lvl.TileToWorld(x, y)
+			center := Point{p.X + float32(lvl.TileWidth)/2, p.Y + float32(lvl.TileHeight)/2}
+			if tx, ty := lvl.WorldToTile(center); tx != x || ty != y {
+				t.Errorf("%s: expected the center of %d,%d (%v) to be on that tile, got %d,%d", name, x, y, center,
+					tx, ty)
+			}
+		}
+	}
+}
+
+func TestOrientationTileToWorld(t *testing.T) {
+	levels := map[string]*Level{
+		"orthogonal":  {Width: 4, Height: 4, TileWidth: 16, TileHeight: 16},
+		"isometric":   {Width: 4, Height: 4, TileWidth: 64, TileHeight: 32, Orientation: Isometric},
+		"staggered y": {Width: 4, Height: 4, TileWidth: 64, TileHeight: 32, Orientation: Staggered},
+		"staggered x": {Width: 4, Height: 4, TileWidth: 64, TileHeight: 32, Orientation: Staggered,
+			StaggerX: true, StaggerEven: true},
+		"hexagonal y": {Width: 4, Height: 4, TileWidth: 32, TileHeight: 32, Orientation: Hexagonal,
+			HexSideLength: 16},
+		"hexagonal x": {Width: 4, Height: 4, TileWidth: 32, TileHeight: 28, Orientation: Hexagonal,
+			HexSideLength: 16, StaggerX: true, StaggerEven: true},
+	}
+
+	expected := map[string][]Point{
+		// The tiles at 0,0, 1,0, 0,1 and 1,1
+		"orthogonal":  {{0, 0}, {16, 0}, {0, 16}, {16, 16}},
+		"isometric":   {{96, 0}, {128, 16}, {64, 16}, {96, 32}},
+		"staggered y": {{0, 0}, {64, 0}, {32, 16}, {96, 16}},
+		"staggered x": {{0, 16}, {32, 0}, {0, 48}, {32, 32}},
+		"hexagonal y": {{0, 0}, {32, 0}, {16, 24}, {48, 24}},
+		"hexagonal x": {{0, 14}, {24, 0}, {0, 42}, {24, 28}},
+	}
+
+	for name, lvl := range levels {
+		cells := [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}}
+		for i, c := range cells {
+			if p := lvl.TileToWorld(c[0], c[1]); p != expected[name][i] {
+				t.Errorf("%s: expected tile %v at %v, got %v", name, c, expected[name][i], p)
+			}
+		}
+		testRoundTrip(t, name, lvl)
+	}
+}
+
+func TestOrientationBounds(t *testing.T) {
+	iso := &Level{Width: 4, Height: 2, TileWidth: 64, TileHeight: 32, Orientation: Isometric}
+	if b := iso.Bounds(); b != (AABB{Point{0, 0}, Point{192, 96}}) {
+		t.Errorf("Unexpected bounds of isometric map: %v", b)
+	}
+
+	// The bounds of infinite maps cover the tiles where TileToWorld puts them
+	infinite := &Level{Width: 4, Height: 2, TileWidth: 64, TileHeight: 32, Orientation: Isometric, StartX: -2, StartY: 1}
+	if b := infinite.Bounds(); b != (AABB{Point{-96, -16}, Point{96, 80}}) {
+		t.Errorf("Unexpected bounds of infinite isometric map: %v", b)
+	}
+	var tiles AABB
+	for y := 0; y < infinite.Height; y++ {
+		for x := 0; x < infinite.Width; x++ {
+			p := infinite.TileToWorld(x+infinite.StartX, y+infinite.StartY)
+			if x == 0 && y == 0 {
+				tiles = AABB{p, p}
+			}
+			tiles.Min = Point{math.Min(tiles.Min.X, p.X), math.Min(tiles.Min.Y, p.Y)}
+			tiles.Max = Point{math.Max(tiles.Max.X, p.X+64), math.Max(tiles.Max.Y, p.Y+32)}
+		}
+	}
+	if tiles != infinite.Bounds() {
+		t.Errorf("Expected the bounds to be %v, like the tiles", tiles)
+	}
+
+	hex := &Level{Width: 4, Height: 2, TileWidth: 32, TileHeight: 32, Orientation: Hexagonal, HexSideLength: 16}
+	if b := hex.Bounds(); b != (AABB{Point{0, 0}, Point{144, 56}}) {
+		t.Errorf("Unexpected bounds of hexagonal map: %v", b)
+	}
+}
+
+func TestIsometricLevel(t *testing.T) {
+	lvl := loadTestLevel(t, `<map orientation="isometric" width="2" height="2" tilewidth="16" tileheight="8">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16">
+  <image source="tiles.png" width="16" height="16"/>
+ </tileset>
+ <layer name="ground" width="2" height="2">
+  <data encoding="csv">1,1,1,1</data>
+ </layer>
+</map>`)
+
+	if lvl.Orientation != Isometric {
+		t.Fatalf("Expected an isometric map, got %v", lvl.Orientation)
+	}
+
+	// The tiles are 16 pixels high, so they stick out 8 pixels above their cell
+	expected := []Point{{8, -8}, {16, -4}, {0, -4}, {8, 0}}
+	for i, tl := range lvl.Tiles {
+		if tl.Point != expected[i] {
+			t.Errorf("Expected tile %d at %v, got %v", i, expected[i], tl.Point)
+		}
+	}
+
+	// The tiles in front are drawn last
+	headless = true
+	Mailbox = &MessageManager{}
+	w := &ecs.World{}
+	w.AddSystem(&RenderSystem{})
+	tms := &TileMapSystem{}
+	tms.New(w)
+	basic := ecs.NewBasic()
+	tms.Add(&basic, &TileMapComponent{Level: lvl})
+
+	d := tms.entities[0].layers[0].tiles
+	d.show(image.Rect(0, 0, 2, 2))
+	if !(d.depth[0] < d.depth[1] && d.depth[1] == d.depth[2] && d.depth[2] < d.depth[3]) {
+		t.Errorf("Unexpected depths: %v", d.depth)
+	}
+	if !reflect.DeepEqual(d.visible, []int{0, 1, 2, 3}) {
+		t.Errorf("Unexpected draw order: %v", d.visible)
+	}
+}
+
+func TestUnknownOrientation(t *testing.T) {
+	if _, err := parseOrientation("triangular"); err == nil {
+		t.Error("Expected an error for an unknown orientation")
+	}
+}
@@ -0,0 +1,564 @@
+package engo
+
+import (
+	"container/heap"
+	"image"
+
+	"github.com/luxengine/math"
+)
+
+// Graph is a navigation graph, of which the nodes are identified by an int. Grid is a Graph, but any other graph (like
+// a set of waypoints) can be searched with FindPath as well.
+type Graph interface {
+	// Neighbours returns the edges to the nodes that can be reached from the node directly
+	Neighbours(node int) []Edge
+	// Heuristic estimates the cost of the cheapest path from one node to another; it should never estimate more
+	// than the actual cost, or FindPath may not find the cheapest path
+	Heuristic(from, to int) float32
+}
+
+// Edge is a connection from a node of a Graph to node To, which costs Cost to travel
+type Edge struct {
+	To   int
+	Cost float32
+}
+
+// FindPath returns the cheapest path from start to goal (including both) and its cost, using the A* algorithm. When
+// goal can't be reached, ok is false. The result only depends on the Graph, so it's the same every time.
+func FindPath(g Graph, start, goal int) (path []int, cost float32, ok bool) {
+	open := &pathQueue{}
+	costs := map[int]float32{start: 0}
+	parents := make(map[int]int)
+	closed := make(map[int]bool)
+
+	open.add(start, 0, g.Heuristic(start, goal))
+	for open.Len() > 0 {
+		current := heap.Pop(open).(pathNode)
+		if closed[current.node] {
+			continue // because it was already reached in a cheaper way
+		}
+		if current.node == goal {
+			return tracePath(parents, start, goal), current.g, true
+		}
+		closed[current.node] = true
+
+		for _, e := range g.Neighbours(current.node) {
+			if closed[e.To] {
+				continue // with other edges
+			}
+
+			cost := current.g + e.Cost
+			if known, ok := costs[e.To]; ok && known <= cost {
+				continue // with other edges, because there's a cheaper path already
+			}
+			costs[e.To] = cost
+			parents[e.To] = current.node
+			open.add(e.To, cost, g.Heuristic(e.To, goal))
+		}
+	}
+
+	return nil, 0, false
+}
+
+// tracePath follows the parents back from goal to start, and returns the nodes from start to goal
+func tracePath(parents map[int]int, start, goal int) []int {
+	path := []int{goal}
+	for node := goal; node != start; {
+		node = parents[node]
+		path = append(path, node)
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// pathNode is a node in the open list of a search, with the cost to reach it (g) and the estimated remaining cost (h)
+type pathNode struct {
+	node int
+	g, h float32
+	seq  int // the order in which nodes were added, so ties are broken the same way every time
+}
+
+// pathQueue is the open list of a search, which returns the node with the lowest estimated total cost first
+type pathQueue struct {
+	nodes []pathNode
+	seq   int
+}
+
+func (q *pathQueue) add(node int, g, h float32) {
+	heap.Push(q, pathNode{node: node, g: g, h: h, seq: q.seq})
+	q.seq++
+}
+
+func (q *pathQueue) Len() int {
+	return len(q.nodes)
+}
+
+func (q *pathQueue) Less(i, j int) bool {
+	a, b := q.nodes[i], q.nodes[j]
+	if fa, fb := a.g+a.h, b.g+b.h; fa != fb {
+		return fa < fb
+	}
+	if a.h != b.h {
+		return a.h < b.h
+	}
+	return a.seq < b.seq
+}
+
+func (q *pathQueue) Swap(i, j int) {
+	q.nodes[i], q.nodes[j] = q.nodes[j], q.nodes[i]
+}
+
+func (q *pathQueue) Push(x interface{}) {
+	q.nodes = append(q.nodes, x.(pathNode))
+}
+
+func (q *pathQueue) Pop() interface{} {
+	last := q.nodes[len(q.nodes)-1]
+	q.nodes = q.nodes[:len(q.nodes)-1]
+	return last
+}
+
+// DiagonalMovement indicates when a Grid allows moving diagonally
+type DiagonalMovement uint8
+
+const (
+	// NoDiagonals only allows moving horizontally and vertically
+	NoDiagonals DiagonalMovement = iota
+	// DiagonalsWithoutCorners allows moving diagonally when both cells next to the diagonal are walkable, so paths
+	// never cut corners
+	DiagonalsWithoutCorners
+	// DiagonalsPastOneCorner allows moving diagonally when at least one of the cells next to the diagonal is walkable
+	DiagonalsPastOneCorner
+	// AllDiagonals allows moving diagonally whenever the cell that is moved to is walkable
+	AllDiagonals
+)
+
+// Grid is a Graph of cells in rows and columns. The node of the cell at (x, y) is x + y*Width.
+type Grid struct {
+	Width, Height int
+	// Costs contains the cost of entering each cell, row by row; cells with a cost of 0 or less aren't walkable.
+	// Moving diagonally costs the square root of 2 times as much.
+	Costs    []float32
+	Diagonal DiagonalMovement
+}
+
+// NewGrid creates a Grid of which all cells are walkable, with a cost of 1
+func NewGrid(width, height int, diagonal DiagonalMovement) *Grid {
+	g := &Grid{Width: width, Height: height, Costs: make([]float32, width*height), Diagonal: diagonal}
+	for i := range g.Costs {
+		g.Costs[i] = 1
+	}
+	return g
+}
+
+// Node returns the node of the cell at (x, y)
+func (g *Grid) Node(x, y int) int {
+	return x + y*g.Width
+}
+
+// Cell returns the location of the cell of the node
+func (g *Grid) Cell(node int) (int, int) {
+	return node % g.Width, node / g.Width
+}
+
+// Cost returns the cost of entering the cell at (x, y)
+func (g *Grid) Cost(x, y int) float32 {
+	if x < 0 || y < 0 || x >= g.Width || y >= g.Height {
+		return 0
+	}
+	return g.Costs[g.Node(x, y)]
+}
+
+// SetCost sets the cost of entering the cell at (x, y); a cost of 0 makes it unwalkable
+func (g *Grid) SetCost(x, y int, cost float32) {
+	g.Costs[g.Node(x, y)] = cost
+}
+
+// Walkable returns whether or not the cell at (x, y) exists, and can be entered
+func (g *Grid) Walkable(x, y int) bool {
+	return g.Cost(x, y) > 0
+}
+
+// gridDirections are the directions to the neighbours of a cell: first the straight ones, then the diagonal ones
+var gridDirections = [8]image.Point{{0, -1}, {1, 0}, {0, 1}, {-1, 0}, {1, -1}, {1, 1}, {-1, 1}, {-1, -1}}
+
+// canMove returns whether or not the move from (x, y) in the direction is allowed
+func (g *Grid) canMove(x, y int, dir image.Point) bool {
+	if !g.Walkable(x+dir.X, y+dir.Y) {
+		return false
+	}
+	if dir.X == 0 || dir.Y == 0 {
+		return true
+	}
+
+	horizontal, vertical := g.Walkable(x+dir.X, y), g.Walkable(x, y+dir.Y)
+	switch g.Diagonal {
+	case DiagonalsWithoutCorners:
+		return horizontal && vertical
+	case DiagonalsPastOneCorner:
+		return horizontal || vertical
+	case AllDiagonals:
+		return true
+	default:
+		return false
+	}
+}
+
+func (g *Grid) Neighbours(node int) []Edge {
+	x, y := g.Cell(node)
+
+	edges := make([]Edge, 0, 8)
+	for _, dir := range gridDirections {
+		if !g.canMove(x, y, dir) {
+			continue // with other directions
+		}
+
+		cost := g.Cost(x+dir.X, y+dir.Y)
+		if dir.X != 0 && dir.Y != 0 {
+			cost *= math.Sqrt2
+		}
+		edges = append(edges, Edge{To: g.Node(x+dir.X, y+dir.Y), Cost: cost})
+	}
+	return edges
+}
+
+// Heuristic returns the distance between the cells when all cells would have the lowest cost of the Grid. It looks
+// at all costs every time; Grid.FindPath only does so once.
+func (g *Grid) Heuristic(from, to int) float32 {
+	return gridGraph{g, g.minCost()}.Heuristic(from, to)
+}
+
+// gridGraph is a Grid of which the lowest cost is known, so the heuristic doesn't have to look it up
+type gridGraph struct {
+	*Grid
+	min float32
+}
+
+func (g gridGraph) Heuristic(from, to int) float32 {
+	x1, y1 := g.Cell(from)
+	x2, y2 := g.Cell(to)
+	return g.distance(x1, y1, x2, y2) * g.min
+}
+
+// distance returns the number of steps between the cells, where diagonal steps count as the square root of 2
+func (g *Grid) distance(x1, y1, x2, y2 int) float32 {
+	dx := math.Abs(float32(x2 - x1))
+	dy := math.Abs(float32(y2 - y1))
+	if g.Diagonal == NoDiagonals {
+		return dx + dy
+	}
+	return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+}
+
+// minCost returns the lowest cost of entering a walkable cell
+func (g *Grid) minCost() float32 {
+	min := float32(0)
+	for _, c := range g.Costs {
+		if c > 0 && (min == 0 || c < min) {
+			min = c
+		}
+	}
+	return min
+}
+
+// FindPath returns the cells of the cheapest path from (x1, y1) to (x2, y2), including both, using A*
+func (g *Grid) FindPath(x1, y1, x2, y2 int) ([]image.Point, bool) {
+	if !g.Walkable(x1, y1) || !g.Walkable(x2, y2) {
+		return nil, false
+	}
+
+	nodes, _, ok := FindPath(gridGraph{g, g.minCost()}, g.Node(x1, y1), g.Node(x2, y2))
+	if !ok {
+		return nil, false
+	}
+
+	path := make([]image.Point, len(nodes))
+	for i, n := range nodes {
+		x, y := g.Cell(n)
+		path[i] = image.Pt(x, y)
+	}
+	return path, true
+}
+
+// FindPathJPS returns the cells of a shortest path from (x1, y1) to (x2, y2), including both, using Jump Point
+// Search. It's much faster than FindPath on large open areas, but it only works when all walkable cells have the same
+// cost, and moving diagonally is either not allowed or DiagonalsWithoutCorners; otherwise, FindPath is used instead.
+// The path may differ from the one FindPath finds, but it's just as short.
+func (g *Grid) FindPathJPS(x1, y1, x2, y2 int) ([]image.Point, bool) {
+	if !g.uniform() || (g.Diagonal != NoDiagonals && g.Diagonal != DiagonalsWithoutCorners) {
+		return g.FindPath(x1, y1, x2, y2)
+	}
+	if !g.Walkable(x1, y1) || !g.Walkable(x2, y2) {
+		return nil, false
+	}
+
+	s := &jumpSearch{grid: g, goal: image.Pt(x2, y2)}
+	points, ok := s.find(image.Pt(x1, y1))
+	if !ok {
+		return nil, false
+	}
+
+	// Jump points are connected by straight or diagonal lines, which are filled in
+	path := []image.Point{points[0]}
+	for _, p := range points[1:] {
+		for cur := path[len(path)-1]; cur != p; {
+			cur = cur.Add(image.Pt(sign(p.X-cur.X), sign(p.Y-cur.Y)))
+			path = append(path, cur)
+		}
+	}
+	return path, true
+}
+
+// uniform returns whether or not all walkable cells have the same cost
+func (g *Grid) uniform() bool {
+	min := g.minCost()
+	for _, c := range g.Costs {
+		if c > 0 && c != min {
+			return false
+		}
+	}
+	return true
+}
+
+// jumpSearch is a single Jump Point Search on a Grid
+type jumpSearch struct {
+	grid *Grid
+	goal image.Point
+}
+
+// find returns the jump points of the shortest path from start to the goal
+func (s *jumpSearch) find(start image.Point) ([]image.Point, bool) {
+	g := s.grid
+	startNode, goalNode := g.Node(start.X, start.Y), g.Node(s.goal.X, s.goal.Y)
+
+	open := &pathQueue{}
+	costs := map[int]float32{startNode: 0}
+	parents := make(map[int]int)
+	closed := make(map[int]bool)
+
+	open.add(startNode, 0, g.distance(start.X, start.Y, s.goal.X, s.goal.Y))
+	for open.Len() > 0 {
+		current := heap.Pop(open).(pathNode)
+		if closed[current.node] {
+			continue // because it was already reached in a cheaper way
+		}
+		if current.node == goalNode {
+			nodes := tracePath(parents, startNode, goalNode)
+			points := make([]image.Point, len(nodes))
+			for i, n := range nodes {
+				x, y := g.Cell(n)
+				points[i] = image.Pt(x, y)
+			}
+			return points, true
+		}
+		closed[current.node] = true
+
+		x, y := g.Cell(current.node)
+		parent, hasParent := image.Point{}, false
+		if p, ok := parents[current.node]; ok {
+			px, py := g.Cell(p)
+			parent, hasParent = image.Pt(px, py), true
+		}
+
+		for _, n := range s.neighbours(image.Pt(x, y), parent, hasParent) {
+			jump, ok := s.jump(n, image.Pt(x, y))
+			if !ok {
+				continue // with other neighbours
+			}
+
+			node := g.Node(jump.X, jump.Y)
+			if closed[node] {
+				continue // with other neighbours
+			}
+
+			cost := current.g + g.distance(x, y, jump.X, jump.Y)
+			if known, ok := costs[node]; ok && known <= cost {
+				continue // with other neighbours, because there's a shorter path already
+			}
+			costs[node] = cost
+			parents[node] = current.node
+			open.add(node, cost, g.distance(jump.X, jump.Y, s.goal.X, s.goal.Y))
+		}
+	}
+
+	return nil, false
+}
+
+// neighbours returns the cells worth looking at from p, when it was reached from parent
+func (s *jumpSearch) neighbours(p, parent image.Point, hasParent bool) []image.Point {
+	g := s.grid
+	var cells []image.Point
+	add := func(x, y int) {
+		if g.Walkable(x, y) {
+			cells = append(cells, image.Pt(x, y))
+		}
+	}
+
+	if !hasParent {
+		for _, dir := range gridDirections {
+			if g.canMove(p.X, p.Y, dir) {
+				cells = append(cells, p.Add(dir))
+			}
+		}
+		return cells
+	}
+
+	dx, dy := sign(p.X-parent.X), sign(p.Y-parent.Y)
+	if g.Diagonal == NoDiagonals {
+		if dx != 0 {
+			add(p.X, p.Y-1)
+			add(p.X, p.Y+1)
+			add(p.X+dx, p.Y)
+		} else {
+			add(p.X-1, p.Y)
+			add(p.X+1, p.Y)
+			add(p.X, p.Y+dy)
+		}
+		return cells
+	}
+
+	if dx != 0 && dy != 0 {
+		horizontal, vertical := g.Walkable(p.X+dx, p.Y), g.Walkable(p.X, p.Y+dy)
+		add(p.X, p.Y+dy)
+		add(p.X+dx, p.Y)
+		if horizontal && vertical {
+			add(p.X+dx, p.Y+dy)
+		}
+	} else if dx != 0 {
+		next, up, down := g.Walkable(p.X+dx, p.Y), g.Walkable(p.X, p.Y-1), g.Walkable(p.X, p.Y+1)
+		if next {
+			add(p.X+dx, p.Y)
+			if up {
+				add(p.X+dx, p.Y-1)
+			}
+			if down {
+				add(p.X+dx, p.Y+1)
+			}
+		}
+		add(p.X, p.Y-1)
+		add(p.X, p.Y+1)
+	} else {
+		next, left, right := g.Walkable(p.X, p.Y+dy), g.Walkable(p.X-1, p.Y), g.Walkable(p.X+1, p.Y)
+		if next {
+			add(p.X, p.Y+dy)
+			if left {
+				add(p.X-1, p.Y+dy)
+			}
+			if right {
+				add(p.X+1, p.Y+dy)
+			}
+		}
+		add(p.X-1, p.Y)
+		add(p.X+1, p.Y)
+	}
+	return cells
+}
+
+// jump moves from parent through p in the same direction, until it finds a jump point: the goal, or a cell with a
+// neighbour that can only be reached (in the shortest way) through it
+func (s *jumpSearch) jump(p, parent image.Point) (image.Point, bool) {
+	g := s.grid
+	dx, dy := p.X-parent.X, p.Y-parent.Y
+
+	for {
+		if !g.Walkable(p.X, p.Y) {
+			return image.Point{}, false
+		}
+		if p == s.goal {
+			return p, true
+		}
+
+		switch {
+		case dx != 0 && dy != 0:
+			// Moving diagonally, the cell is a jump point when a straight jump from it finds one
+			if _, ok := s.jump(image.Pt(p.X+dx, p.Y), p); ok {
+				return p, true
+			}
+			if _, ok := s.jump(image.Pt(p.X, p.Y+dy), p); ok {
+				return p, true
+			}
+			if !g.Walkable(p.X+dx, p.Y) || !g.Walkable(p.X, p.Y+dy) {
+				return image.Point{}, false // because corners can't be cut
+			}
+		case dx != 0:
+			if (g.Walkable(p.X, p.Y-1) && !g.Walkable(p.X-dx, p.Y-1)) ||
+				(g.Walkable(p.X, p.Y+1) && !g.Walkable(p.X-dx, p.Y+1)) {
+				return p, true
+			}
+		default:
+			if (g.Walkable(p.X-1, p.Y) && !g.Walkable(p.X-1, p.Y-dy)) ||
+				(g.Walkable(p.X+1, p.Y) && !g.Walkable(p.X+1, p.Y-dy)) {
+				return p, true
+			}
+			if g.Diagonal == NoDiagonals {
+				// Without diagonals, turning is only found by looking sideways while moving vertically
+				if _, ok := s.jump(image.Pt(p.X+1, p.Y), p); ok {
+					return p, true
+				}
+				if _, ok := s.jump(image.Pt(p.X-1, p.Y), p); ok {
+					return p, true
+				}
+			}
+		}
+
+		p = image.Pt(p.X+dx, p.Y+dy)
+	}
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
+
+// NavigationGrid creates a Grid from the cells of the Level. The cells that are solid (see SolidCells) aren't
+// walkable; the others cost 1 to enter, unless a tile on the cell has a float property called costProperty, of
+// which the highest one is used. The Grid uses the coordinates of the cells (starting at 0, even for infinite
+// maps), so it's suitable for orthogonal and isometric maps.
+func (lvl *Level) NavigationGrid(layer, property, costProperty string, diagonal DiagonalMovement) *Grid {
+	g := NewGrid(lvl.Width, lvl.Height, diagonal)
+
+	if costProperty != "" {
+		for i := range g.Costs {
+			g.Costs[i] = 0
+		}
+		for _, l := range lvl.TileLayers {
+			for i, t := range l.Tiles {
+				if i < len(g.Costs) && t.Info != nil {
+					g.Costs[i] = math.Max(g.Costs[i], t.Info.Properties.Float(costProperty))
+				}
+			}
+		}
+		for i, c := range g.Costs {
+			if c <= 0 {
+				g.Costs[i] = 1
+			}
+		}
+	}
+
+	for i, solid := range lvl.SolidCells(layer, property) {
+		if solid {
+			g.Costs[i] = 0
+		}
+	}
+
+	return g
+}
+
+// PathToWorld returns the centers of the cells of a path found on the NavigationGrid of the Level, in world
+// coordinates
+func (lvl *Level) PathToWorld(path []image.Point) []Point {
+	points := make([]Point, len(path))
+	for i, cell := range path {
+		p := lvl.TileToWorld(cell.X+lvl.StartX, cell.Y+lvl.StartY)
+		points[i] = Point{p.X + float32(lvl.TileWidth)/2, p.Y + float32(lvl.TileHeight)/2}
+	}
+	return points
+}
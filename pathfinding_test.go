@@ -0,0 +1,211 @@
+package engo
+
+import (
+	"image"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/luxengine/math"
+)
+
+// checkGridPath fails when the path doesn't go from start to goal in moves the Grid allows, and returns its cost
+func checkGridPath(t *testing.T, g *Grid, path []image.Point, start, goal image.Point) float32 {
+	if len(path) == 0 || path[0] != start || path[len(path)-1] != goal {
+		t.Fatalf("Expected a path from %v to %v, got %v", start, goal, path)
+	}
+
+	var cost float32
+	for i := 1; i < len(path); i++ {
+		dir := path[i].Sub(path[i-1])
+		if dir.X < -1 || dir.X > 1 || dir.Y < -1 || dir.Y > 1 || dir == (image.Point{}) {
+			t.Fatalf("Unexpected move from %v to %v", path[i-1], path[i])
+		}
+		if !g.canMove(path[i-1].X, path[i-1].Y, dir) {
+			t.Fatalf("Moving from %v to %v isn't allowed", path[i-1], path[i])
+		}
+		cost += g.distance(path[i-1].X, path[i-1].Y, path[i].X, path[i].Y) * g.Cost(path[i].X, path[i].Y)
+	}
+	return cost
+}
+
+func TestGridFindPath(t *testing.T) {
+	// A wall at x=2, with a gap at the bottom
+	g := NewGrid(5, 5, NoDiagonals)
+	for y := 0; y < 4; y++ {
+		g.SetCost(2, y, 0)
+	}
+
+	path, ok := g.FindPath(0, 0, 4, 0)
+	if !ok {
+		t.Fatal("Expected a path")
+	}
+	if cost := checkGridPath(t, g, path, image.Pt(0, 0), image.Pt(4, 0)); cost != 12 {
+		t.Errorf("Expected a path of 12 moves, got %v: %v", cost, path)
+	}
+
+	g.SetCost(2, 4, 0)
+	if path, ok := g.FindPath(0, 0, 4, 0); ok {
+		t.Errorf("Expected no path through the closed wall, got %v", path)
+	}
+	if _, ok := g.FindPath(0, 0, 2, 0); ok {
+		t.Error("Expected no path to a cell that isn't walkable")
+	}
+}
+
+func TestGridDiagonalMovement(t *testing.T) {
+	tests := []struct {
+		diagonal DiagonalMovement
+		blocked  []image.Point
+		length   int // 0 when there's no path
+	}{
+		{NoDiagonals, nil, 3},
+		{DiagonalsWithoutCorners, nil, 2},
+		{DiagonalsWithoutCorners, []image.Point{{1, 0}}, 3},
+		{DiagonalsPastOneCorner, []image.Point{{1, 0}}, 2},
+		{DiagonalsPastOneCorner, []image.Point{{1, 0}, {0, 1}}, 0},
+		{AllDiagonals, []image.Point{{1, 0}, {0, 1}}, 2},
+	}
+
+	for _, test := range tests {
+		g := NewGrid(2, 2, test.diagonal)
+		for _, b := range test.blocked {
+			g.SetCost(b.X, b.Y, 0)
+		}
+
+		path, ok := g.FindPath(0, 0, 1, 1)
+		if len(path) != test.length || ok != (test.length > 0) {
+			t.Errorf("Expected a path of %d cells with diagonal movement %d and %v blocked, got %v",
+				test.length, test.diagonal, test.blocked, path)
+		}
+	}
+}
+
+func TestGridCosts(t *testing.T) {
+	g := NewGrid(3, 3, NoDiagonals)
+	g.SetCost(1, 1, 10)
+
+	// Going around the expensive cell takes 4 moves, instead of 2 moves costing 11
+	path, _ := g.FindPath(0, 1, 2, 1)
+	if cost := checkGridPath(t, g, path, image.Pt(0, 1), image.Pt(2, 1)); cost != 4 {
+		t.Errorf("Expected the path around the expensive cell, got %v", path)
+	}
+
+	g.SetCost(1, 1, 2)
+	path, _ = g.FindPath(0, 1, 2, 1)
+	if cost := checkGridPath(t, g, path, image.Pt(0, 1), image.Pt(2, 1)); cost != 3 || len(path) != 3 {
+		t.Errorf("Expected the path through the cheaper cell, got %v", path)
+	}
+}
+
+func TestGridFindPathDeterministic(t *testing.T) {
+	// On an open grid there are many paths of the same length; the same one must be found every time
+	g := NewGrid(16, 16, DiagonalsWithoutCorners)
+	first, _ := g.FindPath(0, 0, 15, 9)
+	for i := 0; i < 10; i++ {
+		if path, _ := g.FindPath(0, 0, 15, 9); !reflect.DeepEqual(path, first) {
+			t.Fatalf("Expected the same path every time, got %v and %v", first, path)
+		}
+	}
+}
+
+func TestGridFindPathJPS(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, diagonal := range []DiagonalMovement{NoDiagonals, DiagonalsWithoutCorners} {
+		for i := 0; i < 50; i++ {
+			g := NewGrid(20, 15, diagonal)
+			for j := range g.Costs {
+				if r.Intn(10) < 3 {
+					g.Costs[j] = 0
+				}
+			}
+			start := image.Pt(r.Intn(g.Width), r.Intn(g.Height))
+			goal := image.Pt(r.Intn(g.Width), r.Intn(g.Height))
+			g.SetCost(start.X, start.Y, 1)
+			g.SetCost(goal.X, goal.Y, 1)
+
+			astar, ok := g.FindPath(start.X, start.Y, goal.X, goal.Y)
+			jps, jpsOK := g.FindPathJPS(start.X, start.Y, goal.X, goal.Y)
+			if ok != jpsOK {
+				t.Fatalf("A* found a path: %v, but JPS: %v", ok, jpsOK)
+			}
+			if !ok {
+				continue // with other grids
+			}
+
+			expected := checkGridPath(t, g, astar, start, goal)
+			if cost := checkGridPath(t, g, jps, start, goal); math.Abs(cost-expected) > 0.001 {
+				t.Fatalf("Expected JPS to find a path of %v like A*, got %v: %v", expected, cost, jps)
+			}
+		}
+	}
+}
+
+// waypoints is a Graph of named locations, connected by roads of a certain length
+type waypoints struct {
+	positions []Point
+	roads     map[int][]Edge
+}
+
+func (w waypoints) Neighbours(node int) []Edge {
+	return w.roads[node]
+}
+
+func (w waypoints) Heuristic(from, to int) float32 {
+	return w.positions[from].PointDistance(w.positions[to])
+}
+
+func TestFindPathGraph(t *testing.T) {
+	w := waypoints{
+		positions: []Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}},
+		roads: map[int][]Edge{
+			0: {{To: 1, Cost: 10}, {To: 3, Cost: 30}},
+			1: {{To: 2, Cost: 10}},
+			3: {{To: 2, Cost: 10}},
+		},
+	}
+
+	path, cost, ok := FindPath(w, 0, 2)
+	if !ok || cost != 20 || !reflect.DeepEqual(path, []int{0, 1, 2}) {
+		t.Errorf("Expected path [0 1 2] of 20, got %v of %v", path, cost)
+	}
+
+	// Roads are one-way
+	if path, _, ok := FindPath(w, 2, 0); ok {
+		t.Errorf("Expected no path back, got %v", path)
+	}
+}
+
+// navigationTestLevel is 4x3 tiles of 8x8: tile 2 is mud, which costs 3, and tile 3 is a solid wall
+const navigationTestLevel = `<map width="4" height="3" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" name="tiles" tilewidth="8" tileheight="8">
+  <image source="tiles.png" width="16" height="16"/>
+  <tile id="1"><properties><property name="cost" type="float" value="3"/></properties></tile>
+  <tile id="2"><properties><property name="solid" type="bool" value="true"/></properties></tile>
+ </tileset>
+ <layer name="ground" width="4" height="3">
+  <data encoding="csv">1,2,1,1,1,3,3,1,1,1,1,1</data>
+ </layer>
+</map>`
+
+func TestLevelNavigationGrid(t *testing.T) {
+	lvl := loadTestLevel(t, navigationTestLevel)
+
+	g := lvl.NavigationGrid("", "solid", "cost", NoDiagonals)
+	expected := []float32{1, 3, 1, 1, 1, 0, 0, 1, 1, 1, 1, 1}
+	if !reflect.DeepEqual(g.Costs, expected) {
+		t.Fatalf("Expected costs %v, got %v", expected, g.Costs)
+	}
+
+	// Around the wall through the mud costs 7, along the bottom only 5
+	path, ok := g.FindPath(0, 1, 3, 1)
+	if !ok || checkGridPath(t, g, path, image.Pt(0, 1), image.Pt(3, 1)) != 5 {
+		t.Errorf("Expected the path along the bottom, got %v", path)
+	}
+
+	world := lvl.PathToWorld(path[:2])
+	if world[0] != (Point{4, 12}) || world[1] != (Point{4, 20}) {
+		t.Errorf("Expected the centers of the cells, got %v", world)
+	}
+}
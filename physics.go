@@ -0,0 +1,263 @@
+package engo
+
+import (
+	"engo.io/ecs"
+	"github.com/luxengine/math"
+)
+
+const (
+	// PhysicsSystemPriority makes sure the PhysicsSystem moves entities before the CollisionSystem checks them
+	PhysicsSystemPriority = 5
+
+	// DefaultTimestep is the fixed timestep used by the PhysicsSystem when none was set
+	DefaultTimestep float32 = 1.0 / 60
+)
+
+// BodyType indicates how an entity is affected by the PhysicsSystem
+type BodyType uint8
+
+const (
+	// DynamicBody is moved by its velocity, by forces and by gravity, and is pushed around by collisions
+	DynamicBody BodyType = iota
+	// KinematicBody is only moved by its velocity; it pushes dynamic bodies around, but is not affected by them
+	KinematicBody
+	// StaticBody never moves
+	StaticBody
+)
+
+// PhysicsComponent contains the physical properties of an entity, which are used by the PhysicsSystem
+type PhysicsComponent struct {
+	Type BodyType
+
+	Velocity     Point
+	Acceleration Point
+
+	// Mass is the mass of a dynamic body; when zero, a mass of 1 is used
+	Mass float32
+	// Restitution is the bounciness, where 0 means no bounce and 1 means a perfectly elastic bounce
+	Restitution float32
+	// Friction slows down the movement along the surface of a collision, usually between 0 and 1
+	Friction float32
+	// GravityScale is multiplied with the Gravity of the PhysicsSystem; when zero, the body has no gravity
+	GravityScale float32
+
+	force Point // the sum of the forces applied since the last step
+}
+
+// NewPhysicsComponent creates a PhysicsComponent of the given type and mass, which is affected by gravity
+func NewPhysicsComponent(bodyType BodyType, mass float32) PhysicsComponent {
+	return PhysicsComponent{
+		Type:         bodyType,
+		Mass:         mass,
+		Friction:     0.2,
+		GravityScale: 1,
+	}
+}
+
+// ApplyForce applies the force to the body, during the next step of the PhysicsSystem
+func (p *PhysicsComponent) ApplyForce(force Point) {
+	p.force.Add(force)
+}
+
+// ApplyImpulse immediately changes the velocity of a dynamic body, according to its mass
+func (p *PhysicsComponent) ApplyImpulse(impulse Point) {
+	invMass := p.inverseMass()
+	p.Velocity.X += impulse.X * invMass
+	p.Velocity.Y += impulse.Y * invMass
+}
+
+// inverseMass returns 1 / Mass for dynamic bodies, and 0 (infinite mass) for others
+func (p *PhysicsComponent) inverseMass() float32 {
+	if p == nil || p.Type != DynamicBody {
+		return 0
+	}
+	if p.Mass <= 0 {
+		return 1
+	}
+	return 1 / p.Mass
+}
+
+type physicsEntity struct {
+	*ecs.BasicEntity
+	*PhysicsComponent
+	*SpaceComponent
+}
+
+// PhysicsSystem moves entities according to their PhysicsComponent, on a fixed timestep. When a CollisionSystem is
+// used as well, the PhysicsSystem changes the velocities of colliding bodies so they bounce off each other, while
+// the CollisionSystem keeps them from overlapping.
+type PhysicsSystem struct {
+	// Gravity is the acceleration that is applied to every dynamic body, multiplied by its GravityScale
+	Gravity Point
+	// Timestep is the fixed duration of a single step, in seconds; it defaults to DefaultTimestep
+	Timestep float32
+	// MaxSteps is the maximum number of steps per frame, so a slow frame can't make the next one even slower; when
+	// zero, there is no maximum
+	MaxSteps int
+
+	entities    []physicsEntity
+	indices     map[uint64]int
+	accumulator float32
+}
+
+func (*PhysicsSystem) Priority() int { return PhysicsSystemPriority }
+
+func (p *PhysicsSystem) New(*ecs.World) {
+	Mailbox.Listen("CollisionMessage", func(msg Message) {
+		collision, ok := msg.(CollisionMessage)
+		if !ok {
+			return
+		}
+
+		p.resolve(collision)
+	})
+}
+
+func (p *PhysicsSystem) Add(basic *ecs.BasicEntity, physics *PhysicsComponent, space *SpaceComponent) {
+	if p.indices == nil {
+		p.indices = make(map[uint64]int)
+	}
+
+	p.indices[basic.ID()] = len(p.entities)
+	p.entities = append(p.entities, physicsEntity{basic, physics, space})
+}
+
+func (p *PhysicsSystem) Remove(basic ecs.BasicEntity) {
+	delete := -1
+	for index, e := range p.entities {
+		if e.BasicEntity.ID() == basic.ID() {
+			delete = index
+			break
+		}
+	}
+	if delete >= 0 {
+		p.entities = append(p.entities[:delete], p.entities[delete+1:]...)
+
+		p.indices = make(map[uint64]int, len(p.entities))
+		for index, e := range p.entities {
+			p.indices[e.BasicEntity.ID()] = index
+		}
+	}
+}
+
+func (p *PhysicsSystem) Update(dt float32) {
+	step := p.Timestep
+	if step <= 0 {
+		step = DefaultTimestep
+	}
+
+	p.accumulator += dt
+	steps := 0
+	for p.accumulator >= step {
+		if p.MaxSteps > 0 && steps >= p.MaxSteps {
+			p.accumulator = 0
+			break
+		}
+
+		p.step(step)
+		p.accumulator -= step
+		steps++
+	}
+
+	if steps > 0 {
+		for _, e := range p.entities {
+			e.PhysicsComponent.force = Point{}
+		}
+	}
+}
+
+// step integrates the motion of all bodies, using semi-implicit Euler integration
+func (p *PhysicsSystem) step(dt float32) {
+	for _, e := range p.entities {
+		body := e.PhysicsComponent
+
+		switch body.Type {
+		case StaticBody:
+			continue // with other entities
+		case DynamicBody:
+			invMass := body.inverseMass()
+			body.Velocity.X += (body.Acceleration.X + p.Gravity.X*body.GravityScale + body.force.X*invMass) * dt
+			body.Velocity.Y += (body.Acceleration.Y + p.Gravity.Y*body.GravityScale + body.force.Y*invMass) * dt
+		}
+
+		e.SpaceComponent.Position.X += body.Velocity.X * dt
+		e.SpaceComponent.Position.Y += body.Velocity.Y * dt
+	}
+}
+
+// body returns the PhysicsComponent of the entity, or nil if the PhysicsSystem doesn't know about it
+func (p *PhysicsSystem) body(basic *ecs.BasicEntity) *PhysicsComponent {
+	if index, ok := p.indices[basic.ID()]; ok {
+		return p.entities[index].PhysicsComponent
+	}
+	return nil
+}
+
+// resolve applies the impulses that make two colliding bodies bounce off each other. Entities without a
+// PhysicsComponent are treated like static bodies.
+func (p *PhysicsSystem) resolve(collision CollisionMessage) {
+	if !collision.Entity.CollisionComponent.SolidAgainst(collision.To.CollisionComponent) {
+		return
+	}
+
+	normal := collision.Normal
+	if normal.X == 0 && normal.Y == 0 {
+		return
+	}
+
+	a := p.body(collision.Entity.BasicEntity)
+	b := p.body(collision.To.BasicEntity)
+	invMassA, invMassB := a.inverseMass(), b.inverseMass()
+	if invMassA+invMassB == 0 {
+		return
+	}
+
+	var velocityA, velocityB Point
+	restitution, friction := float32(0), float32(0)
+	switch {
+	case a != nil && b != nil:
+		velocityA, velocityB = a.Velocity, b.Velocity
+		restitution = math.Min(a.Restitution, b.Restitution)
+		friction = math.Sqrt(a.Friction * b.Friction)
+	case a != nil:
+		velocityA = a.Velocity
+		restitution, friction = a.Restitution, a.Friction
+	default:
+		velocityB = b.Velocity
+		restitution, friction = b.Restitution, b.Friction
+	}
+
+	relative := velocityA
+	relative.Subtract(velocityB)
+	normalSpeed := relative.X*normal.X + relative.Y*normal.Y
+	if normalSpeed >= 0 {
+		return // because they're already moving apart
+	}
+
+	j := -(1 + restitution) * normalSpeed / (invMassA + invMassB)
+	impulse := Point{normal.X * j, normal.Y * j}
+
+	// Friction works along the tangent of the collision, and can't be stronger than the normal impulse allows
+	tangent := Point{relative.X - normal.X*normalSpeed, relative.Y - normal.Y*normalSpeed}
+	if tangent.X != 0 || tangent.Y != 0 {
+		tangent, _ = tangent.Normalize()
+		jt := -(relative.X*tangent.X + relative.Y*tangent.Y) / (invMassA + invMassB)
+		maxFriction := j * friction
+		if jt > maxFriction {
+			jt = maxFriction
+		} else if jt < -maxFriction {
+			jt = -maxFriction
+		}
+		impulse.X += tangent.X * jt
+		impulse.Y += tangent.Y * jt
+	}
+
+	if a != nil {
+		a.Velocity.X += impulse.X * invMassA
+		a.Velocity.Y += impulse.Y * invMassA
+	}
+	if b != nil {
+		b.Velocity.X -= impulse.X * invMassB
+		b.Velocity.Y -= impulse.Y * invMassB
+	}
+}
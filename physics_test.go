@@ -0,0 +1,92 @@
+package engo
+
+import (
+	"testing"
+
+	"engo.io/ecs"
+)
+
+type physicsTestEntity struct {
+	ecs.BasicEntity
+	CollisionComponent
+	PhysicsComponent
+	SpaceComponent
+}
+
+func TestPhysicsFixedTimestep(t *testing.T) {
+	Mailbox = &MessageManager{}
+	sys := &PhysicsSystem{Gravity: Point{0, 10}, Timestep: 0.5}
+	sys.New(nil)
+
+	body := &physicsTestEntity{BasicEntity: ecs.NewBasic()}
+	body.PhysicsComponent = NewPhysicsComponent(DynamicBody, 2)
+	sys.Add(&body.BasicEntity, &body.PhysicsComponent, &body.SpaceComponent)
+
+	sys.Update(0.4)
+	if body.Position != (Point{}) {
+		t.Errorf("Expected no step before a full timestep has passed, got %v", body.Position)
+	}
+
+	sys.Update(0.6)
+	if body.Velocity != (Point{0, 10}) {
+		t.Errorf("Expected two steps to result in velocity (0, 10), got %v", body.Velocity)
+	}
+	if body.Position != (Point{0, 7.5}) {
+		t.Errorf("Expected two steps to result in position (0, 7.5), got %v", body.Position)
+	}
+
+	body.ApplyForce(Point{4, 0})
+	sys.Update(0.5)
+	if body.Velocity.X != 1 {
+		t.Errorf("Expected a force of 4 on a mass of 2 for 0.5 seconds to result in velocity 1, got %v", body.Velocity.X)
+	}
+
+	sys.Update(0.5)
+	if body.Velocity.X != 1 {
+		t.Errorf("Expected the force to be applied only once, got velocity %v", body.Velocity.X)
+	}
+}
+
+func TestPhysicsBounce(t *testing.T) {
+	Mailbox = &MessageManager{}
+	physics := &PhysicsSystem{Gravity: Point{0, 100}}
+	physics.New(nil)
+	collision := &CollisionSystem{}
+
+	ball := &physicsTestEntity{BasicEntity: ecs.NewBasic()}
+	ball.SpaceComponent = SpaceComponent{Position: Point{0, 0}, Width: 10, Height: 10}
+	ball.CollisionComponent = CollisionComponent{Main: true, Solid: true}
+	ball.PhysicsComponent = NewPhysicsComponent(DynamicBody, 1)
+	ball.Restitution = 1
+	ball.Friction = 0
+
+	floor := &physicsTestEntity{BasicEntity: ecs.NewBasic()}
+	floor.SpaceComponent = SpaceComponent{Position: Point{-50, 100}, Width: 100, Height: 10}
+	floor.CollisionComponent = CollisionComponent{Solid: true}
+
+	for _, e := range []*physicsTestEntity{ball, floor} {
+		collision.Add(&e.BasicEntity, &e.CollisionComponent, &e.SpaceComponent)
+	}
+	physics.Add(&ball.BasicEntity, &ball.PhysicsComponent, &ball.SpaceComponent)
+
+	bounced := false
+	for i := 0; i < 120; i++ {
+		physics.Update(DefaultTimestep)
+		collision.Update(DefaultTimestep)
+
+		if ball.Position.Y+ball.Height > floor.Position.Y+0.001 {
+			t.Fatalf("Expected the ball to stay above the floor, got %v", ball.Position)
+		}
+		if ball.Velocity.Y < 0 {
+			bounced = true
+			break
+		}
+	}
+
+	if !bounced {
+		t.Error("Expected the ball to bounce off the floor")
+	}
+	if ball.Velocity.Y > -100 {
+		t.Errorf("Expected the bounce to keep most of the speed, got velocity %v", ball.Velocity)
+	}
+}
@@ -0,0 +1,237 @@
+package engo
+
+import (
+	"sort"
+
+	"github.com/luxengine/math"
+)
+
+// RaycastHit describes the first thing a ray hits
+type RaycastHit struct {
+	// Entity is the entity that was hit; its BasicEntity is nil if a line of a Level was hit instead
+	Entity collisionEntity
+	// Line is the line of a Level that was hit, or nil if an entity was hit instead
+	Line *Line
+
+	// Point is the location where the ray hit
+	Point Point
+	// Normal is the normal of the surface that was hit, pointing towards the origin of the ray
+	Normal Point
+	// Distance is the distance from the origin of the ray to Point
+	Distance float32
+}
+
+// AddLevel adds the LineBounds of the Level as static geometry, which can be hit by Raycast
+func (cs *CollisionSystem) AddLevel(level *Level) {
+	cs.lines = append(cs.lines, level.LineBounds...)
+}
+
+// Raycast returns the first entity or Level line that is hit by the ray starting at origin, going into direction,
+// but only up to maxDistance. The ray ignores the Extra space of entities, but does take their Shape into account.
+// The second return value is false when nothing was hit. Entities that moved since an earlier query of the same
+// frame are only seen after calling Refresh.
+func (cs *CollisionSystem) Raycast(origin, direction Point, maxDistance float32) (RaycastHit, bool) {
+	dir, length := direction.Normalize()
+	if length == 0 || maxDistance <= 0 {
+		return RaycastHit{}, false
+	}
+
+	end := Point{origin.X + dir.X*maxDistance, origin.Y + dir.Y*maxDistance}
+	box := AABB{
+		Min: Point{math.Min(origin.X, end.X), math.Min(origin.Y, end.Y)},
+		Max: Point{math.Max(origin.X, end.X), math.Max(origin.Y, end.Y)},
+	}
+
+	closest := RaycastHit{Distance: maxDistance}
+	found := false
+
+	if cs.Broadphase != nil {
+		for _, index := range cs.lookup(box) {
+			e := cs.entities[index]
+			distance, normal, ok := raycastShape(e.unpaddedShape(), origin, dir)
+			if ok && (distance < closest.Distance || (!found && distance == closest.Distance)) {
+				closest = RaycastHit{Entity: e, Distance: distance, Normal: normal}
+				found = true
+			}
+		}
+	}
+
+	for i := range cs.lines {
+		line := &cs.lines[i]
+		distance, ok := raycastSegment(origin, dir, line.P1, line.P2)
+		if ok && (distance < closest.Distance || (!found && distance == closest.Distance)) {
+			closest = RaycastHit{Line: line, Distance: distance, Normal: segmentNormal(line.P1, line.P2, dir)}
+			found = true
+		}
+	}
+
+	if !found {
+		return RaycastHit{}, false
+	}
+
+	closest.Point = Point{origin.X + dir.X*closest.Distance, origin.Y + dir.Y*closest.Distance}
+	return closest, true
+}
+
+// QueryAABB returns all entities intersecting with the box, in the order they were added. Like Raycast, it ignores
+// the Extra space of entities.
+func (cs *CollisionSystem) QueryAABB(box AABB) []collisionEntity {
+	if cs.Broadphase == nil {
+		return nil
+	}
+
+	var entities []collisionEntity
+	for _, index := range cs.lookup(box) {
+		e := cs.entities[index]
+		if e.CollisionComponent.Shape == nil {
+			if !IsIntersecting(box, e.SpaceComponent.AABB()) {
+				continue // with other entities
+			}
+		} else if _, ok := satTranslation(aabbShape(box), e.unpaddedShape()); !ok {
+			continue // with other entities
+		}
+		entities = append(entities, e)
+	}
+
+	return entities
+}
+
+// QueryPoint returns all entities containing the point, in the order they were added. Like Raycast, it ignores the
+// Extra space of entities.
+func (cs *CollisionSystem) QueryPoint(point Point) []collisionEntity {
+	if cs.Broadphase == nil {
+		return nil
+	}
+
+	var entities []collisionEntity
+	for _, index := range cs.lookup(AABB{point, point}) {
+		e := cs.entities[index]
+		if e.unpaddedShape().contains(point) {
+			entities = append(entities, e)
+		}
+	}
+
+	return entities
+}
+
+// lookup returns the indices of the entities the Broadphase finds for the given box, like query does. The first
+// lookup after an Update refreshes the entities that moved since, and the result isn't shared with query, so the
+// queries can be used while handling a CollisionMessage.
+func (cs *CollisionSystem) lookup(box AABB) []int {
+	if !cs.refreshed {
+		cs.Refresh()
+	}
+
+	ids := cs.Broadphase.Query(box, nil)
+	indices := make([]int, len(ids))
+	for i, id := range ids {
+		indices[i] = cs.indices[id]
+	}
+	sort.Ints(indices)
+
+	return indices
+}
+
+// unpaddedShape returns the shape of the entity in world coordinates, without the Extra space
+func (e collisionEntity) unpaddedShape() placedShape {
+	if e.CollisionComponent.Shape != nil {
+		return e.CollisionComponent.Shape.place(e.SpaceComponent.Position, Point{})
+	}
+
+	return aabbShape(e.SpaceComponent.AABB())
+}
+
+// contains returns whether or not the point is inside of the shape
+func (s placedShape) contains(p Point) bool {
+	if s.circle {
+		return s.center.PointDistanceSquared(p) < s.radius*s.radius
+	}
+
+	// The point has to be on the same side of every edge
+	var sign float32
+	for i, p1 := range s.points {
+		p2 := s.points[(i+1)%len(s.points)]
+		cross := (p2.X-p1.X)*(p.Y-p1.Y) - (p2.Y-p1.Y)*(p.X-p1.X)
+		if cross == 0 {
+			return false // because it's on the edge
+		}
+		if sign != 0 && (cross > 0) != (sign > 0) {
+			return false
+		}
+		sign = cross
+	}
+
+	return true
+}
+
+// raycastShape returns the distance along the (normalized) direction at which the ray hits the shape, and the
+// normal at that point. A ray starting inside of the shape hits it immediately.
+func raycastShape(s placedShape, origin, dir Point) (float32, Point, bool) {
+	if s.contains(origin) {
+		return 0, Point{-dir.X, -dir.Y}, true
+	}
+
+	if s.circle {
+		// Solve |origin + t*dir - center| = radius for t
+		toOrigin := Point{origin.X - s.center.X, origin.Y - s.center.Y}
+		b := toOrigin.X*dir.X + toOrigin.Y*dir.Y
+		c := toOrigin.X*toOrigin.X + toOrigin.Y*toOrigin.Y - s.radius*s.radius
+		discriminant := b*b - c
+		if discriminant < 0 {
+			return 0, Point{}, false
+		}
+
+		t := -b - math.Sqrt(discriminant)
+		if t < 0 {
+			return 0, Point{}, false
+		}
+
+		normal := Point{origin.X + dir.X*t - s.center.X, origin.Y + dir.Y*t - s.center.Y}
+		normal, _ = normal.Normalize()
+		return t, normal, true
+	}
+
+	var (
+		closest float32
+		normal  Point
+		found   bool
+	)
+	for i, p1 := range s.points {
+		p2 := s.points[(i+1)%len(s.points)]
+		if t, ok := raycastSegment(origin, dir, p1, p2); ok && (!found || t < closest) {
+			closest = t
+			normal = segmentNormal(p1, p2, dir)
+			found = true
+		}
+	}
+
+	return closest, normal, found
+}
+
+// raycastSegment returns the distance along the (normalized) direction at which the ray hits the line segment
+func raycastSegment(origin, dir, p1, p2 Point) (float32, bool) {
+	segment := Point{p2.X - p1.X, p2.Y - p1.Y}
+	denom := dir.X*segment.Y - dir.Y*segment.X
+	if denom == 0 {
+		return 0, false // because they're parallel
+	}
+
+	toStart := Point{p1.X - origin.X, p1.Y - origin.Y}
+	t := (toStart.X*segment.Y - toStart.Y*segment.X) / denom
+	u := (toStart.X*dir.Y - toStart.Y*dir.X) / denom
+	if t < 0 || u < 0 || u > 1 {
+		return 0, false
+	}
+
+	return t, true
+}
+
+// segmentNormal returns the normal of the line segment, on the side the (normalized) direction is coming from
+func segmentNormal(p1, p2, dir Point) Point {
+	normal := Point{p2.Y - p1.Y, p1.X - p2.X}
+	normal, _ = normal.Normalize()
+	if normal.X*dir.X+normal.Y*dir.Y > 0 {
+		normal.X, normal.Y = -normal.X, -normal.Y
+	}
+	return normal
+}
@@ -0,0 +1,153 @@
+package engo
+
+import (
+	"testing"
+
+	"engo.io/ecs"
+)
+
+func newRaycastTestWorld() (*CollisionSystem, []*collisionTestEntity) {
+	sys, _ := newCollisionTestWorld(NewSpatialHash(DefaultCellSize), 0, 0)
+
+	box := &collisionTestEntity{BasicEntity: ecs.NewBasic()}
+	box.SpaceComponent = SpaceComponent{Position: Point{100, 0}, Width: 20, Height: 20}
+	box.CollisionComponent = CollisionComponent{Extra: Point{10, 10}}
+	sys.Add(&box.BasicEntity, &box.CollisionComponent, &box.SpaceComponent)
+
+	ball := &collisionTestEntity{BasicEntity: ecs.NewBasic()}
+	ball.SpaceComponent = SpaceComponent{Position: Point{0, 100}, Width: 20, Height: 20}
+	ball.CollisionComponent = CollisionComponent{Shape: CircleShape{Center: Point{10, 10}, Radius: 10}}
+	sys.Add(&ball.BasicEntity, &ball.CollisionComponent, &ball.SpaceComponent)
+
+	sys.AddLevel(&Level{LineBounds: []Line{{Point{200, -50}, Point{200, 50}}}})
+
+	return sys, []*collisionTestEntity{box, ball}
+}
+
+func TestRaycastEntity(t *testing.T) {
+	sys, entities := newRaycastTestWorld()
+
+	hit, ok := sys.Raycast(Point{0, 10}, Point{1, 0}, 500)
+	if !ok {
+		t.Fatal("Expected the ray to hit the box")
+	}
+	if hit.Entity.ID() != entities[0].ID() || hit.Line != nil {
+		t.Errorf("Expected the box to be hit, got %v", hit)
+	}
+	if hit.Distance != 100 || hit.Point != (Point{100, 10}) || hit.Normal != (Point{-1, 0}) {
+		t.Errorf("Expected a hit at (100, 10) with normal (-1, 0), got %v", hit)
+	}
+
+	hit, ok = sys.Raycast(Point{10, 0}, Point{0, 3}, 500)
+	if !ok || hit.Entity.ID() != entities[1].ID() {
+		t.Fatal("Expected the ray to hit the ball")
+	}
+	if !pointsAlmostEqual(hit.Point, Point{10, 100}) || !pointsAlmostEqual(hit.Normal, Point{0, -1}) {
+		t.Errorf("Expected a hit at (10, 100) with normal (0, -1), got %v", hit)
+	}
+
+	if _, ok = sys.Raycast(Point{0, 10}, Point{1, 0}, 99); ok {
+		t.Error("Expected the ray to be too short to hit the box")
+	}
+}
+
+func TestRaycastLevel(t *testing.T) {
+	sys, _ := newRaycastTestWorld()
+
+	hit, ok := sys.Raycast(Point{150, 40}, Point{1, 0}, 500)
+	if !ok {
+		t.Fatal("Expected the ray to hit the level")
+	}
+	if hit.Line == nil || hit.Entity.BasicEntity != nil {
+		t.Errorf("Expected a line to be hit, got %v", hit)
+	}
+	if hit.Distance != 50 || hit.Normal != (Point{-1, 0}) {
+		t.Errorf("Expected a hit after 50 with normal (-1, 0), got %v", hit)
+	}
+}
+
+func TestQueryAABBAndPoint(t *testing.T) {
+	sys, entities := newRaycastTestWorld()
+
+	if found := sys.QueryPoint(Point{110, 10}); len(found) != 1 || found[0].ID() != entities[0].ID() {
+		t.Errorf("Expected to find the box, got %v", found)
+	}
+	if found := sys.QueryPoint(Point{1, 101}); len(found) != 0 {
+		t.Errorf("Expected the corner of the ball to be empty, got %v", found)
+	}
+	if found := sys.QueryAABB(AABB{Point{0, 0}, Point{200, 200}}); len(found) != 2 {
+		t.Errorf("Expected to find both entities, got %v", found)
+	}
+	if found := sys.QueryAABB(AABB{Point{0, 80}, Point{2, 102}}); len(found) != 0 {
+		t.Errorf("Expected the box to miss the ball, got %v", found)
+	}
+}
+
+func TestQueriesIgnoreExtra(t *testing.T) {
+	sys, _ := newRaycastTestWorld()
+
+	// The box at (100, 0) has 5 pixels of Extra space on every side, which neither query counts
+	if found := sys.QueryPoint(Point{97, 10}); len(found) != 0 {
+		t.Errorf("Expected the padding of the box to be empty, got %v", found)
+	}
+	if _, ok := sys.Raycast(Point{97, -10}, Point{0, 1}, 50); ok {
+		t.Error("Expected the ray to pass through the padding of the box")
+	}
+}
+
+func TestQueriesSeeMovedEntities(t *testing.T) {
+	sys, entities := newRaycastTestWorld()
+	sys.Update(0)
+
+	// Moving the ball without an Update still moves it for the queries
+	entities[1].SpaceComponent.Position = Point{300, 300}
+	if found := sys.QueryPoint(Point{310, 310}); len(found) != 1 || found[0].ID() != entities[1].ID() {
+		t.Errorf("Expected to find the ball at its new position, got %v", found)
+	}
+	if found := sys.QueryAABB(AABB{Point{0, 100}, Point{20, 120}}); len(found) != 0 {
+		t.Errorf("Expected the old position of the ball to be empty, got %v", found)
+	}
+
+	// Entities are only refreshed once until the next Update, unless Refresh is called
+	entities[0].SpaceComponent.Position = Point{300, 0}
+	if found := sys.QueryPoint(Point{310, 10}); len(found) != 0 {
+		t.Errorf("Expected the box to be found at its old position until it's refreshed, got %v", found)
+	}
+	sys.Refresh()
+	if found := sys.QueryPoint(Point{310, 10}); len(found) != 1 || found[0].ID() != entities[0].ID() {
+		t.Errorf("Expected to find the box after refreshing, got %v", found)
+	}
+}
+
+func TestQueriesDuringCollisionMessages(t *testing.T) {
+	sys, _ := newCollisionTestWorld(NewSpatialHash(DefaultCellSize), 0, 0)
+
+	// A main entity overlapping with three others, which should each collide exactly once, while the queries find
+	// the first and the last one
+	main := &collisionTestEntity{BasicEntity: ecs.NewBasic()}
+	main.SpaceComponent = SpaceComponent{Position: Point{0, 0}, Width: 400, Height: 400}
+	main.CollisionComponent = CollisionComponent{Main: true}
+	sys.Add(&main.BasicEntity, &main.CollisionComponent, &main.SpaceComponent)
+	for i := 0; i < 3; i++ {
+		e := &collisionTestEntity{BasicEntity: ecs.NewBasic()}
+		e.SpaceComponent = SpaceComponent{Position: Point{float32(i) * 30, float32(10 + i%2*300)}, Width: 10, Height: 10}
+		sys.Add(&e.BasicEntity, &e.CollisionComponent, &e.SpaceComponent)
+	}
+
+	hits := make(map[uint64]int)
+	Mailbox.Listen("CollisionMessage", func(msg Message) {
+		hits[msg.(CollisionMessage).To.ID()]++
+		sys.QueryAABB(AABB{Point{5, 15}, Point{65, 15}})
+		sys.Raycast(Point{5, 15}, Point{1, 0}, 60)
+	})
+	sys.Update(0)
+
+	if len(hits) != 3 {
+		t.Errorf("Expected 3 entities to be hit, got %v", hits)
+	}
+	for id, n := range hits {
+		if n != 1 {
+			t.Errorf("Expected entity %d to be hit once, got %d", id, n)
+		}
+	}
+}
@@ -27,6 +27,13 @@ type Drawable interface {
 	View() (float32, float32, float32, float32)
 }
 
+// multiDrawable is a Drawable made up of many quads, like a layer of a tile map; the RenderSystem lets it draw them
+// itself, at the position of the entity
+type multiDrawable interface {
+	Drawable
+	draw(shader Shader, x, y float32)
+}
+
 type RenderComponent struct {
 	// Hidden is used to prevent drawing by OpenGL
 	Hidden bool
@@ -42,6 +49,8 @@ type RenderComponent struct {
 	drawable      Drawable
 	buffer        *gl.Buffer
 	bufferContent []float32
+	bufferDirty   bool  // whether or not bufferContent changed since it was uploaded to buffer
+	pivot         Point // the Pivot of the SpaceComponent the buffer was generated for
 }
 
 func NewRenderComponent(d Drawable, scale Point, label string) RenderComponent {
@@ -91,18 +100,27 @@ func (ren *RenderComponent) preloadTexture() {
 	}
 
 	ren.bufferContent = ren.generateBufferContent()
+	ren.bufferDirty = true
+}
 
-	ren.buffer = Gl.CreateBuffer()
-	Gl.BindBuffer(Gl.ARRAY_BUFFER, ren.buffer)
-	Gl.BufferData(Gl.ARRAY_BUFFER, ren.bufferContent, Gl.STATIC_DRAW)
+// uploadBuffer makes sure the buffer contains the latest vertices; it's only needed for shaders that don't batch
+func (ren *RenderComponent) uploadBuffer() {
+	if ren.buffer == nil {
+		ren.buffer = Gl.CreateBuffer()
+	}
+
+	if ren.bufferDirty {
+		Gl.BindBuffer(Gl.ARRAY_BUFFER, ren.buffer)
+		Gl.BufferData(Gl.ARRAY_BUFFER, ren.bufferContent, Gl.STATIC_DRAW)
+		ren.bufferDirty = false
+	}
 }
 
 // generateBufferContent computes information about the 4 vertices needed to draw the texture, which should
-// be stored in the buffer
+// be stored in the buffer. The vertices are relative to the pivot, so the shader can rotate around it.
 func (ren *RenderComponent) generateBufferContent() []float32 {
 	scaleX := ren.scale.X
 	scaleY := ren.scale.Y
-	rotation := float32(0.0)
 	transparency := float32(1.0)
 	c := ren.Color
 
@@ -118,54 +136,19 @@ func (ren *RenderComponent) generateBufferContent() []float32 {
 		fy2 *= scaleY
 	}
 
-	p1x := fx
-	p1y := fy
-	p2x := fx
-	p2y := fy2
-	p3x := fx2
-	p3y := fy2
-	p4x := fx2
-	p4y := fy
-
-	var x1 float32
-	var y1 float32
-	var x2 float32
-	var y2 float32
-	var x3 float32
-	var y3 float32
-	var x4 float32
-	var y4 float32
-
-	if rotation != 0 {
-		rot := rotation * (math.Pi / 180.0)
-
-		cos := math.Cos(rot)
-		sin := math.Sin(rot)
-
-		x1 = cos*p1x - sin*p1y
-		y1 = sin*p1x + cos*p1y
-
-		x2 = cos*p2x - sin*p2y
-		y2 = sin*p2x + cos*p2y
-
-		x3 = cos*p3x - sin*p3y
-		y3 = sin*p3x + cos*p3y
-
-		x4 = x1 + (x3 - x2)
-		y4 = y3 - (y2 - y1)
-	} else {
-		x1 = p1x
-		y1 = p1y
-
-		x2 = p2x
-		y2 = p2y
-
-		x3 = p3x
-		y3 = p3y
-
-		x4 = p4x
-		y4 = p4y
-	}
+	fx -= ren.pivot.X
+	fy -= ren.pivot.Y
+	fx2 -= ren.pivot.X
+	fy2 -= ren.pivot.Y
+
+	x1 := fx
+	y1 := fy
+	x2 := fx
+	y2 := fy2
+	x3 := fx2
+	y3 := fy2
+	x4 := fx2
+	y4 := fy
 
 	colorR, colorG, colorB, _ := c.RGBA()
 
@@ -213,6 +196,7 @@ type RenderSystem struct {
 
 	sortingNeeded bool
 	currentShader Shader
+	stats         RenderStats
 }
 
 func (*RenderSystem) Priority() int { return RenderSystemPriority }
@@ -227,6 +211,13 @@ func (rs *RenderSystem) New(w *ecs.World) {
 	Mailbox.Listen("renderChangeMessage", func(Message) {
 		rs.sortingNeeded = true
 	})
+
+	// A reloaded texture may have a different size, so all vertices are generated again
+	Mailbox.Listen("AssetReloadedMessage", func(Message) {
+		for _, e := range rs.entities {
+			e.RenderComponent.preloadTexture()
+		}
+	})
 }
 
 func (rs *RenderSystem) Add(basic *ecs.BasicEntity, render *RenderComponent, space *SpaceComponent) {
@@ -259,6 +250,7 @@ func (rs *RenderSystem) Update(dt float32) {
 	}
 
 	Gl.Clear(Gl.COLOR_BUFFER_BIT)
+	renderStats = RenderStats{}
 
 	// TODO: it's linear for now, but that might very well be a bad idea
 	for _, e := range rs.entities {
@@ -281,11 +273,37 @@ func (rs *RenderSystem) Update(dt float32) {
 			rs.currentShader = shader
 		}
 
-		rs.currentShader.Draw(e.RenderComponent.drawable.Texture(), e.RenderComponent.buffer, e.SpaceComponent.Position.X, e.SpaceComponent.Position.Y, 0) // TODO: add rotation
+		if d, ok := e.RenderComponent.drawable.(multiDrawable); ok {
+			d.draw(rs.currentShader, e.SpaceComponent.Position.X, e.SpaceComponent.Position.Y)
+			continue // with other entities
+		}
+
+		// The vertices are relative to the pivot, so they have to be regenerated when it changes
+		if e.RenderComponent.pivot != e.SpaceComponent.Pivot {
+			e.RenderComponent.pivot = e.SpaceComponent.Pivot
+			e.RenderComponent.preloadTexture()
+		}
+
+		x := e.SpaceComponent.Position.X + e.SpaceComponent.Pivot.X
+		y := e.SpaceComponent.Position.Y + e.SpaceComponent.Pivot.Y
+
+		if batcher, ok := rs.currentShader.(BatchShader); ok {
+			batcher.DrawBatched(e.RenderComponent.drawable.Texture(), e.RenderComponent.bufferContent, x, y, e.SpaceComponent.Rotation)
+		} else {
+			e.RenderComponent.uploadBuffer()
+			rs.currentShader.Draw(e.RenderComponent.drawable.Texture(), e.RenderComponent.buffer, x, y, e.SpaceComponent.Rotation)
+		}
 	}
 
 	if rs.currentShader != nil {
 		rs.currentShader.Post()
 		rs.currentShader = nil
 	}
+
+	rs.stats = renderStats
+}
+
+// Stats returns the amount of work that was done to render the previous frame
+func (rs *RenderSystem) Stats() RenderStats {
+	return rs.stats
 }
@@ -73,6 +73,11 @@ func SetScene(s Scene, forceNewWorld bool) {
 
 	// Initialize new Scene / World if needed
 	var doSetup bool
+	var previous []string // the resources used by the world that is being discarded
+
+	if wrapper.world != nil && forceNewWorld {
+		previous = Files.disown(s.Type())
+	}
 
 	if wrapper.world == nil || forceNewWorld {
 		wrapper.world = &ecs.World{}
@@ -90,14 +95,22 @@ func SetScene(s Scene, forceNewWorld bool) {
 
 	// doSetup is true whenever we're (re)initializing the Scene
 	if doSetup {
+		// The resources added by the Scene belong to it, so they're released when its world is discarded
+		Files.owner = s.Type()
+
 		s.Preload()
 		Files.Load(func() {})
 
+		// Only the resources the Scene no longer uses are released, so the others don't have to be loaded again
+		Files.release(previous)
+
 		wrapper.mailbox.listeners = make(map[string][]MessageHandler)
 
 		wrapper.world.AddSystem(wrapper.camera)
 
 		s.Setup(wrapper.world)
+
+		Files.owner = ""
 	} else {
 		if shower, ok := currentScene.(Shower); ok {
 			shower.Show()
@@ -3,6 +3,8 @@ package engo
 import (
 	"engo.io/gl"
 	"fmt"
+
+	"github.com/luxengine/math"
 )
 
 const bufferSize = 10000
@@ -10,14 +12,19 @@ const bufferSize = 10000
 type Shader interface {
 	Initialize(width, height float32)
 	Pre()
+	// Draw draws the buffer with the given texture, where x and y are the location of the pivot the vertices in
+	// the buffer are relative to, and rotation is the clockwise rotation around it in degrees
 	Draw(texture *gl.Texture, buffer *gl.Buffer, x, y, rotation float32)
 	Post()
 }
 
+// defaultShader batches all consecutive entities sharing a texture, and draws them with a single draw call
 type defaultShader struct {
-	indices  []uint16
-	indexVBO *gl.Buffer
-	program  *gl.Program
+	indices   []uint16
+	indexVBO  *gl.Buffer
+	vertexVBO *gl.Buffer
+	batch     *spriteBatch
+	program   *gl.Program
 
 	projX float32
 	projY float32
@@ -29,6 +36,7 @@ type defaultShader struct {
 	inColor      int
 	ufCamera     *gl.UniformLocation
 	ufPosition   *gl.UniformLocation
+	ufRotation   *gl.UniformLocation
 	ufProjection *gl.UniformLocation
 }
 
@@ -41,6 +49,7 @@ attribute vec2 in_TexCoords;
 attribute vec4 in_Color;
 
 uniform vec2 uf_Position;
+uniform vec2 uf_Rotation;
 uniform vec3 uf_Camera;
 uniform vec2 uf_Projection;
 
@@ -51,8 +60,12 @@ void main() {
   var_Color = in_Color;
   var_TexCoords = in_TexCoords;
 
-  gl_Position = vec4((in_Position.x + uf_Position.x - uf_Camera.x)/  uf_Projection.x,
-  					 (in_Position.y + uf_Position.y - uf_Camera.y)/ -uf_Projection.y,
+  // uf_Rotation contains the cosine and sine of the rotation
+  vec2 position = vec2(in_Position.x * uf_Rotation.x - in_Position.y * uf_Rotation.y,
+                       in_Position.x * uf_Rotation.y + in_Position.y * uf_Rotation.x);
+
+  gl_Position = vec4((position.x + uf_Position.x - uf_Camera.x)/  uf_Projection.x,
+  					 (position.y + uf_Position.y - uf_Camera.y)/ -uf_Projection.y,
   					 0.0, uf_Camera.z);
 
 }`, `
@@ -87,6 +100,10 @@ void main (void) {
 	Gl.BindBuffer(Gl.ELEMENT_ARRAY_BUFFER, s.indexVBO)
 	Gl.BufferData(Gl.ELEMENT_ARRAY_BUFFER, s.indices, Gl.STATIC_DRAW)
 
+	// The vertices of the batch are uploaded to this buffer whenever it is flushed
+	s.vertexVBO = Gl.CreateBuffer()
+	s.batch = newSpriteBatch(bufferSize, s.flush)
+
 	s.SetProjection(width, height)
 
 	// Define things that should be read from the texture buffer
@@ -97,6 +114,7 @@ void main (void) {
 	// Define things that should be set per draw
 	s.ufCamera = Gl.GetUniformLocation(s.program, "uf_Camera")
 	s.ufPosition = Gl.GetUniformLocation(s.program, "uf_Position")
+	s.ufRotation = Gl.GetUniformLocation(s.program, "uf_Rotation")
 	s.ufProjection = Gl.GetUniformLocation(s.program, "uf_Projection")
 
 	// Enable those things
@@ -127,6 +145,9 @@ func (s *defaultShader) Pre() {
 }
 
 func (s *defaultShader) Draw(texture *gl.Texture, buffer *gl.Buffer, x, y, rotation float32) {
+	// Whatever was batched before has to be drawn first, to keep the order intact
+	s.batch.end()
+
 	if s.lastTexture != texture {
 		Gl.BindTexture(Gl.TEXTURE_2D, texture)
 		Gl.BindBuffer(Gl.ARRAY_BUFFER, buffer)
@@ -138,12 +159,43 @@ func (s *defaultShader) Draw(texture *gl.Texture, buffer *gl.Buffer, x, y, rotat
 		s.lastTexture = texture
 	}
 
-	// TODO: add rotation
 	Gl.Uniform2f(s.ufPosition, x, y)
+	setRotation(s.ufRotation, rotation)
 	Gl.DrawElements(Gl.TRIANGLES, 6, Gl.UNSIGNED_SHORT, 0)
+
+	renderStats.DrawCalls++
+	renderStats.Vertices += 4
+}
+
+func (s *defaultShader) DrawBatched(texture *gl.Texture, vertices []float32, x, y, rotation float32) {
+	s.batch.add(texture, vertices, x, y, rotation)
+}
+
+// flush draws the quads collected by the batch; their vertices are already in world coordinates
+func (s *defaultShader) flush(texture *gl.Texture, vertices []float32, quads int) {
+	Gl.Uniform2f(s.ufPosition, 0, 0)
+	setRotation(s.ufRotation, 0)
+
+	Gl.BindTexture(Gl.TEXTURE_2D, texture)
+	Gl.BindBuffer(Gl.ARRAY_BUFFER, s.vertexVBO)
+	Gl.BufferData(Gl.ARRAY_BUFFER, vertices, Gl.DYNAMIC_DRAW)
+	Gl.BindBuffer(Gl.ELEMENT_ARRAY_BUFFER, s.indexVBO)
+
+	Gl.VertexAttribPointer(s.inPosition, 2, Gl.FLOAT, false, 20, 0)
+	Gl.VertexAttribPointer(s.inTexCoords, 2, Gl.FLOAT, false, 20, 8)
+	Gl.VertexAttribPointer(s.inColor, 4, Gl.UNSIGNED_BYTE, true, 20, 16)
+
+	Gl.DrawElements(Gl.TRIANGLES, 6*quads, Gl.UNSIGNED_SHORT, 0)
+
+	renderStats.DrawCalls++
+	renderStats.Vertices += 4 * quads
+
+	// The buffer binding changed, so Draw has to bind its own buffer again
+	s.lastTexture = nil
 }
 
 func (s *defaultShader) Post() {
+	s.batch.end()
 	s.lastTexture = nil
 }
 
@@ -166,6 +218,7 @@ type hudShader struct {
 	inTexCoords  int
 	inColor      int
 	ufPosition   *gl.UniformLocation
+	ufRotation   *gl.UniformLocation
 	ufProjection *gl.UniformLocation
 }
 
@@ -178,6 +231,7 @@ attribute vec2 in_TexCoords;
 attribute vec4 in_Color;
 
 uniform vec2 uf_Position;
+uniform vec2 uf_Rotation;
 uniform vec2 uf_Projection;
 
 varying vec4 var_Color;
@@ -187,8 +241,12 @@ void main() {
   var_Color = in_Color;
   var_TexCoords = in_TexCoords;
 
-  gl_Position = vec4((in_Position.x + uf_Position.x)/  uf_Projection.x - 1.0,
-  					 (in_Position.y + uf_Position.y)/ -uf_Projection.y + 1.0,
+  // uf_Rotation contains the cosine and sine of the rotation
+  vec2 position = vec2(in_Position.x * uf_Rotation.x - in_Position.y * uf_Rotation.y,
+                       in_Position.x * uf_Rotation.y + in_Position.y * uf_Rotation.x);
+
+  gl_Position = vec4((position.x + uf_Position.x)/  uf_Projection.x - 1.0,
+  					 (position.y + uf_Position.y)/ -uf_Projection.y + 1.0,
   					 0.0, 1.0);
 
 }`, `
@@ -231,6 +289,7 @@ void main (void) {
 
 	// Define things that should be set per draw
 	s.ufPosition = Gl.GetUniformLocation(s.program, "uf_Position")
+	s.ufRotation = Gl.GetUniformLocation(s.program, "uf_Rotation")
 	s.ufProjection = Gl.GetUniformLocation(s.program, "uf_Projection")
 
 	// Enable those things
@@ -262,7 +321,11 @@ func (s *hudShader) Draw(texture *gl.Texture, buffer *gl.Buffer, x, y, rotation
 	}
 
 	Gl.Uniform2f(s.ufPosition, x, y)
+	setRotation(s.ufRotation, rotation)
 	Gl.DrawElements(Gl.TRIANGLES, 6, Gl.UNSIGNED_SHORT, 0)
+
+	renderStats.DrawCalls++
+	renderStats.Vertices += 4
 }
 
 func (s *hudShader) Post() {
@@ -274,6 +337,12 @@ func (s *hudShader) SetProjection(width, height float32) {
 	s.projY = height / 2
 }
 
+// setRotation passes the cosine and sine of the rotation (in degrees) to the shader
+func setRotation(location *gl.UniformLocation, rotation float32) {
+	rot := rotation * (math.Pi / 180.0)
+	Gl.Uniform2f(location, math.Cos(rot), math.Sin(rot))
+}
+
 var (
 	DefaultShader = &defaultShader{}
 	HUDShader     = &hudShader{}
@@ -0,0 +1,253 @@
+package engo
+
+import (
+	"fmt"
+
+	"github.com/luxengine/math"
+)
+
+// Shape is the shape of a CollisionComponent, relative to the Position of its SpaceComponent. When a
+// CollisionComponent has no Shape, the AABB of the SpaceComponent is used.
+type Shape interface {
+	// Bounds returns the AABB around the shape, when it's placed at position and grown by extra (the Extra space
+	// of the CollisionComponent)
+	Bounds(position, extra Point) AABB
+
+	// place returns the shape in world coordinates
+	place(position, extra Point) placedShape
+}
+
+// RectangleShape is a rectangle, which is rotated by Rotation degrees around its center. Without rotation, it's an
+// axis-aligned box; with rotation, it's an oriented box.
+type RectangleShape struct {
+	// Offset is the top-left corner of the (unrotated) rectangle, relative to the position of the entity
+	Offset        Point
+	Width, Height float32
+	Rotation      float32
+}
+
+func (r RectangleShape) Bounds(position, extra Point) AABB {
+	return r.place(position, extra).bounds()
+}
+
+func (r RectangleShape) place(position, extra Point) placedShape {
+	center := Point{position.X + r.Offset.X + r.Width/2, position.Y + r.Offset.Y + r.Height/2}
+	halfWidth := (r.Width + extra.X) / 2
+	halfHeight := (r.Height + extra.Y) / 2
+
+	corners := []Point{{-halfWidth, -halfHeight}, {halfWidth, -halfHeight}, {halfWidth, halfHeight}, {-halfWidth, halfHeight}}
+
+	rot := r.Rotation * (math.Pi / 180.0)
+	cos := math.Cos(rot)
+	sin := math.Sin(rot)
+	for i, c := range corners {
+		corners[i] = Point{center.X + cos*c.X - sin*c.Y, center.Y + sin*c.X + cos*c.Y}
+	}
+
+	return placedShape{points: corners}
+}
+
+// CircleShape is a circle with its Center relative to the position of the entity. The Extra space of the
+// CollisionComponent grows its radius by half of the largest of Extra.X and Extra.Y.
+type CircleShape struct {
+	Center Point
+	Radius float32
+}
+
+func (c CircleShape) Bounds(position, extra Point) AABB {
+	return c.place(position, extra).bounds()
+}
+
+func (c CircleShape) place(position, extra Point) placedShape {
+	return placedShape{
+		circle: true,
+		center: Point{position.X + c.Center.X, position.Y + c.Center.Y},
+		radius: c.Radius + math.Max(extra.X, extra.Y)/2,
+	}
+}
+
+// PolygonShape is a convex polygon, with Points relative to the position of the entity. The Extra space of the
+// CollisionComponent moves every point away from the center of the polygon, by half of Extra on each axis.
+type PolygonShape struct {
+	Points []Point
+}
+
+// NewPolygonShape creates a PolygonShape, like from the Points of a PolygonObject. It returns an error when there
+// are fewer than 3 points, because those don't enclose anything.
+func NewPolygonShape(points []Point) (PolygonShape, error) {
+	if len(points) < 3 {
+		return PolygonShape{}, fmt.Errorf("a polygon needs at least 3 points, got %d", len(points))
+	}
+	return PolygonShape{Points: points}, nil
+}
+
+// shapeError returns why the shape can't be used for collision detection, or nil when it can
+func shapeError(s Shape) error {
+	var err error
+	switch p := s.(type) {
+	case PolygonShape:
+		_, err = NewPolygonShape(p.Points)
+	case *PolygonShape:
+		_, err = NewPolygonShape(p.Points)
+	}
+	return err
+}
+
+func (p PolygonShape) Bounds(position, extra Point) AABB {
+	return p.place(position, extra).bounds()
+}
+
+func (p PolygonShape) place(position, extra Point) placedShape {
+	center := Point{}
+	for _, point := range p.Points {
+		center.Add(point)
+	}
+	if len(p.Points) > 0 {
+		center.MultiplyScalar(1 / float32(len(p.Points)))
+	}
+
+	points := make([]Point, len(p.Points))
+	for i, point := range p.Points {
+		points[i] = Point{position.X + point.X, position.Y + point.Y}
+		if point.X < center.X {
+			points[i].X -= extra.X / 2
+		} else if point.X > center.X {
+			points[i].X += extra.X / 2
+		}
+		if point.Y < center.Y {
+			points[i].Y -= extra.Y / 2
+		} else if point.Y > center.Y {
+			points[i].Y += extra.Y / 2
+		}
+	}
+
+	return placedShape{points: points}
+}
+
+// placedShape is a Shape in world coordinates: either a circle, or a convex polygon
+type placedShape struct {
+	circle bool
+	center Point
+	radius float32
+	points []Point
+}
+
+func (s placedShape) bounds() AABB {
+	if s.circle {
+		return AABB{
+			Min: Point{s.center.X - s.radius, s.center.Y - s.radius},
+			Max: Point{s.center.X + s.radius, s.center.Y + s.radius},
+		}
+	}
+
+	if len(s.points) == 0 {
+		return AABB{}
+	}
+
+	aabb := AABB{Min: s.points[0], Max: s.points[0]}
+	for _, p := range s.points[1:] {
+		aabb.Min.X = math.Min(aabb.Min.X, p.X)
+		aabb.Min.Y = math.Min(aabb.Min.Y, p.Y)
+		aabb.Max.X = math.Max(aabb.Max.X, p.X)
+		aabb.Max.Y = math.Max(aabb.Max.Y, p.Y)
+	}
+	return aabb
+}
+
+// project returns the interval the shape covers on the (normalized) axis
+func (s placedShape) project(axis Point) (float32, float32) {
+	if s.circle {
+		c := s.center.X*axis.X + s.center.Y*axis.Y
+		return c - s.radius, c + s.radius
+	}
+
+	min := s.points[0].X*axis.X + s.points[0].Y*axis.Y
+	max := min
+	for _, p := range s.points[1:] {
+		d := p.X*axis.X + p.Y*axis.Y
+		min = math.Min(min, d)
+		max = math.Max(max, d)
+	}
+	return min, max
+}
+
+// axes returns the axes that have to be tested by the separating axis theorem, for a collision with other
+func (s placedShape) axes(other placedShape) []Point {
+	if !s.circle {
+		axes := make([]Point, 0, len(s.points))
+		for i, p := range s.points {
+			next := s.points[(i+1)%len(s.points)]
+			edge := Point{next.Y - p.Y, p.X - next.X}
+			if edge.X == 0 && edge.Y == 0 {
+				continue // with other edges
+			}
+			normal, _ := edge.Normalize()
+			axes = append(axes, normal)
+		}
+		return axes
+	}
+
+	// A circle only adds the axis towards the closest point of the other shape
+	closest := other.center
+	if !other.circle {
+		closest = other.points[0]
+		for _, p := range other.points[1:] {
+			if s.center.PointDistanceSquared(p) < s.center.PointDistanceSquared(closest) {
+				closest = p
+			}
+		}
+	}
+
+	axis := Point{closest.X - s.center.X, closest.Y - s.center.Y}
+	if axis.X == 0 && axis.Y == 0 {
+		return nil
+	}
+	normal, _ := axis.Normalize()
+	return []Point{normal}
+}
+
+// ShapeTranslation is the generalization of MinimumTranslation to any Shape: it returns whether or not the shapes
+// intersect, and if so, the smallest translation that moves the first shape out of the second one. Invalid shapes
+// (like a polygon of fewer than 3 points) never intersect.
+func ShapeTranslation(shape1 Shape, position1, extra1 Point, shape2 Shape, position2, extra2 Point) (Point, bool) {
+	if shapeError(shape1) != nil || shapeError(shape2) != nil {
+		return Point{}, false
+	}
+	return satTranslation(shape1.place(position1, extra1), shape2.place(position2, extra2))
+}
+
+// satTranslation uses the separating axis theorem to find the minimum translation vector
+func satTranslation(s1, s2 placedShape) (Point, bool) {
+	axes := append(s1.axes(s2), s2.axes(s1)...)
+	if len(axes) == 0 {
+		// Only happens for two circles at the exact same position
+		axes = []Point{{0, 1}}
+	}
+
+	mtv := Point{}
+	smallest := float32(-1)
+
+	for _, axis := range axes {
+		min1, max1 := s1.project(axis)
+		min2, max2 := s2.project(axis)
+
+		// How far s1 has to move along the axis (in either direction) to no longer overlap
+		forward := max2 - min1
+		backward := max1 - min2
+		if forward <= 0 || backward <= 0 {
+			return Point{}, false // because we found a separating axis
+		}
+
+		depth, direction := forward, float32(1)
+		if backward < forward {
+			depth, direction = backward, -1
+		}
+
+		if smallest < 0 || depth < smallest {
+			smallest = depth
+			mtv = Point{axis.X * depth * direction, axis.Y * depth * direction}
+		}
+	}
+
+	return mtv, true
+}
@@ -0,0 +1,121 @@
+package engo
+
+import (
+	"testing"
+
+	"engo.io/ecs"
+	"github.com/luxengine/math"
+)
+
+func pointsAlmostEqual(a, b Point) bool {
+	return math.Abs(a.X-b.X) < 0.001 && math.Abs(a.Y-b.Y) < 0.001
+}
+
+func TestShapeTranslationRectangles(t *testing.T) {
+	rect := RectangleShape{Width: 10, Height: 10}
+
+	for _, offset := range []Point{{8, 1}, {-7, 2}, {1, 9}, {3, -6}} {
+		mtv, ok := ShapeTranslation(rect, Point{}, Point{}, rect, offset, Point{})
+		if !ok {
+			t.Errorf("Expected rectangles at offset %v to intersect", offset)
+			continue // with other offsets
+		}
+
+		expected := MinimumTranslation(AABB{Point{0, 0}, Point{10, 10}}, AABB{offset, Point{offset.X + 10, offset.Y + 10}})
+		if !pointsAlmostEqual(mtv, expected) {
+			t.Errorf("Expected translation %v at offset %v, got %v", expected, offset, mtv)
+		}
+	}
+
+	if _, ok := ShapeTranslation(rect, Point{}, Point{}, rect, Point{10, 0}, Point{}); ok {
+		t.Error("Expected touching rectangles not to intersect")
+	}
+	if _, ok := ShapeTranslation(rect, Point{}, Point{4, 4}, rect, Point{11, 0}, Point{}); !ok {
+		t.Error("Expected Extra space to make the rectangles intersect")
+	}
+}
+
+func TestShapeTranslationCircles(t *testing.T) {
+	circle := CircleShape{Radius: 5}
+
+	mtv, ok := ShapeTranslation(circle, Point{}, Point{}, circle, Point{6, 8}, Point{})
+	if ok {
+		t.Errorf("Expected circles 10 apart not to intersect, got %v", mtv)
+	}
+
+	mtv, ok = ShapeTranslation(circle, Point{}, Point{}, circle, Point{3, 4}, Point{})
+	if !ok {
+		t.Fatal("Expected circles 5 apart to intersect")
+	}
+	if !pointsAlmostEqual(mtv, Point{-3, -4}) {
+		t.Errorf("Expected translation (-3, -4), got %v", mtv)
+	}
+}
+
+func TestShapeTranslationRotatedRectangle(t *testing.T) {
+	diamond := RectangleShape{Width: 10, Height: 10, Rotation: 45}
+	box := RectangleShape{Width: 10, Height: 10}
+
+	// The AABBs intersect, but the corner of the box does not reach the diamond
+	if _, ok := ShapeTranslation(diamond, Point{}, Point{}, box, Point{9, 9}, Point{}); ok {
+		t.Error("Expected the box not to reach the rotated rectangle")
+	}
+
+	mtv, ok := ShapeTranslation(diamond, Point{}, Point{}, box, Point{5 + 7.071 - 1, 0}, Point{})
+	if !ok {
+		t.Fatal("Expected the box to intersect with the tip of the rotated rectangle")
+	}
+	if !pointsAlmostEqual(mtv, Point{-1, 0}) {
+		t.Errorf("Expected translation (-1, 0), got %v", mtv)
+	}
+}
+
+func TestShapeTranslationPolygonAndCircle(t *testing.T) {
+	triangle := PolygonShape{Points: []Point{{0, 0}, {10, 0}, {0, 10}}}
+	circle := CircleShape{Radius: 2}
+
+	if _, ok := ShapeTranslation(triangle, Point{}, Point{}, circle, Point{8, 8}, Point{}); ok {
+		t.Error("Expected the circle beyond the hypotenuse not to intersect")
+	}
+
+	mtv, ok := ShapeTranslation(circle, Point{5, -1}, Point{}, triangle, Point{}, Point{})
+	if !ok {
+		t.Fatal("Expected the circle overlapping the top edge to intersect")
+	}
+	if !pointsAlmostEqual(mtv, Point{0, -1}) {
+		t.Errorf("Expected translation (0, -1), got %v", mtv)
+	}
+}
+
+func TestInvalidPolygonShape(t *testing.T) {
+	if _, err := NewPolygonShape([]Point{{0, 0}, {10, 0}}); err == nil {
+		t.Error("Expected an error for a polygon of 2 points")
+	}
+	if _, err := NewPolygonShape([]Point{{0, 0}, {10, 0}, {0, 10}}); err != nil {
+		t.Errorf("Expected a triangle to be valid, got %v", err)
+	}
+
+	empty := PolygonShape{}
+	if _, ok := ShapeTranslation(empty, Point{}, Point{}, CircleShape{Radius: 2}, Point{}, Point{}); ok {
+		t.Error("Expected an empty polygon not to intersect")
+	}
+	if b := empty.Bounds(Point{}, Point{1, 1}); b != (AABB{}) {
+		t.Errorf("Expected empty bounds, got %v", b)
+	}
+
+	// Entities with an invalid Shape are never added to the CollisionSystem
+	Mailbox = &MessageManager{}
+	sys := &CollisionSystem{}
+	basic := ecs.NewBasic()
+	sys.Add(&basic, &CollisionComponent{Shape: PolygonShape{Points: []Point{{0, 0}}}, Main: true}, &SpaceComponent{})
+	sys.Update(0)
+	if len(sys.entities) != 0 || len(sys.QueryPoint(Point{})) != 0 {
+		t.Errorf("Expected the entity not to be added, got %d entities", len(sys.entities))
+	}
+}
+
+func TestTmxPolygonTooFewPoints(t *testing.T) {
+	if _, err := newObject(TMXObj{ID: 3, Polygons: []TMXPolyline{{Points: "0,0 4,4"}}}); err == nil {
+		t.Error("Expected an error for a polygon object of 2 points")
+	}
+}
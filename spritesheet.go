@@ -1,16 +1,27 @@
 package engo
 
+import (
+	"image"
+)
+
 // Spritesheet is a class that stores a set of tiles from a file, used by tilemaps and animations
 type Spritesheet struct {
-	texture               *Texture        // The original texture
-	CellWidth, CellHeight int             // The dimensions of the cells
-	cache                 map[int]*Region // The cell cache cells
+	texture               *Texture          // The original texture
+	CellWidth, CellHeight int               // The dimensions of the cells
+	cache                 map[int]*Region   // The cell cache cells
+	regions               []image.Rectangle // The location of every cell, when they're not laid out in a grid
 }
 
 func NewSpritesheetFromTexture(texture *Texture, cellWidth, cellHeight int) *Spritesheet {
 	return &Spritesheet{texture: texture, CellWidth: cellWidth, CellHeight: cellHeight, cache: make(map[int]*Region)}
 }
 
+// NewSpritesheetFromRegions creates a Spritesheet of which the cells are the given parts of the texture, rather than
+// a grid of cells of the same size, like the frames of a packed sprite sheet
+func NewSpritesheetFromRegions(texture *Texture, regions []image.Rectangle) *Spritesheet {
+	return &Spritesheet{texture: texture, cache: make(map[int]*Region), regions: regions}
+}
+
 // NewSpritesheetFromFile is a simple handler for creating a new spritesheet from a file
 // textureName is the name of a texture already preloaded with engo.Files.Add
 func NewSpritesheetFromFile(textureName string, cellWidth, cellHeight int) *Spritesheet {
@@ -22,7 +33,12 @@ func (s *Spritesheet) Cell(index int) *Region {
 	if r := s.cache[index]; r != nil {
 		return r
 	}
-	s.cache[index] = regionFromSheet(s.texture, s.CellWidth, s.CellHeight, index)
+	if s.regions != nil {
+		r := s.regions[index]
+		s.cache[index] = NewRegion(s.texture, float32(r.Min.X), float32(r.Min.Y), float32(r.Dx()), float32(r.Dy()))
+	} else {
+		s.cache[index] = regionFromSheet(s.texture, s.CellWidth, s.CellHeight, 0, 0, index)
+	}
 
 	return s.cache[index]
 }
@@ -42,6 +58,9 @@ func (s *Spritesheet) Drawables() []Drawable {
 }
 
 func (s *Spritesheet) CellCount() int {
+	if s.regions != nil {
+		return len(s.regions)
+	}
 	return int(s.Width()) * int(s.Height())
 }
 
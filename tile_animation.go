@@ -0,0 +1,76 @@
+package engo
+
+import (
+	"time"
+)
+
+// TileAnimation is the animation of a single type of tile. All tiles of that type in a Level share it, so they
+// always show the same frame.
+type TileAnimation struct {
+	Frames    []*Region
+	Durations []time.Duration
+
+	frame   int
+	elapsed time.Duration
+	changed bool // whether or not the frame changed during the last Update
+}
+
+// newTileAnimation creates the animation from the frames of Tiled, where region returns the image of a tile by its
+// ID within the tileset
+func newTileAnimation(frames []TileFrame, region func(id int) *Region) *TileAnimation {
+	anim := &TileAnimation{
+		Frames:    make([]*Region, len(frames)),
+		Durations: make([]time.Duration, len(frames)),
+	}
+	for i, f := range frames {
+		anim.Frames[i] = region(f.TileID)
+		anim.Durations[i] = f.Duration
+	}
+	return anim
+}
+
+// Update advances the animation by dt seconds, skipping frames if needed; it returns whether or not the current frame
+// changed
+func (a *TileAnimation) Update(dt float32) bool {
+	a.changed = false
+
+	var total time.Duration
+	for _, d := range a.Durations {
+		total += d
+	}
+	if total <= 0 {
+		return false // because the animation would never get past a frame
+	}
+
+	previous := a.frame
+	a.elapsed += time.Duration(dt * float32(time.Second))
+	if a.elapsed >= total {
+		// Whole loops of the animation end up at the same frame
+		a.elapsed %= total
+	}
+	for a.elapsed >= a.Durations[a.frame] {
+		a.elapsed -= a.Durations[a.frame]
+		a.frame = (a.frame + 1) % len(a.Frames)
+	}
+
+	a.changed = a.frame != previous
+	return a.changed
+}
+
+// Frame returns the index of the current frame
+func (a *TileAnimation) Frame() int {
+	return a.frame
+}
+
+// Region returns the image of the current frame
+func (a *TileAnimation) Region() *Region {
+	return a.Frames[a.frame]
+}
+
+// UpdateAnimations advances all animated tiles of the Level by dt seconds. The TileMapSystem does this for the
+// Levels it draws.
+func (lvl *Level) UpdateAnimations(dt float32) {
+	for _, anim := range lvl.Animations {
+		anim.Update(dt)
+	}
+}
@@ -0,0 +1,99 @@
+package engo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"engo.io/ecs"
+)
+
+func TestTileAnimationUpdate(t *testing.T) {
+	a := &TileAnimation{
+		Frames:    []*Region{{}, {}},
+		Durations: []time.Duration{100 * time.Millisecond, 150 * time.Millisecond},
+	}
+
+	steps := []struct {
+		dt      float32
+		frame   int
+		changed bool
+	}{
+		{0.05, 0, false},
+		{0.06, 1, true},
+		{0.1, 1, false},
+		{0.05, 0, true},
+		{0.26, 0, false}, // a whole loop, and a bit
+		{0.1, 1, true},
+	}
+	for i, s := range steps {
+		if changed := a.Update(s.dt); changed != s.changed || a.Frame() != s.frame {
+			t.Errorf("Step %d: expected frame %d (changed: %v), got %d (%v)", i, s.frame, s.changed, a.Frame(),
+				changed)
+		}
+	}
+
+	still := &TileAnimation{Frames: []*Region{{}}, Durations: []time.Duration{0}}
+	if still.Update(1) {
+		t.Error("Expected an animation without duration not to change")
+	}
+}
+
+func TestAnimatedTiles(t *testing.T) {
+	lvl := loadTestLevel(t, fmt.Sprintf(`<map width="3" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" name="tiles" tilewidth="8" tileheight="8">
+  <image source="tiles.png" width="16" height="16"/>
+  <tile id="0">
+   <animation>
+    <frame tileid="0" duration="100"/>
+    <frame tileid="3" duration="100"/>
+   </animation>
+  </tile>
+ </tileset>
+ <layer name="ground" width="3" height="1">
+  <data encoding="csv">1,%d,2</data>
+ </layer>
+</map>`, FlippedHorizontally|1))
+
+	tiles := lvl.TileLayers[0].Tiles
+	anim := lvl.Animations[1]
+	if anim == nil || len(lvl.Animations) != 1 || tiles[0].Animation != anim || tiles[1].Animation != anim {
+		t.Fatalf("Expected both tiles to share the animation, got %v", lvl.Animations)
+	}
+	if tiles[2].Animation != nil {
+		t.Error("Expected the other tile not to be animated")
+	}
+
+	sheet := lvl.Tilesets[0].Image
+	if len(anim.Frames) != 2 || anim.Frames[0].texture != sheet || anim.Durations[1] != 100*time.Millisecond {
+		t.Fatalf("Unexpected frames: %v", anim.Frames)
+	}
+
+	headless = true
+	Mailbox = &MessageManager{}
+	w := &ecs.World{}
+	w.AddSystem(&RenderSystem{})
+	tms := &TileMapSystem{}
+	tms.New(w)
+	w.AddSystem(tms)
+	basic := ecs.NewBasic()
+	tms.Add(&basic, &TileMapComponent{Level: lvl})
+
+	tms.Update(0.15)
+	if anim.Frame() != 1 {
+		t.Fatalf("Expected the TileMapSystem to advance the animation, got frame %d", anim.Frame())
+	}
+
+	// Tile 4 is at the bottom-right of the tileset
+	// The texture coordinates of the first vertex of every tile are its u and v
+	vertices := tms.entities[0].layers[0].tiles.vertices
+	if u, v := vertices[0][2], vertices[0][3]; u != 0.5 || v != 0.5 {
+		t.Errorf("Expected the first tile to show tile 4, got %v,%v", u, v)
+	}
+	if u, v, u2 := vertices[1][2], vertices[1][3], vertices[1][7]; u != 1 || u2 != 0.5 || v != 0.5 {
+		t.Errorf("Expected the second tile to show tile 4 flipped, got %v,%v", u, v)
+	}
+	if u, v, _, _ := tiles[2].Image.View(); vertices[2][2] != u || vertices[2][3] != v {
+		t.Error("Expected the tile without animation to keep its image")
+	}
+}
@@ -0,0 +1,103 @@
+package engo
+
+import (
+	"engo.io/ecs"
+)
+
+// SolidCells returns which cells of the Level are solid, row by row. When layer isn't empty, the cells with a tile on
+// that TileLayer are solid; when property isn't empty, the cells with a tile of which that bool property is true (on
+// any TileLayer) are solid as well.
+func (lvl *Level) SolidCells(layer, property string) []bool {
+	solid := make([]bool, lvl.Width*lvl.Height)
+
+	for _, l := range lvl.TileLayers {
+		collisionLayer := layer != "" && l.Name == layer
+		for i, t := range l.Tiles {
+			if i >= len(solid) || t.Image == nil {
+				continue // with other tiles
+			}
+			if collisionLayer || (property != "" && t.Info != nil && t.Info.Properties.Bool(property)) {
+				solid[i] = true
+			}
+		}
+	}
+
+	return solid
+}
+
+// CollisionRects returns the solid cells of the Level (see SolidCells) in world coordinates. On orthogonal maps,
+// neighbouring cells are merged into as few rectangles as possible; on other maps, every cell gets the box of
+// TileWidth by TileHeight around it.
+func (lvl *Level) CollisionRects(layer, property string) []AABB {
+	solid := lvl.SolidCells(layer, property)
+	used := make([]bool, len(solid))
+	free := func(x, y int) bool {
+		idx := x + y*lvl.Width
+		return solid[idx] && !used[idx]
+	}
+
+	var rects []AABB
+	for y := 0; y < lvl.Height; y++ {
+		for x := 0; x < lvl.Width; x++ {
+			if !free(x, y) {
+				continue // with other cells
+			}
+
+			// Grow the rectangle to the right first, and then down for as long as entire rows are free
+			width := 1
+			for lvl.Orientation == Orthogonal && x+width < lvl.Width && free(x+width, y) {
+				width++
+			}
+			height := 1
+			for lvl.Orientation == Orthogonal && y+height < lvl.Height {
+				row := true
+				for i := 0; i < width && row; i++ {
+					row = free(x+i, y+height)
+				}
+				if !row {
+					break
+				}
+				height++
+			}
+
+			for j := 0; j < height; j++ {
+				for i := 0; i < width; i++ {
+					used[x+i+(y+j)*lvl.Width] = true
+				}
+			}
+
+			min := lvl.TileToWorld(x+lvl.StartX, y+lvl.StartY)
+			rects = append(rects, AABB{
+				Min: min,
+				Max: Point{min.X + float32(width*lvl.TileWidth), min.Y + float32(height*lvl.TileHeight)},
+			})
+		}
+	}
+
+	return rects
+}
+
+// TileCollision is a static, solid entity covering part of a Level, which can be added to the CollisionSystem
+type TileCollision struct {
+	ecs.BasicEntity
+	CollisionComponent
+	SpaceComponent
+}
+
+// CollisionEntities creates a TileCollision for every rectangle returned by CollisionRects
+func (lvl *Level) CollisionEntities(layer, property string) []*TileCollision {
+	rects := lvl.CollisionRects(layer, property)
+	entities := make([]*TileCollision, len(rects))
+	for i, r := range rects {
+		entities[i] = &TileCollision{
+			BasicEntity:        ecs.NewBasic(),
+			CollisionComponent: CollisionComponent{Solid: true},
+			SpaceComponent: SpaceComponent{
+				Position: r.Min,
+				Width:    r.Max.X - r.Min.X,
+				Height:   r.Max.Y - r.Min.Y,
+			},
+		}
+	}
+	return entities
+}
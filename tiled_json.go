@@ -0,0 +1,366 @@
+package engo
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// tiledJSONMap is a map saved in the JSON format of Tiled (.tmj or .json)
+type tiledJSONMap struct {
+	Type          string              `json:"type"`
+	Orientation   string              `json:"orientation"`
+	StaggerAxis   string              `json:"staggeraxis"`
+	StaggerIndex  string              `json:"staggerindex"`
+	HexSideLength int                 `json:"hexsidelength"`
+	Width         int                 `json:"width"`
+	Height        int                 `json:"height"`
+	TileWidth     int                 `json:"tilewidth"`
+	TileHeight    int                 `json:"tileheight"`
+	Infinite      bool                `json:"infinite"`
+	Properties    []tiledJSONProperty `json:"properties"`
+	Tilesets      []tiledJSONTileset  `json:"tilesets"`
+	Layers        []tiledJSONLayer    `json:"layers"`
+}
+
+type tiledJSONProperty struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type tiledJSONTileset struct {
+	Firstgid    int                 `json:"firstgid"`
+	Source      string              `json:"source"`
+	Name        string              `json:"name"`
+	TileWidth   int                 `json:"tilewidth"`
+	TileHeight  int                 `json:"tileheight"`
+	Spacing     int                 `json:"spacing"`
+	Margin      int                 `json:"margin"`
+	Image       string              `json:"image"`
+	ImageWidth  int                 `json:"imagewidth"`
+	ImageHeight int                 `json:"imageheight"`
+	Properties  []tiledJSONProperty `json:"properties"`
+	Tiles       []tiledJSONTile     `json:"tiles"`
+}
+
+type tiledJSONTile struct {
+	ID          int                 `json:"id"`
+	Type        string              `json:"type"`
+	Class       string              `json:"class"`
+	Properties  []tiledJSONProperty `json:"properties"`
+	ObjectGroup *tiledJSONLayer     `json:"objectgroup"`
+	Animation   []tiledJSONFrame    `json:"animation"`
+}
+
+type tiledJSONFrame struct {
+	TileID   int `json:"tileid"`
+	Duration int `json:"duration"`
+}
+
+type tiledJSONLayer struct {
+	Type        string              `json:"type"`
+	Name        string              `json:"name"`
+	Width       int                 `json:"width"`
+	Height      int                 `json:"height"`
+	X           float64             `json:"x"`
+	Y           float64             `json:"y"`
+	OffsetX     float64             `json:"offsetx"`
+	OffsetY     float64             `json:"offsety"`
+	Opacity     *float64            `json:"opacity"`
+	Visible     *bool               `json:"visible"`
+	Color       string              `json:"color"`
+	Properties  []tiledJSONProperty `json:"properties"`
+	Encoding    string              `json:"encoding"`
+	Compression string              `json:"compression"`
+	Data        json.RawMessage     `json:"data"`
+	Chunks      []tiledJSONChunk    `json:"chunks"`
+	Objects     []tiledJSONObject   `json:"objects"`
+	Image       string              `json:"image"`
+	Layers      []tiledJSONLayer    `json:"layers"`
+}
+
+type tiledJSONChunk struct {
+	X      int             `json:"x"`
+	Y      int             `json:"y"`
+	Width  int             `json:"width"`
+	Height int             `json:"height"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type tiledJSONObject struct {
+	ID         int                 `json:"id"`
+	Name       string              `json:"name"`
+	Type       string              `json:"type"`
+	Class      string              `json:"class"`
+	X          float64             `json:"x"`
+	Y          float64             `json:"y"`
+	Width      float64             `json:"width"`
+	Height     float64             `json:"height"`
+	Rotation   float64             `json:"rotation"`
+	Gid        uint32              `json:"gid"`
+	Visible    *bool               `json:"visible"`
+	Ellipse    bool                `json:"ellipse"`
+	Point      bool                `json:"point"`
+	Polygon    []tiledJSONPoint    `json:"polygon"`
+	Polyline   []tiledJSONPoint    `json:"polyline"`
+	Properties []tiledJSONProperty `json:"properties"`
+}
+
+type tiledJSONPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// isTiledJSONMap returns whether or not the JSON document is a map saved by Tiled
+func isTiledJSONMap(data []byte) bool {
+	var doc struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(data, &doc) == nil && doc.Type == "map"
+}
+
+// parseTiledJSON reads and decodes a JSON map, and the external tilesets it uses, into the same structure as a TMX
+// file. Like parseTmx, it can be called from any goroutine.
+func parseTiledJSON(fs FileSystem, r Resource) (*TMXLevel, error) {
+	data, err := readFile(fs, r.url)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTiledJSON(fs, r, data)
+}
+
+// decodeTiledJSON decodes a JSON map, which was read from r
+func decodeTiledJSON(fs FileSystem, r Resource, data []byte) (*TMXLevel, error) {
+	var m tiledJSONMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	tlvl, err := m.tmx()
+	if err != nil {
+		return tlvl, err
+	}
+
+	if err := readTilesets(fs, path.Dir(r.url), tlvl.Tilesets); err != nil {
+		return tlvl, err
+	}
+
+	return tlvl, decodeLayers(tlvl)
+}
+
+// tmx converts the map to the structure of a TMX file
+func (m tiledJSONMap) tmx() (*TMXLevel, error) {
+	tlvl := &TMXLevel{
+		Orientation:   m.Orientation,
+		StaggerAxis:   m.StaggerAxis,
+		StaggerIndex:  m.StaggerIndex,
+		HexSideLength: m.HexSideLength,
+		Width:         m.Width,
+		Height:        m.Height,
+		TileWidth:     m.TileWidth,
+		TileHeight:    m.TileHeight,
+		Properties:    tiledJSONProperties(m.Properties),
+	}
+	if m.Infinite {
+		tlvl.Infinite = 1
+	}
+
+	for _, ts := range m.Tilesets {
+		tlvl.Tilesets = append(tlvl.Tilesets, ts.tmx())
+	}
+
+	return tlvl, addTiledJSONLayers(tlvl, m.Layers)
+}
+
+// addTiledJSONLayers adds the layers to the TMX structure; the layers of groups are added as if they weren't grouped
+func addTiledJSONLayers(tlvl *TMXLevel, layers []tiledJSONLayer) error {
+	for _, l := range layers {
+		switch l.Type {
+		case "tilelayer":
+			layer := TMXLayer{
+				Name:       l.Name,
+				Width:      l.Width,
+				Height:     l.Height,
+				Opacity:    l.Opacity,
+				Visible:    tiledJSONVisible(l.Visible),
+				Properties: tiledJSONProperties(l.Properties),
+				Data:       TMXData{Encoding: l.Encoding, Compression: l.Compression},
+			}
+
+			var err error
+			if len(l.Chunks) > 0 {
+				for _, c := range l.Chunks {
+					chunk := TMXChunk{X: c.X, Y: c.Y, Width: c.Width, Height: c.Height}
+					if chunk.Text, chunk.Tiles, err = tiledJSONData(c.Data); err != nil {
+						return fmt.Errorf("layer %q, chunk at %d,%d: %v", l.Name, c.X, c.Y, err)
+					}
+					layer.Data.Chunks = append(layer.Data.Chunks, chunk)
+				}
+			} else if layer.Data.Text, layer.Data.Tiles, err = tiledJSONData(l.Data); err != nil {
+				return fmt.Errorf("layer %q: %v", l.Name, err)
+			}
+			if l.Encoding == "csv" {
+				// The gids are an array of numbers in JSON, which are read like the tiles of XML
+				layer.Data.Encoding = ""
+			}
+			tlvl.Layers = append(tlvl.Layers, layer)
+		case "objectgroup":
+			tlvl.ObjGroups = append(tlvl.ObjGroups, l.objectGroup())
+		case "imagelayer":
+			tlvl.ImgLayers = append(tlvl.ImgLayers, TMXImgLayer{
+				Name:       l.Name,
+				X:          l.X + l.OffsetX,
+				Y:          l.Y + l.OffsetY,
+				ImgSrc:     TMXImgSrc{Source: l.Image},
+				Properties: tiledJSONProperties(l.Properties),
+			})
+		case "group":
+			if err := addTiledJSONLayers(tlvl, l.Layers); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("layer %q has unknown type %q", l.Name, l.Type)
+		}
+	}
+	return nil
+}
+
+// objectGroup converts a layer of objects to the structure of a TMX file
+func (l tiledJSONLayer) objectGroup() TMXObjGroup {
+	group := TMXObjGroup{
+		Name:       l.Name,
+		Color:      l.Color,
+		Opacity:    l.Opacity,
+		Visible:    tiledJSONVisible(l.Visible),
+		OffsetX:    l.OffsetX,
+		OffsetY:    l.OffsetY,
+		Properties: tiledJSONProperties(l.Properties),
+	}
+	for _, o := range l.Objects {
+		group.Objects = append(group.Objects, o.tmx())
+	}
+	return group
+}
+
+// tiledJSONData converts the data of a layer or chunk, which is either an array of gids or a base64 encoded string
+func tiledJSONData(data json.RawMessage) (string, []TMXDataTile, error) {
+	if len(data) == 0 {
+		return "", nil, nil
+	}
+
+	if data[0] == '"' {
+		var text string
+		err := json.Unmarshal(data, &text)
+		return text, nil, err
+	}
+
+	var gids []uint32
+	if err := json.Unmarshal(data, &gids); err != nil {
+		return "", nil, err
+	}
+	tiles := make([]TMXDataTile, len(gids))
+	for i, gid := range gids {
+		tiles[i].Gid = gid
+	}
+	return "", tiles, nil
+}
+
+func (ts tiledJSONTileset) tmx() TMXTileset {
+	tmx := TMXTileset{
+		Firstgid:   ts.Firstgid,
+		Source:     ts.Source,
+		Name:       ts.Name,
+		TileWidth:  ts.TileWidth,
+		TileHeight: ts.TileHeight,
+		Spacing:    ts.Spacing,
+		Margin:     ts.Margin,
+		ImageSrc:   TMXTilesetSrc{Source: ts.Image, Width: ts.ImageWidth, Height: ts.ImageHeight},
+		Properties: tiledJSONProperties(ts.Properties),
+	}
+	for _, t := range ts.Tiles {
+		tile := TMXTile{
+			ID:         t.ID,
+			Type:       t.Type,
+			Class:      t.Class,
+			Properties: tiledJSONProperties(t.Properties),
+		}
+		if t.ObjectGroup != nil {
+			group := t.ObjectGroup.objectGroup()
+			tile.ObjectGroup = &group
+		}
+		for _, f := range t.Animation {
+			tile.Animation = append(tile.Animation, TMXFrame{TileID: f.TileID, Duration: f.Duration})
+		}
+		tmx.Tiles = append(tmx.Tiles, tile)
+	}
+	return tmx
+}
+
+func (o tiledJSONObject) tmx() TMXObj {
+	tmx := TMXObj{
+		ID:         o.ID,
+		Name:       o.Name,
+		Type:       o.Type,
+		Class:      o.Class,
+		X:          o.X,
+		Y:          o.Y,
+		Width:      o.Width,
+		Height:     o.Height,
+		Rotation:   o.Rotation,
+		Gid:        o.Gid,
+		Visible:    tiledJSONVisible(o.Visible),
+		Properties: tiledJSONProperties(o.Properties),
+	}
+	if o.Ellipse {
+		tmx.Ellipse = &struct{}{}
+	}
+	if o.Point {
+		tmx.Point = &struct{}{}
+	}
+	if o.Polygon != nil {
+		tmx.Polygons = []TMXPolyline{{Points: tiledJSONPoints(o.Polygon)}}
+	}
+	if o.Polyline != nil {
+		tmx.Polylines = []TMXPolyline{{Points: tiledJSONPoints(o.Polyline)}}
+	}
+	return tmx
+}
+
+// tiledJSONPoints formats the points like the points attribute of a TMX file
+func tiledJSONPoints(points []tiledJSONPoint) string {
+	strs := make([]string, len(points))
+	for i, p := range points {
+		strs[i] = strconv.FormatFloat(p.X, 'g', -1, 64) + "," + strconv.FormatFloat(p.Y, 'g', -1, 64)
+	}
+	return strings.Join(strs, " ")
+}
+
+func tiledJSONProperties(props []tiledJSONProperty) []TMXProperty {
+	tmx := make([]TMXProperty, len(props))
+	for i, p := range props {
+		tmx[i] = TMXProperty{Name: p.Name, Type: p.Type}
+		switch v := p.Value.(type) {
+		case nil:
+		case float64:
+			// Formatted without exponent, so integers can be parsed with Properties.Int
+			tmx[i].Value = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			tmx[i].Value = v
+		default:
+			tmx[i].Value = fmt.Sprint(v)
+		}
+	}
+	return tmx
+}
+
+// tiledJSONVisible converts the visible field, which is left out when it's true
+func tiledJSONVisible(visible *bool) *int {
+	if visible == nil || *visible {
+		return nil
+	}
+	hidden := 0
+	return &hidden
+}
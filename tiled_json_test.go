@@ -0,0 +1,154 @@
+package engo
+
+import (
+	"fmt"
+	"testing"
+)
+
+const testJSONMap = `{
+ "type": "map",
+ "width": 2, "height": 2, "tilewidth": 8, "tileheight": 8,
+ "properties": [
+  {"name": "music", "type": "string", "value": "theme.wav"},
+  {"name": "lives", "type": "int", "value": 3}
+ ],
+ "tilesets": [
+  {"firstgid": 1, "name": "tiles", "tilewidth": 8, "tileheight": 8, "image": "tiles.png",
+   "imagewidth": 16, "imageheight": 16,
+   "tiles": [{"id": 2, "type": "water", "properties": [{"name": "depth", "type": "int", "value": 3}]}]}
+ ],
+ "layers": [
+  {"type": "tilelayer", "name": "ground", "width": 2, "height": 2, "opacity": 0.5, "visible": true,
+   "data": [1, 0, 3, 4]},
+  {"type": "group", "name": "group", "layers": [
+   {"type": "tilelayer", "name": "top", "width": 2, "height": 2, "visible": false,
+    "encoding": "base64", "compression": "zlib", "data": "%s"}
+  ]},
+  {"type": "objectgroup", "name": "shapes", "visible": true, "objects": [
+   {"id": 1, "name": "box", "type": "spawn", "x": 1, "y": 2, "width": 3, "height": 4, "rotation": 0,
+    "visible": true, "properties": [{"name": "solid", "type": "bool", "value": true}]},
+   {"id": 2, "name": "round", "x": 0, "y": 0, "width": 5, "height": 5, "ellipse": true, "visible": true},
+   {"id": 3, "name": "dot", "x": 6, "y": 7, "point": true, "visible": true},
+   {"id": 4, "name": "triangle", "x": 10, "y": 10, "visible": true,
+    "polygon": [{"x": 0, "y": 0}, {"x": 4, "y": 0}, {"x": 0, "y": 4}]},
+   {"id": 5, "name": "wall", "x": 0, "y": 16, "visible": true,
+    "polyline": [{"x": 0, "y": 0}, {"x": 16.5, "y": 0}]},
+   {"id": 6, "name": "crate", "gid": 2, "x": 8, "y": 16, "width": 8, "height": 8, "visible": false}
+  ]},
+  {"type": "imagelayer", "name": "sky", "x": 1, "y": 2, "image": "background.png", "visible": true}
+ ]
+}`
+
+func TestTiledJSONMap(t *testing.T) {
+	for _, name := range []string{"level.tmj", "level.json"} {
+		data := []byte(fmt.Sprintf(testJSONMap, testLayerData(t, 0, 2, 0, 0)))
+		lvl := loadTestFiles(t, name, MemoryFileSystem{name: data})
+
+		if lvl.Width != 2 || lvl.Height != 2 || lvl.TileWidth != 8 || lvl.TileHeight != 8 {
+			t.Errorf("%s: unexpected size: %dx%d", name, lvl.Width, lvl.Height)
+		}
+		if lvl.Properties["music"] != "theme.wav" || lvl.Properties.Int("lives") != 3 {
+			t.Errorf("%s: unexpected properties: %v", name, lvl.Properties)
+		}
+		if info := lvl.TileInfo(3); info == nil || info.Type != "water" || info.Properties.Int("depth") != 3 {
+			t.Errorf("%s: unexpected info of tile 3: %v", name, info)
+		}
+
+		if len(lvl.TileLayers) != 2 || len(lvl.Tiles) != 8 {
+			t.Fatalf("%s: expected both tile layers, got %v", name, lvl.TileLayers)
+		}
+		ground, top := lvl.TileLayer("ground"), lvl.TileLayer("top")
+		if ground.Opacity != 0.5 || !ground.Visible || ground.Tiles[0].Image == nil || ground.Tiles[1].Image != nil {
+			t.Errorf("%s: unexpected ground layer: %v", name, ground)
+		}
+		if top.Visible || top.Tiles[1].Image == nil || top.Tiles[0].Image != nil {
+			t.Errorf("%s: unexpected top layer: %v", name, top)
+		}
+
+		shapes := lvl.ObjectGroup("shapes")
+		if shapes == nil || len(shapes.Objects) != 6 {
+			t.Fatalf("%s: unexpected object group: %v", name, shapes)
+		}
+		kinds := []ObjectKind{RectangleObject, EllipseObject, PointObject, PolygonObject, PolylineObject, TileObject}
+		for i, kind := range kinds {
+			if shapes.Objects[i].Kind != kind {
+				t.Errorf("%s: expected object %d to be of kind %d, got %d", name, i, kind, shapes.Objects[i].Kind)
+			}
+		}
+		if box := shapes.Object("box"); box.Type != "spawn" || !box.Properties.Bool("solid") || !box.Visible {
+			t.Errorf("%s: unexpected box: %v", name, box)
+		}
+		if crate := shapes.Object("crate"); crate.Visible || crate.Gid != 2 {
+			t.Errorf("%s: unexpected crate: %v", name, crate)
+		}
+		if len(lvl.LineBounds) != 1 || lvl.LineBounds[0] != (Line{Point{0, 16}, Point{16.5, 16}}) {
+			t.Errorf("%s: unexpected line bounds: %v", name, lvl.LineBounds)
+		}
+
+		if len(lvl.Images) != 1 || lvl.ImageLayers[0].Image.Point != (Point{1, 2}) {
+			t.Errorf("%s: unexpected image layers: %v", name, lvl.ImageLayers)
+		}
+	}
+}
+
+func TestTiledJSONIsStillJSON(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	l := NewLoader()
+	l.SetFileSystem(MemoryFileSystem{"data.json": []byte(`{"type": "dialogue"}`)})
+	l.Add("data.json")
+	if err := l.TryLoad(); err != nil {
+		t.Fatal(err)
+	}
+
+	if text, err := l.TryJson("data.json"); err != nil || text != `{"type": "dialogue"}` {
+		t.Errorf("Unexpected JSON: %q, %v", text, err)
+	}
+	if _, err := l.TryLevel("data.json"); err == nil {
+		t.Error("Expected a JSON file which isn't a map not to be a level")
+	}
+}
+
+func TestExternalTilesets(t *testing.T) {
+	tsx := `<?xml version="1.0" encoding="UTF-8"?>
+<tileset name="tiles" tilewidth="8" tileheight="8">
+ <image source="tiles.png" width="16" height="16"/>
+ <tile id="2" type="water"/>
+</tileset>`
+	tsj := `{"name": "tiles", "tilewidth": 8, "tileheight": 8, "image": "tiles.png", "imagewidth": 16,
+ "imageheight": 16, "tiles": [{"id": 2, "type": "water"}]}`
+	tmx := `<map width="2" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="5" source="%s"/>
+ <layer name="ground" width="2" height="1"><data encoding="csv">5,7</data></layer>
+</map>`
+	tmj := `{"type": "map", "width": 2, "height": 1, "tilewidth": 8, "tileheight": 8,
+ "tilesets": [{"firstgid": 5, "source": "%s"}],
+ "layers": [{"type": "tilelayer", "name": "ground", "width": 2, "height": 1, "data": [5, 7]}]}`
+
+	files := map[string]MemoryFileSystem{
+		"maps/level.tmx": {"maps/level.tmx": []byte(fmt.Sprintf(tmx, "../tilesets/tiles.tsx"))},
+		"maps/level.tmj": {"maps/level.tmj": []byte(fmt.Sprintf(tmj, "tiles.tsj"))},
+	}
+	files["maps/level.tmx"]["tilesets/tiles.tsx"] = []byte(tsx)
+	files["maps/level.tmj"]["maps/tiles.tsj"] = []byte(tsj)
+
+	for name, fs := range files {
+		lvl := loadTestFiles(t, name, fs)
+
+		if len(lvl.Tilesets) != 1 || lvl.Tilesets[0].Firstgid != 5 || lvl.Tilesets[0].Name != "tiles" {
+			t.Fatalf("%s: unexpected tilesets: %v", name, lvl.Tilesets)
+		}
+		if info := lvl.TileInfo(7); info == nil || info.Type != "water" {
+			t.Errorf("%s: unexpected info of tile 7: %v", name, info)
+		}
+		if lvl.Tiles[0].Image == nil || lvl.Tiles[1].Image == nil || lvl.Tiles[1].Info.Type != "water" {
+			t.Errorf("%s: expected both tiles to have an image", name)
+		}
+	}
+
+	_, err := parseTestTmx(fmt.Sprintf(tmx, "missing.tsx"))
+	if err == nil {
+		t.Error("Expected an error for a missing tileset")
+	}
+}
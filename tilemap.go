@@ -0,0 +1,334 @@
+package engo
+
+import (
+	"image"
+	"log"
+	"sort"
+
+	"engo.io/ecs"
+	"engo.io/gl"
+	"github.com/luxengine/math"
+)
+
+// TileMapSystemPriority makes sure the TileMapSystem decides which tiles are visible, before the RenderSystem draws
+// them
+const TileMapSystemPriority = RenderSystemPriority + 1
+
+// TileMapComponent makes the TileMapSystem draw the tile layers of a Level
+type TileMapComponent struct {
+	Level *Level
+	// ZIndex is the z-index of the first tile layer; every next layer is drawn one z-index higher, so the layers
+	// are drawn in the same order as in Tiled. Within a layer, the tiles are drawn from top to bottom, so tiles
+	// which stick out of their cell (like on isometric maps) overlap the ones behind them.
+	ZIndex float32
+}
+
+// TileMapSystem draws the tile layers of Levels, through the RenderSystem of the same World. Every layer is a single
+// entity of the RenderSystem, which only draws the tiles visible to the camera (on layers which are Visible). It
+// also advances the animated tiles of the Levels.
+type TileMapSystem struct {
+	entities []tileMapEntity
+	world    *ecs.World
+	renderer *RenderSystem
+}
+
+type tileMapEntity struct {
+	*ecs.BasicEntity
+	*TileMapComponent
+
+	layers []*tileLayerEntity
+	added  bool // whether or not the layers were added to the RenderSystem
+
+	// margin is the number of cells around the view that is checked for tiles, because tiles which are larger than
+	// the grid can stick out of their cell
+	margin int
+}
+
+// tileLayerEntity is the entity of a single TileLayer, which is drawn by the RenderSystem
+type tileLayerEntity struct {
+	ecs.BasicEntity
+	RenderComponent
+	SpaceComponent
+
+	tiles *tileLayerDrawable
+}
+
+// tileLayerDrawable draws the visible tiles of a TileLayer, as a single Drawable
+type tileLayerDrawable struct {
+	*TileLayer
+	width  int // the number of cells in a row of the Level
+	bounds AABB
+
+	tint     float32     // the color of the tiles, which contains the Opacity of the layer
+	depth    []float32   // the y-coordinate of every cell, which determines the order tiles are drawn in
+	vertices [][]float32 // the quad of every tile; nil for empty cells
+	animated []int       // the cells with an animated tile
+
+	shown   image.Rectangle // the cells that can be seen
+	visible []int           // the non-empty cells within shown, in the order they're drawn
+	buffer  *gl.Buffer      // used to draw the tiles with shaders that don't batch
+}
+
+func (*TileMapSystem) Priority() int { return TileMapSystemPriority }
+
+func (t *TileMapSystem) New(w *ecs.World) {
+	t.world = w
+
+	// A reloaded Level has new layers and tiles, so the layers that draw them are created again
+	Mailbox.Listen("AssetReloadedMessage", func(msg Message) {
+		reloaded, ok := msg.(AssetReloadedMessage)
+		if !ok {
+			return
+		}
+
+		for i := range t.entities {
+			e := &t.entities[i]
+			if reloaded.Resource != e.Level {
+				continue // with other entities
+			}
+
+			if e.added {
+				for _, layer := range e.layers {
+					t.renderer.Remove(layer.BasicEntity)
+				}
+				e.added = false
+			}
+			e.createLayers()
+		}
+	})
+}
+
+// Add makes the TileMapSystem draw the tile layers of the Level of the component
+func (t *TileMapSystem) Add(basic *ecs.BasicEntity, tileMap *TileMapComponent) {
+	e := tileMapEntity{BasicEntity: basic, TileMapComponent: tileMap}
+	e.createLayers()
+	t.entities = append(t.entities, e)
+}
+
+func (t *TileMapSystem) Remove(basic ecs.BasicEntity) {
+	var delete int = -1
+	for index, entity := range t.entities {
+		if entity.ID() == basic.ID() {
+			delete = index
+			break
+		}
+	}
+	if delete >= 0 {
+		if t.entities[delete].added {
+			for _, layer := range t.entities[delete].layers {
+				t.renderer.Remove(layer.BasicEntity)
+			}
+		}
+		t.entities = append(t.entities[:delete], t.entities[delete+1:]...)
+	}
+}
+
+func (t *TileMapSystem) Update(dt float32) {
+	if t.renderer == nil {
+		if t.renderer = t.findRenderer(); t.renderer == nil {
+			return
+		}
+	}
+
+	view := CameraBounds()
+	advanced := make(map[*Level]bool)
+	for i := range t.entities {
+		e := &t.entities[i]
+		if !e.added {
+			for _, layer := range e.layers {
+				t.renderer.Add(&layer.BasicEntity, &layer.RenderComponent, &layer.SpaceComponent)
+			}
+			e.added = true
+		}
+
+		// Every Level is advanced once, even when it's drawn by multiple entities
+		if !advanced[e.Level] {
+			e.Level.UpdateAnimations(dt)
+			advanced[e.Level] = true
+		}
+
+		for _, layer := range e.layers {
+			d := layer.tiles
+			for _, idx := range d.animated {
+				if tl := d.Tiles[idx]; tl.Animation.changed {
+					d.vertices[idx] = tileVertices(tl.CurrentImage(), d.tint)
+				}
+			}
+
+			var cells image.Rectangle
+			if d.Visible {
+				cells = e.visibleCells(view)
+			}
+			if cells != d.shown {
+				d.show(cells)
+			}
+		}
+	}
+}
+
+// findRenderer returns the RenderSystem of the World; it's looked up when it's needed, because it may be added to
+// the World after the TileMapSystem
+func (t *TileMapSystem) findRenderer() *RenderSystem {
+	if t.world == nil {
+		return nil
+	}
+
+	for _, system := range t.world.Systems() {
+		if rs, ok := system.(*RenderSystem); ok {
+			return rs
+		}
+	}
+
+	log.Println("TileMapSystem: the World doesn't have a RenderSystem to draw the tiles")
+	t.world = nil
+	return nil
+}
+
+// createLayers creates the entities of the tile layers of the Level; they're added to the RenderSystem by Update
+func (e *tileMapEntity) createLayers() {
+	lvl := e.Level
+	bounds := lvl.Bounds()
+
+	e.layers = nil
+	e.margin = 0
+	for i, l := range lvl.TileLayers {
+		d := &tileLayerDrawable{
+			TileLayer: l,
+			width:     lvl.Width,
+			bounds:    bounds,
+			tint:      tileTint(l.Opacity),
+			depth:     make([]float32, len(l.Tiles)),
+			vertices:  make([][]float32, len(l.Tiles)),
+		}
+		for j, tl := range l.Tiles {
+			if tl.Image == nil {
+				continue // because the cell is empty
+			}
+
+			d.depth[j] = lvl.TileToWorld(j%lvl.Width+lvl.StartX, j/lvl.Width+lvl.StartY).Y
+			d.vertices[j] = tileVertices(tl.CurrentImage(), d.tint)
+			if tl.Animation != nil {
+				d.animated = append(d.animated, j)
+			}
+
+			size := int(math.Ceil(math.Max(tl.Image.Width()/float32(lvl.TileWidth),
+				tl.Image.Height()/float32(lvl.TileHeight))))
+			if size > e.margin {
+				e.margin = size
+			}
+		}
+
+		le := &tileLayerEntity{BasicEntity: ecs.NewBasic(), tiles: d}
+		le.RenderComponent = NewRenderComponent(d, Point{1, 1}, l.Name)
+		le.RenderComponent.zIndex = e.ZIndex + float32(i)
+		le.SpaceComponent = SpaceComponent{Width: d.Width(), Height: d.Height()}
+		e.layers = append(e.layers, le)
+	}
+}
+
+// visibleCells returns the cells of the map (as indices of the grid, starting at zero) that can contain visible tiles
+func (e *tileMapEntity) visibleCells(view AABB) image.Rectangle {
+	lvl := e.Level
+
+	// On maps which aren't orthogonal, the view covers a rotated or jagged area of cells, so all cells within the
+	// corners are used
+	corners := [4]Point{view.Min, {view.Max.X, view.Min.Y}, view.Max, {view.Min.X, view.Max.Y}}
+	var cells image.Rectangle
+	for i, c := range corners {
+		x, y := lvl.WorldToTile(c)
+		cell := image.Rect(x, y, x+1, y+1)
+		if i == 0 {
+			cells = cell
+		} else {
+			cells = cells.Union(cell)
+		}
+	}
+
+	cells = cells.Inset(-e.margin).Sub(image.Pt(lvl.StartX, lvl.StartY))
+	return cells.Intersect(image.Rect(0, 0, lvl.Width, lvl.Height))
+}
+
+// show makes the layer draw the tiles in the cells, from top to bottom
+func (d *tileLayerDrawable) show(cells image.Rectangle) {
+	d.shown = cells
+	d.visible = d.visible[:0]
+	for y := cells.Min.Y; y < cells.Max.Y; y++ {
+		for x := cells.Min.X; x < cells.Max.X; x++ {
+			if idx := x + y*d.width; idx < len(d.vertices) && d.vertices[idx] != nil {
+				d.visible = append(d.visible, idx)
+			}
+		}
+	}
+	sort.Sort(tileDrawOrder{d.visible, d.depth})
+}
+
+func (d *tileLayerDrawable) Texture() *gl.Texture {
+	return nil
+}
+
+func (d *tileLayerDrawable) Width() float32 {
+	return d.bounds.Max.X - d.bounds.Min.X
+}
+
+func (d *tileLayerDrawable) Height() float32 {
+	return d.bounds.Max.Y - d.bounds.Min.Y
+}
+
+func (d *tileLayerDrawable) View() (float32, float32, float32, float32) {
+	return 0, 0, 1, 1
+}
+
+// draw draws the visible tiles, relative to the position of the layer
+func (d *tileLayerDrawable) draw(shader Shader, x, y float32) {
+	batcher, batched := shader.(BatchShader)
+	for _, idx := range d.visible {
+		tl := d.Tiles[idx]
+		texture := tl.CurrentImage().Texture()
+		if batched {
+			batcher.DrawBatched(texture, d.vertices[idx], x+tl.X, y+tl.Y, tl.Rotation)
+			continue // with other tiles
+		}
+
+		if d.buffer == nil {
+			d.buffer = Gl.CreateBuffer()
+		}
+		Gl.BindBuffer(Gl.ARRAY_BUFFER, d.buffer)
+		Gl.BufferData(Gl.ARRAY_BUFFER, d.vertices[idx], Gl.STATIC_DRAW)
+		shader.Draw(texture, d.buffer, x+tl.X, y+tl.Y, tl.Rotation)
+	}
+}
+
+// tileTint returns the color of tiles on a layer with the given opacity, as it's stored in their vertices
+func tileTint(opacity float32) float32 {
+	alpha := uint32(math.Max(0, math.Min(1, opacity))*255.0) << 24
+	return math.Float32frombits((alpha | 0xffffff) & 0xfeffffff)
+}
+
+// tileVertices returns the quad of a tile, relative to its position, in the same format as a RenderComponent
+func tileVertices(image *Region, tint float32) []float32 {
+	u, v, u2, v2 := image.View()
+	w, h := image.Width(), image.Height()
+	return []float32{0, 0, u, v, tint, w, 0, u2, v, tint, w, h, u2, v2, tint, 0, h, u, v2, tint}
+}
+
+// tileDrawOrder sorts cells by their depth, and then by their index
+type tileDrawOrder struct {
+	cells []int
+	depth []float32
+}
+
+func (o tileDrawOrder) Len() int {
+	return len(o.cells)
+}
+
+func (o tileDrawOrder) Less(i, j int) bool {
+	a, b := o.cells[i], o.cells[j]
+	if o.depth[a] != o.depth[b] {
+		return o.depth[a] < o.depth[b]
+	}
+	return a < b
+}
+
+func (o tileDrawOrder) Swap(i, j int) {
+	o.cells[i], o.cells[j] = o.cells[j], o.cells[i]
+}
@@ -0,0 +1,228 @@
+package engo
+
+import (
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+
+	"engo.io/ecs"
+	"engo.io/gl"
+	"github.com/luxengine/math"
+)
+
+// tileMapTestLevel is 6x2 tiles of 8x8 on the ground layer, and has a 2x1 "walls" layer of solid tiles
+const tileMapTestLevel = `<map width="6" height="2" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" name="tiles" tilewidth="8" tileheight="8">
+  <image source="tiles.png" width="16" height="16"/>
+  <tile id="1"><properties><property name="solid" type="bool" value="true"/></properties></tile>
+ </tileset>
+ <layer name="ground" width="6" height="2">
+  <data encoding="csv">1,1,1,1,1,1,1,0,1,1,1,2</data>
+ </layer>
+ <layer name="walls" width="6" height="2">
+  <data encoding="csv">0,0,3,3,0,0,0,0,3,3,0,0</data>
+ </layer>
+</map>`
+
+func TestTileMapSystemCulling(t *testing.T) {
+	lvl := loadTestLevel(t, tileMapTestLevel)
+
+	oldCam, oldWidth, oldHeight := cam, gameWidth, gameHeight
+	defer func() { cam, gameWidth, gameHeight = oldCam, oldWidth, oldHeight }()
+
+	// The camera sees x=4 until x=12, so the first two columns and one column around them
+	cam = &cameraSystem{x: 8, y: 8, z: 1}
+	gameWidth, gameHeight = 8, 16
+
+	w := &ecs.World{}
+	rs := &RenderSystem{}
+	w.AddSystem(rs)
+	tms := &TileMapSystem{}
+	tms.New(w)
+	w.AddSystem(tms)
+
+	basic := ecs.NewBasic()
+	tms.Add(&basic, &TileMapComponent{Level: lvl, ZIndex: 10})
+	tms.Update(0)
+
+	// Every layer is a single entity
+	if len(rs.entities) != 2 {
+		t.Fatalf("Expected 2 layers in the RenderSystem, got %d", len(rs.entities))
+	}
+
+	shown := func(layer, x, y int) bool {
+		for _, idx := range tms.entities[0].layers[layer].tiles.visible {
+			if idx == x+y*lvl.Width {
+				return true
+			}
+		}
+		return false
+	}
+	for x := 0; x < lvl.Width; x++ {
+		if expected := x < 3; shown(0, x, 0) != expected {
+			t.Errorf("Expected ground tile %d to be shown: %v", x, expected)
+		}
+	}
+	if !shown(1, 2, 0) || shown(1, 3, 0) {
+		t.Error("Expected only the first column of walls to be shown")
+	}
+	if shown(0, 1, 1) {
+		t.Error("Expected the empty cell not to be shown")
+	}
+
+	// The layers are drawn in order
+	if z := tms.entities[0].layers[1].RenderComponent.zIndex; z != 11 {
+		t.Errorf("Expected the walls to have z-index 11, got %v", z)
+	}
+
+	// Moving the camera shows the other tiles, and hides the ones that were shown before
+	cam.x = 40
+	tms.Update(0)
+	if shown(0, 0, 0) || !shown(0, 5, 1) || !shown(0, 4, 0) || shown(1, 2, 0) {
+		t.Error("Expected the visible tiles to follow the camera")
+	}
+
+	// Invisible layers aren't shown at all
+	lvl.TileLayer("ground").Visible = false
+	tms.Update(0)
+	if shown(0, 5, 1) || shown(0, 4, 0) {
+		t.Error("Expected the ground layer to be hidden")
+	}
+
+	tms.Remove(basic)
+	if len(rs.entities) != 0 || len(tms.entities) != 0 {
+		t.Errorf("Expected all layers to be removed, got %d", len(rs.entities))
+	}
+}
+
+// recordingShader is a BatchShader which remembers what it was asked to draw
+type recordingShader struct {
+	positions []Point
+	tints     []float32
+}
+
+func (*recordingShader) Initialize(width, height float32)                               {}
+func (*recordingShader) Pre()                                                           {}
+func (*recordingShader) Post()                                                          {}
+func (*recordingShader) Draw(texture *gl.Texture, buffer *gl.Buffer, x, y, rot float32) {}
+
+func (s *recordingShader) DrawBatched(texture *gl.Texture, vertices []float32, x, y, rotation float32) {
+	s.positions = append(s.positions, Point{x, y})
+	s.tints = append(s.tints, vertices[4])
+}
+
+func TestTileLayerDrawing(t *testing.T) {
+	lvl := loadTestLevel(t, tileMapTestLevel)
+	lvl.TileLayer("walls").Opacity = 0.5
+
+	tms := &TileMapSystem{}
+	basic := ecs.NewBasic()
+	tms.Add(&basic, &TileMapComponent{Level: lvl})
+
+	// Only the visible tiles are drawn, at the position of the layer
+	walls := tms.entities[0].layers[1].tiles
+	walls.show(image.Rect(2, 0, 3, 2))
+	shader := &recordingShader{}
+	walls.draw(shader, 100, 0)
+
+	expected := []Point{{116, 0}, {116, 8}}
+	if !reflect.DeepEqual(shader.positions, expected) {
+		t.Errorf("Expected tiles at %v, got %v", expected, shader.positions)
+	}
+
+	// The opacity of the layer is the alpha of the color of its tiles, of which the lowest bit is always cleared
+	for _, tint := range shader.tints {
+		if alpha := math.Float32bits(tint) >> 24; alpha != 126 {
+			t.Errorf("Expected an alpha of 126, got %d", alpha)
+		}
+	}
+}
+
+func TestTileMapSystemReload(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	oldCam, oldWidth, oldHeight := cam, gameWidth, gameHeight
+	defer func() { cam, gameWidth, gameHeight = oldCam, oldWidth, oldHeight }()
+	cam = &cameraSystem{x: 8, y: 8, z: 1}
+	gameWidth, gameHeight = 8, 16
+
+	fs := MemoryFileSystem{"level.tmx": []byte(tileMapTestLevel), "tiles.png": testPNG(t, 16)}
+	l := NewLoader()
+	l.SetFileSystem(fs)
+	l.Add("tiles.png", "level.tmx")
+	if err := l.TryLoad(); err != nil {
+		t.Fatal(err)
+	}
+	lvl := l.Level("level.tmx")
+
+	w := &ecs.World{}
+	rs := &RenderSystem{}
+	w.AddSystem(rs)
+	tms := &TileMapSystem{}
+	tms.New(w)
+	w.AddSystem(tms)
+
+	basic := ecs.NewBasic()
+	tms.Add(&basic, &TileMapComponent{Level: lvl})
+	tms.Update(0)
+
+	// The new map only has a ground layer, of which the first cell is empty
+	fs["level.tmx"] = []byte(strings.Replace(tileMapTestLevel[:strings.Index(tileMapTestLevel, ` <layer name="walls"`)],
+		"1,1,1,1,1,1,1,0", "0,1,1,1,1,1,1,0", 1) + "</map>")
+	if err := l.reload(l.loaders["tmx"], NewResource("level.tmx")); err != nil {
+		t.Fatal(err)
+	}
+	tms.Update(0)
+
+	if len(rs.entities) != 1 || len(tms.entities[0].layers) != 1 {
+		t.Fatalf("Expected only the layer of the new map to be drawn, got %d", len(rs.entities))
+	}
+	ground := tms.entities[0].layers[0].tiles
+	if ground.TileLayer != lvl.TileLayers[0] {
+		t.Error("Expected the layer of the new map to be drawn")
+	}
+	if !reflect.DeepEqual(ground.visible, []int{1, 2, 6, 8}) {
+		t.Errorf("Expected the new tiles to be shown, got %v", ground.visible)
+	}
+}
+
+func TestLevelCollisionRects(t *testing.T) {
+	lvl := loadTestLevel(t, tileMapTestLevel)
+
+	// The walls are merged into a single 2x2 rectangle
+	walls := lvl.CollisionRects("walls", "")
+	if len(walls) != 1 || walls[0] != (AABB{Point{16, 0}, Point{32, 16}}) {
+		t.Errorf("Unexpected walls: %v", walls)
+	}
+
+	// Only tile 2, which is the last ground tile, has the solid property
+	solid := lvl.SolidCells("", "solid")
+	for i, s := range solid {
+		if expected := i == 11; s != expected {
+			t.Errorf("Expected cell %d to be solid: %v", i, expected)
+		}
+	}
+
+	// The ground has a hole at the second cell of the second row
+	ground := lvl.CollisionRects("ground", "")
+	expected := []AABB{
+		{Point{0, 0}, Point{48, 8}},
+		{Point{0, 8}, Point{8, 16}},
+		{Point{16, 8}, Point{48, 16}},
+	}
+	if len(ground) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, ground)
+	}
+	for i := range expected {
+		if ground[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], ground[i])
+		}
+	}
+
+	entities := lvl.CollisionEntities("walls", "solid")
+	if len(entities) != 2 || !entities[0].Solid || entities[0].Width != 16 || entities[0].Height != 16 {
+		t.Errorf("Unexpected collision entities: %v", entities)
+	}
+}
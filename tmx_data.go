@@ -0,0 +1,139 @@
+package engo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// decodeTileData decodes the gids of a layer or chunk, using any of the encodings and compressions Tiled supports
+func decodeTileData(encoding, compression, text string, tiles []TMXDataTile) ([]uint32, error) {
+	switch encoding {
+	case "":
+		// Without encoding, every tile is an XML element
+		gids := make([]uint32, len(tiles))
+		for i, t := range tiles {
+			gids[i] = t.Gid
+		}
+		return gids, nil
+	case "csv":
+		return decodeCSV(text)
+	case "base64":
+		return decodeBase64(compression, text)
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
+
+// decodeCSV decodes comma-separated gids, which may be spread over multiple lines
+func decodeCSV(text string) ([]uint32, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return []uint32{}, nil
+	}
+
+	fields := strings.Split(text, ",")
+	gids := make([]uint32, len(fields))
+	for i, field := range fields {
+		gid, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv data: %v", err)
+		}
+		gids[i] = uint32(gid)
+	}
+	return gids, nil
+}
+
+// decodeBase64 decodes base64 encoded gids, which may be compressed with gzip or zlib
+func decodeBase64(compression, text string) ([]uint32, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 data: %v", err)
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	switch compression {
+	case "":
+	case "gzip":
+		if r, err = gzip.NewReader(r); err != nil {
+			return nil, fmt.Errorf("invalid gzip data: %v", err)
+		}
+	case "zlib":
+		if r, err = zlib.NewReader(r); err != nil {
+			return nil, fmt.Errorf("invalid zlib data: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+
+	if data, err = ioutil.ReadAll(r); err != nil {
+		return nil, fmt.Errorf("invalid %s data: %v", compression, err)
+	}
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("data of %d bytes isn't a multiple of 4 bytes", len(data))
+	}
+
+	gids := make([]uint32, len(data)/4)
+	for i := range gids {
+		gids[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return gids, nil
+}
+
+// decodeChunks fills the layers of an infinite map. The size of the map becomes the area covered by the chunks of
+// all layers, of which the top-left tile is at StartX, StartY.
+func decodeChunks(tlvl *TMXLevel) error {
+	first := true
+	var minX, minY, maxX, maxY int
+	for _, layer := range tlvl.Layers {
+		for _, c := range layer.Data.Chunks {
+			if first || c.X < minX {
+				minX = c.X
+			}
+			if first || c.Y < minY {
+				minY = c.Y
+			}
+			if first || c.X+c.Width > maxX {
+				maxX = c.X + c.Width
+			}
+			if first || c.Y+c.Height > maxY {
+				maxY = c.Y + c.Height
+			}
+			first = false
+		}
+	}
+
+	tlvl.StartX, tlvl.StartY = minX, minY
+	tlvl.Width, tlvl.Height = maxX-minX, maxY-minY
+
+	for idx := range tlvl.Layers {
+		layer := &tlvl.Layers[idx]
+		layer.Width, layer.Height = tlvl.Width, tlvl.Height
+		layer.TileMapping = make([]uint32, tlvl.Width*tlvl.Height)
+
+		for _, c := range layer.Data.Chunks {
+			gids, err := decodeTileData(layer.Data.Encoding, layer.Data.Compression, c.Text, c.Tiles)
+			if err != nil {
+				return fmt.Errorf("layer %q, chunk at %d,%d: %v", layer.Name, c.X, c.Y, err)
+			}
+			if len(gids) != c.Width*c.Height {
+				return fmt.Errorf("layer %q, chunk at %d,%d has %d tiles, instead of %dx%d", layer.Name, c.X, c.Y,
+					len(gids), c.Width, c.Height)
+			}
+
+			for y := 0; y < c.Height; y++ {
+				row := (c.Y-minY+y)*tlvl.Width + c.X - minX
+				copy(layer.TileMapping[row:row+c.Width], gids[y*c.Width:(y+1)*c.Width])
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,17 +1,12 @@
 package engo
 
 import (
-	"bytes"
-	"compress/zlib"
-	"encoding/base64"
-	"encoding/binary"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
-	"io/ioutil"
 	"path"
 	"sort"
-	"strconv"
-	"strings"
+	"time"
 )
 
 // Just used to create levelTileset->Image
@@ -21,22 +16,78 @@ type TMXTilesetSrc struct {
 	Height int    `xml:"height,attr"`
 }
 
+// TMXProperty is a custom property; multi-line values are stored as the text of the element instead of its value
+type TMXProperty struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"`
+}
+
+// TMXTile contains the custom type and properties of a single tile of a tileset
+type TMXTile struct {
+	ID          int           `xml:"id,attr"`
+	Type        string        `xml:"type,attr"`
+	Class       string        `xml:"class,attr"`
+	Properties  []TMXProperty `xml:"properties>property"`
+	ObjectGroup *TMXObjGroup  `xml:"objectgroup"`
+	Animation   []TMXFrame    `xml:"animation>frame"`
+}
+
+// TMXFrame is a single frame of the animation of a tile; the duration is in milliseconds
+type TMXFrame struct {
+	TileID   int `xml:"tileid,attr"`
+	Duration int `xml:"duration,attr"`
+}
+
 type TMXTileset struct {
 	Firstgid   int           `xml:"firstgid,attr"`
+	Source     string        `xml:"source,attr"`
 	Name       string        `xml:"name,attr"`
 	TileWidth  int           `xml:"tilewidth,attr"`
 	TileHeight int           `xml:"tileheight,attr"`
+	Spacing    int           `xml:"spacing,attr"`
+	Margin     int           `xml:"margin,attr"`
 	ImageSrc   TMXTilesetSrc `xml:"image"`
+	Properties []TMXProperty `xml:"properties>property"`
+	Tiles      []TMXTile     `xml:"tile"`
 	Image      *Texture
 }
 
 type TMXLayer struct {
-	Name        string `xml:"name,attr"`
-	Width       int    `xml:"width,attr"`
-	Height      int    `xml:"height,attr"`
+	Name        string        `xml:"name,attr"`
+	Width       int           `xml:"width,attr"`
+	Height      int           `xml:"height,attr"`
+	Opacity     *float64      `xml:"opacity,attr"`
+	Visible     *int          `xml:"visible,attr"`
+	Properties  []TMXProperty `xml:"properties>property"`
 	TileMapping []uint32
 	// This variable doesn't need to persist, used to fill TileMapping
-	CompData []byte `xml:"data"`
+	Data TMXData `xml:"data"`
+}
+
+// TMXData contains the tiles of a layer, either directly or split into chunks for infinite maps
+type TMXData struct {
+	Encoding    string        `xml:"encoding,attr"`
+	Compression string        `xml:"compression,attr"`
+	Text        string        `xml:",chardata"`
+	Tiles       []TMXDataTile `xml:"tile"`
+	Chunks      []TMXChunk    `xml:"chunk"`
+}
+
+// TMXDataTile is a single tile of a layer without encoding
+type TMXDataTile struct {
+	Gid uint32 `xml:"gid,attr"`
+}
+
+// TMXChunk is a rectangular part of a layer of an infinite map; its location is in tiles
+type TMXChunk struct {
+	X      int           `xml:"x,attr"`
+	Y      int           `xml:"y,attr"`
+	Width  int           `xml:"width,attr"`
+	Height int           `xml:"height,attr"`
+	Text   string        `xml:",chardata"`
+	Tiles  []TMXDataTile `xml:"tile"`
 }
 
 type TMXPolyline struct {
@@ -44,14 +95,33 @@ type TMXPolyline struct {
 }
 
 type TMXObj struct {
-	X         float64       `xml:"x,attr"`
-	Y         float64       `xml:"y,attr"`
-	Polylines []TMXPolyline `xml:"polyline"`
+	ID         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	Rotation   float64       `xml:"rotation,attr"`
+	Gid        uint32        `xml:"gid,attr"`
+	Visible    *int          `xml:"visible,attr"`
+	Ellipse    *struct{}     `xml:"ellipse"`
+	Point      *struct{}     `xml:"point"`
+	Polygons   []TMXPolyline `xml:"polygon"`
+	Polylines  []TMXPolyline `xml:"polyline"`
+	Properties []TMXProperty `xml:"properties>property"`
 }
 
 type TMXObjGroup struct {
-	Name    string   `xml:"name,attr"`
-	Objects []TMXObj `xml:"object"`
+	Name       string        `xml:"name,attr"`
+	Color      string        `xml:"color,attr"`
+	Opacity    *float64      `xml:"opacity,attr"`
+	Visible    *int          `xml:"visible,attr"`
+	OffsetX    float64       `xml:"offsetx,attr"`
+	OffsetY    float64       `xml:"offsety,attr"`
+	Properties []TMXProperty `xml:"properties>property"`
+	Objects    []TMXObj      `xml:"object"`
 }
 
 type TMXImgSrc struct {
@@ -59,21 +129,31 @@ type TMXImgSrc struct {
 }
 
 type TMXImgLayer struct {
-	Name   string    `xml:"name,attr"`
-	X      float64   `xml:"x,attr"`
-	Y      float64   `xml:"y,attr"`
-	ImgSrc TMXImgSrc `xml:"image"`
+	Name       string        `xml:"name,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	ImgSrc     TMXImgSrc     `xml:"image"`
+	Properties []TMXProperty `xml:"properties>property"`
 }
 
 type TMXLevel struct {
-	Width      int           `xml:"width,attr"`
-	Height     int           `xml:"height,attr"`
-	TileWidth  int           `xml:"tilewidth,attr"`
-	TileHeight int           `xml:"tileheight,attr"`
-	Tilesets   []TMXTileset  `xml:"tileset"`
-	Layers     []TMXLayer    `xml:"layer"`
-	ObjGroups  []TMXObjGroup `xml:"objectgroup"`
-	ImgLayers  []TMXImgLayer `xml:"imagelayer"`
+	Orientation   string        `xml:"orientation,attr"`
+	StaggerAxis   string        `xml:"staggeraxis,attr"`
+	StaggerIndex  string        `xml:"staggerindex,attr"`
+	HexSideLength int           `xml:"hexsidelength,attr"`
+	Width         int           `xml:"width,attr"`
+	Height        int           `xml:"height,attr"`
+	TileWidth     int           `xml:"tilewidth,attr"`
+	TileHeight    int           `xml:"tileheight,attr"`
+	Infinite      int           `xml:"infinite,attr"`
+	Properties    []TMXProperty `xml:"properties>property"`
+	Tilesets      []TMXTileset  `xml:"tileset"`
+	Layers        []TMXLayer    `xml:"layer"`
+	ObjGroups     []TMXObjGroup `xml:"objectgroup"`
+	ImgLayers     []TMXImgLayer `xml:"imagelayer"`
+
+	// StartX and StartY are the location of the top-left tile of an infinite map, in tiles
+	StartX, StartY int `xml:"-"`
 }
 
 type ByFirstgid []TMXTileset
@@ -82,60 +162,98 @@ func (t ByFirstgid) Len() int           { return len(t) }
 func (t ByFirstgid) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
 func (t ByFirstgid) Less(i, j int) bool { return t[i].Firstgid < t[j].Firstgid }
 
-// MUST BE base64 ENCODED and COMPRESSED WITH zlib!
-func createLevelFromTmx(r Resource) (*Level, error) {
+// parseTmx reads and decodes the TMX file, and the external tilesets it uses. It doesn't use any textures, so it can
+// be called from any goroutine.
+func parseTmx(fs FileSystem, r Resource) (*TMXLevel, error) {
 	tlvl := &TMXLevel{}
-	lvl := &Level{}
 
-	tmx, err := readTmx(r.url)
+	tmx, err := readTmx(fs, r.url)
 	if err != nil {
-		return lvl, err
+		return tlvl, err
 	}
 
 	if err := xml.Unmarshal([]byte(tmx), &tlvl); err != nil {
-		fmt.Printf("Error unmarshalling XML: %v", err)
+		return tlvl, err
 	}
 
-	// Extract the tile mappings from the compressed data at each layer
-	for idx := range tlvl.Layers {
-		layer := &tlvl.Layers[idx]
+	if err := readTilesets(fs, path.Dir(r.url), tlvl.Tilesets); err != nil {
+		return tlvl, err
+	}
 
-		// Trim leading/trailing whitespace ( inneficient )
-		layer.CompData = []byte(strings.TrimSpace(string(layer.CompData)))
+	return tlvl, decodeLayers(tlvl)
+}
 
-		// Decode it out of base64
-		if n, err := base64.StdEncoding.Decode(layer.CompData, layer.CompData); err != nil {
-			fmt.Printf("error after %d bytes: %v", n, err)
-			return lvl, err
+// readTilesets replaces the tilesets which refer to an external .tsx or .tsj file by the contents of that file; dir
+// is the directory of the map, which the references are relative to
+func readTilesets(fs FileSystem, dir string, tilesets []TMXTileset) error {
+	for i, ts := range tilesets {
+		if ts.Source == "" {
+			continue // because the tileset is embedded in the map
 		}
 
-		// Decompress
-		b := bytes.NewReader(layer.CompData)
-		zlr, err := zlib.NewReader(b)
+		data, err := readFile(fs, path.Join(dir, ts.Source))
 		if err != nil {
-			fmt.Printf("error: %v", err)
-			return lvl, err
+			return fmt.Errorf("tileset %q: %v", ts.Source, err)
 		}
 
-		tm := make([]uint32, 0)
-		var nextInt uint32
-		for {
-			err = binary.Read(zlr, binary.LittleEndian, &nextInt)
-			if err != nil {
-				// this is -generally- EOF
-				//fmt.Println("binary.Read failed:", err)
-				break
+		var external TMXTileset
+		switch path.Ext(ts.Source) {
+		case ".tsx", ".xml":
+			err = xml.Unmarshal(data, &external)
+		case ".tsj", ".json":
+			var jts tiledJSONTileset
+			if err = json.Unmarshal(data, &jts); err == nil {
+				external = jts.tmx()
 			}
-			tm = append(tm, nextInt)
+		default:
+			err = fmt.Errorf("unknown tileset format %q", path.Ext(ts.Source))
 		}
-		layer.TileMapping = tm
+		if err != nil {
+			return fmt.Errorf("tileset %q: %v", ts.Source, err)
+		}
+
+		// The first gid depends on the map using the tileset, so it's only stored in the map
+		external.Firstgid = ts.Firstgid
+		external.Source = ts.Source
+		tilesets[i] = external
+	}
+	return nil
+}
 
-		zlr.Close()
+// decodeLayers extracts the tile mappings from the data at each layer
+func decodeLayers(tlvl *TMXLevel) error {
+	if tlvl.Infinite != 0 {
+		return decodeChunks(tlvl)
 	}
 
+	for idx := range tlvl.Layers {
+		layer := &tlvl.Layers[idx]
+
+		tm, err := decodeTileData(layer.Data.Encoding, layer.Data.Compression, layer.Data.Text, layer.Data.Tiles)
+		if err != nil {
+			return fmt.Errorf("layer %q: %v", layer.Name, err)
+		}
+		if len(tm) != layer.Width*layer.Height {
+			return fmt.Errorf("layer %q has %d tiles, instead of %dx%d", layer.Name, len(tm), layer.Width,
+				layer.Height)
+		}
+		layer.TileMapping = tm
+	}
+
+	return nil
+}
+
+// createLevelFromTmx creates the Level from a parsed TMX file; the images it uses have to be loaded by l already
+func createLevelFromTmx(l *Loader, tlvl *TMXLevel) (*Level, error) {
+	lvl := &Level{}
+
 	// Load in the images needed for the tilesets
 	for k, ts := range tlvl.Tilesets {
-		ts.Image = Files.Image(path.Base(ts.ImageSrc.Source))
+		img, err := l.TryImage(path.Base(ts.ImageSrc.Source))
+		if err != nil {
+			return nil, fmt.Errorf("tileset %q: %v", ts.Name, err)
+		}
+		ts.Image = img
 		tlvl.Tilesets[k] = ts
 	}
 
@@ -143,68 +261,131 @@ func createLevelFromTmx(r Resource) (*Level, error) {
 	lvl.Height = tlvl.Height
 	lvl.TileWidth = tlvl.TileWidth
 	lvl.TileHeight = tlvl.TileHeight
+	lvl.StartX = tlvl.StartX
+	lvl.StartY = tlvl.StartY
+	lvl.StaggerX = tlvl.StaggerAxis == "x"
+	lvl.StaggerEven = tlvl.StaggerIndex == "even"
+	lvl.HexSideLength = tlvl.HexSideLength
+
+	orientation, err := parseOrientation(tlvl.Orientation)
+	if err != nil {
+		return nil, err
+	}
+	lvl.Orientation = orientation
+	lvl.Properties = newProperties(tlvl.Properties)
 
 	// get the tilesheets in order and in generic format
 	sort.Sort(ByFirstgid(tlvl.Tilesets))
 	ts := make([]*tilesheet, len(tlvl.Tilesets))
 	for i, tts := range tlvl.Tilesets {
-		ts[i] = &tilesheet{tts.Image, tts.Firstgid}
+		ts[i] = &tilesheet{
+			Image:      tts.Image,
+			Firstgid:   tts.Firstgid,
+			TileWidth:  tts.TileWidth,
+			TileHeight: tts.TileHeight,
+			Spacing:    tts.Spacing,
+			Margin:     tts.Margin,
+		}
+
+		tileset, err := newTileset(tts)
+		if err != nil {
+			return nil, fmt.Errorf("tileset %q: %v", tts.Name, err)
+		}
+		lvl.Tilesets = append(lvl.Tilesets, tileset)
 	}
 
 	lvlTileset := createTileset(lvl, ts)
 
-	lvlLayers := make([]*layer, len(tlvl.Layers))
-	for i, tls := range tlvl.Layers {
-		lvlLayers[i] = &layer{tls.Name, tls.TileMapping}
+	for _, tls := range tlvl.Layers {
+		tiles := createLayerTiles(lvl, &layer{tls.Name, tls.TileMapping}, lvlTileset)
+		lvl.Tiles = append(lvl.Tiles, tiles...)
+		lvl.TileLayers = append(lvl.TileLayers, &TileLayer{
+			Name:       tls.Name,
+			Width:      tls.Width,
+			Height:     tls.Height,
+			Visible:    tmxVisible(tls.Visible),
+			Opacity:    tmxOpacity(tls.Opacity),
+			Properties: newProperties(tls.Properties),
+			Tiles:      tiles,
+		})
 	}
 
-	lvl.Tiles = createLevelTiles(lvl, lvlLayers, lvlTileset)
+	for _, tgroup := range tlvl.ObjGroups {
+		group, err := newObjectGroup(tgroup)
+		if err != nil {
+			return nil, err
+		}
+		lvl.ObjectGroups = append(lvl.ObjectGroups, group)
 
-	for _, o := range tlvl.ObjGroups[0].Objects {
-		p := o.Polylines[0].Points
-		lvl.LineBounds = append(lvl.LineBounds, pointStringToLines(p, o.X, o.Y)...)
+		for _, o := range group.Objects {
+			if o.Kind != PolylineObject {
+				continue // because only polylines are used as bounds
+			}
+			lvl.LineBounds = append(lvl.LineBounds, o.Lines()...)
+		}
 	}
 
-	for i := 0; i < len(tlvl.ImgLayers); i++ {
-		curImg := Files.Image(path.Base(tlvl.ImgLayers[i].ImgSrc.Source))
-		curX := float32(tlvl.ImgLayers[i].X)
-		curY := float32(tlvl.ImgLayers[i].Y)
+	for _, timg := range tlvl.ImgLayers {
+		curImg, err := l.TryImage(path.Base(timg.ImgSrc.Source))
+		if err != nil {
+			return nil, fmt.Errorf("image layer %q: %v", timg.Name, err)
+		}
 		reg := NewRegion(curImg, 0, 0, curImg.width, curImg.height)
-		lvl.Images = append(lvl.Images, &tile{Point{curX, curY}, reg})
+		t := &tile{Point: Point{float32(timg.X), float32(timg.Y)}, Image: reg}
+		lvl.Images = append(lvl.Images, t)
+		lvl.ImageLayers = append(lvl.ImageLayers, &ImageLayer{
+			Name:       timg.Name,
+			Properties: newProperties(timg.Properties),
+			Image:      t,
+		})
 	}
 
 	return lvl, nil
 }
 
-func pointStringToLines(str string, xOff, yOff float64) []Line {
-	pts := strings.Split(str, " ")
-	floatPts := make([][]float64, len(pts))
-	for i, x := range pts {
-		pt := strings.Split(x, ",")
-		floatPts[i] = make([]float64, 2)
-		floatPts[i][0], _ = strconv.ParseFloat(pt[0], 64)
-		floatPts[i][1], _ = strconv.ParseFloat(pt[1], 64)
+// newTileset converts a tileset of a TMX file, of which the image has been loaded
+func newTileset(tmx TMXTileset) (*Tileset, error) {
+	ts := &Tileset{
+		Name:       tmx.Name,
+		Firstgid:   tmx.Firstgid,
+		TileWidth:  tmx.TileWidth,
+		TileHeight: tmx.TileHeight,
+		Spacing:    tmx.Spacing,
+		Margin:     tmx.Margin,
+		Image:      tmx.Image,
+		Properties: newProperties(tmx.Properties),
+		Tiles:      make(map[int]*TileInfo, len(tmx.Tiles)),
 	}
 
-	lines := make([]Line, len(floatPts)-1)
-
-	// Now to globalize line coordinates
-	for i := 0; i < len(floatPts)-1; i++ {
-		x1 := float32(floatPts[i][0] + xOff)
-		y1 := float32(floatPts[i][1] + yOff)
-		x2 := float32(floatPts[i+1][0] + xOff)
-		y2 := float32(floatPts[i+1][1] + yOff)
-		p1 := Point{x1, y1}
-		p2 := Point{x2, y2}
-		newLine := Line{p1, p2}
-		lines = append(lines, newLine)
+	for _, t := range tmx.Tiles {
+		info := &TileInfo{ID: t.ID, Type: t.Type, Properties: newProperties(t.Properties)}
+		if info.Type == "" {
+			info.Type = t.Class
+		}
+
+		if t.ObjectGroup != nil {
+			shapes, err := newObjectGroup(*t.ObjectGroup)
+			if err != nil {
+				return nil, fmt.Errorf("tile %d: %v", t.ID, err)
+			}
+			info.Shapes = shapes.Objects
+		}
+
+		for _, f := range t.Animation {
+			info.Animation = append(info.Animation, TileFrame{
+				TileID:   f.TileID,
+				Duration: time.Duration(f.Duration) * time.Millisecond,
+			})
+		}
+
+		ts.Tiles[t.ID] = info
 	}
 
-	return lines
+	return ts, nil
 }
 
-func readTmx(url string) (string, error) {
-	file, err := ioutil.ReadFile(url)
+func readTmx(fs FileSystem, url string) (string, error) {
+	file, err := readFile(fs, url)
 	if err != nil {
 		return "", err
 	}
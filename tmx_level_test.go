@@ -0,0 +1,375 @@
+package engo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"path"
+	"testing"
+)
+
+// testLayerData encodes the gids like Tiled does by default: zlib compressed and base64 encoded
+func testLayerData(t *testing.T, gids ...uint32) string {
+	return testCompressed(t, "zlib", gids...)
+}
+
+// loadTestLevel loads the TMX file, which can use the 16x16 "tiles.png" and the 8x8 "background.png"
+func loadTestLevel(t *testing.T, tmx string) *Level {
+	return loadTestFiles(t, "level.tmx", MemoryFileSystem{"level.tmx": []byte(tmx)})
+}
+
+// loadTestFiles loads the level at the URL from the files, and all images among them; it can also use the 16x16
+// "tiles.png" and the 8x8 "background.png"
+func loadTestFiles(t *testing.T, name string, files MemoryFileSystem) *Level {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	files["tiles.png"] = testPNG(t, 16)
+	files["background.png"] = testPNG(t, 8)
+
+	l := NewLoader()
+	l.SetFileSystem(files)
+	for file := range files {
+		if path.Ext(file) == ".png" {
+			l.Add(file)
+		}
+	}
+	l.Add(name)
+	if err := l.TryLoad(); err != nil {
+		t.Fatal(err)
+	}
+
+	lvl, err := l.TryLevel(path.Base(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lvl
+}
+
+func TestTmxObjectGroups(t *testing.T) {
+	lvl := loadTestLevel(t, `<?xml version="1.0" encoding="UTF-8"?>
+<map width="2" height="2" tilewidth="8" tileheight="8">
+ <properties>
+  <property name="music" value="theme.wav"/>
+  <property name="gravity" type="float" value="9.8"/>
+ </properties>
+ <tileset firstgid="1" name="tiles" tilewidth="8" tileheight="8">
+  <properties>
+   <property name="solid" type="bool" value="true"/>
+  </properties>
+  <image source="tiles.png" width="16" height="16"/>
+  <tile id="2" type="water">
+   <properties>
+    <property name="depth" type="int" value="3"/>
+   </properties>
+  </tile>
+ </tileset>
+ <layer name="ground" width="2" height="2" opacity="0.5">
+  <properties>
+   <property name="z" type="int" value="-1"/>
+  </properties>
+  <data encoding="base64" compression="zlib">`+testLayerData(t, 1, 0, 3, 4)+`</data>
+ </layer>
+ <objectgroup name="spawns" visible="0">
+  <object id="1" name="player" type="spawn" x="4" y="12"/>
+ </objectgroup>
+ <objectgroup name="shapes" offsetx="2" offsety="3">
+  <properties>
+   <property name="note">first line
+second line</property>
+  </properties>
+  <object id="2" name="box" x="1" y="2" width="3" height="4" rotation="90">
+   <properties>
+    <property name="health" type="int" value="10"/>
+   </properties>
+  </object>
+  <object id="3" name="round" class="pickup" x="0" y="0" width="5" height="5">
+   <ellipse/>
+  </object>
+  <object id="4" name="dot" x="6" y="7">
+   <point/>
+  </object>
+  <object id="5" name="triangle" x="10" y="10">
+   <polygon points="0,0 4,0 0,4"/>
+  </object>
+  <object id="6" name="wall" x="0" y="16">
+   <polyline points="0,0 16,0 16,-16"/>
+  </object>
+  <object id="7" name="crate" gid="2" x="8" y="16" width="8" height="8"/>
+ </objectgroup>
+ <imagelayer name="sky" x="1" y="2">
+  <image source="background.png"/>
+  <properties>
+   <property name="parallax" type="float" value="0.5"/>
+  </properties>
+ </imagelayer>
+</map>`)
+
+	if lvl.Properties["music"] != "theme.wav" || lvl.Properties.Float("gravity") != 9.8 {
+		t.Errorf("Unexpected map properties: %v", lvl.Properties)
+	}
+
+	if len(lvl.Tilesets) != 1 || !lvl.Tilesets[0].Properties.Bool("solid") {
+		t.Fatalf("Unexpected tilesets: %v", lvl.Tilesets)
+	}
+	if info := lvl.TileInfo(3); info == nil || info.Type != "water" || info.Properties.Int("depth") != 3 {
+		t.Errorf("Unexpected info of tile 3: %v", info)
+	}
+	if info := lvl.TileInfo(1); info != nil {
+		t.Errorf("Expected no info for tile 1, got %v", info)
+	}
+
+	ground := lvl.TileLayer("ground")
+	if ground == nil || !ground.Visible || ground.Opacity != 0.5 || ground.Properties.Int("z") != -1 {
+		t.Fatalf("Unexpected tile layer: %v", ground)
+	}
+	if len(ground.Tiles) != 4 || ground.Tiles[0].Image == nil || ground.Tiles[1].Image != nil {
+		t.Errorf("Unexpected tiles: %v", ground.Tiles)
+	}
+	if len(lvl.Tiles) != 4 {
+		t.Errorf("Expected 4 tiles in the level, got %d", len(lvl.Tiles))
+	}
+
+	if len(lvl.ObjectGroups) != 2 {
+		t.Fatalf("Expected 2 object groups, got %d", len(lvl.ObjectGroups))
+	}
+	spawns := lvl.ObjectGroup("spawns")
+	if spawns.Visible || spawns.Opacity != 1 {
+		t.Errorf("Unexpected visibility of spawns: %v, %v", spawns.Visible, spawns.Opacity)
+	}
+	if player := spawns.Object("player"); player == nil || player.Type != "spawn" || player.Kind != RectangleObject ||
+		player.Position != (Point{4, 12}) {
+		t.Errorf("Unexpected player: %v", player)
+	}
+
+	shapes := lvl.ObjectGroup("shapes")
+	if shapes.Offset != (Point{2, 3}) || shapes.Properties["note"] != "first line\nsecond line" {
+		t.Errorf("Unexpected shapes group: %v, %q", shapes.Offset, shapes.Properties["note"])
+	}
+
+	kinds := map[string]ObjectKind{
+		"box":      RectangleObject,
+		"round":    EllipseObject,
+		"dot":      PointObject,
+		"triangle": PolygonObject,
+		"wall":     PolylineObject,
+		"crate":    TileObject,
+	}
+	for name, kind := range kinds {
+		if o := shapes.Object(name); o == nil || o.Kind != kind {
+			t.Errorf("Expected %s to be of kind %d, got %v", name, kind, o)
+		}
+	}
+
+	box := shapes.Object("box")
+	if box.ID != 2 || box.Width != 3 || box.Height != 4 || box.Rotation != 90 || box.Properties.Int("health") != 10 {
+		t.Errorf("Unexpected box: %v", box)
+	}
+	if round := shapes.ObjectsOfType("pickup"); len(round) != 1 || round[0].Name != "round" {
+		t.Errorf("Expected the class to be used as type, got %v", round)
+	}
+	if crate := shapes.Object("crate"); crate.Gid != 2 {
+		t.Errorf("Expected gid 2, got %d", crate.Gid)
+	}
+
+	triangle := shapes.Object("triangle").Lines()
+	expected := []Line{
+		{Point{10, 10}, Point{14, 10}},
+		{Point{14, 10}, Point{10, 14}},
+		{Point{10, 14}, Point{10, 10}},
+	}
+	if len(triangle) != len(expected) {
+		t.Fatalf("Expected a closed triangle, got %v", triangle)
+	}
+	for i := range expected {
+		if triangle[i] != expected[i] {
+			t.Errorf("Expected line %d to be %v, got %v", i, expected[i], triangle[i])
+		}
+	}
+
+	// Only polylines are used as bounds
+	bounds := []Line{
+		{Point{0, 16}, Point{16, 16}},
+		{Point{16, 16}, Point{16, 0}},
+	}
+	if len(lvl.LineBounds) != len(bounds) {
+		t.Fatalf("Expected %d line bounds, got %v", len(bounds), lvl.LineBounds)
+	}
+	for i := range bounds {
+		if lvl.LineBounds[i] != bounds[i] {
+			t.Errorf("Expected bound %d to be %v, got %v", i, bounds[i], lvl.LineBounds[i])
+		}
+	}
+
+	if len(lvl.ImageLayers) != 1 || lvl.ImageLayers[0].Properties.Float("parallax") != 0.5 ||
+		lvl.ImageLayers[0].Image.Point != (Point{1, 2}) || len(lvl.Images) != 1 {
+		t.Errorf("Unexpected image layers: %v", lvl.ImageLayers)
+	}
+}
+
+func TestTmxWithoutObjects(t *testing.T) {
+	lvl := loadTestLevel(t, `<?xml version="1.0" encoding="UTF-8"?>
+<map width="1" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" name="tiles" tilewidth="8" tileheight="8">
+  <image source="tiles.png" width="16" height="16"/>
+ </tileset>
+ <layer name="ground" width="1" height="1">
+  <data encoding="base64" compression="zlib">`+testLayerData(t, 1)+`</data>
+ </layer>
+</map>`)
+
+	if len(lvl.ObjectGroups) != 0 || len(lvl.LineBounds) != 0 {
+		t.Errorf("Expected no objects, got %v and %v", lvl.ObjectGroups, lvl.LineBounds)
+	}
+}
+
+func TestObjectRotatedLines(t *testing.T) {
+	o := &Object{
+		Kind:     PolylineObject,
+		Position: Point{10, 10},
+		Rotation: 90,
+		Points:   []Point{{0, 0}, {4, 0}},
+	}
+
+	lines := o.Lines()
+	if len(lines) != 1 {
+		t.Fatalf("Expected one line, got %v", lines)
+	}
+	if !pointsAlmostEqual(lines[0].P1, Point{10, 10}) || !pointsAlmostEqual(lines[0].P2, Point{10, 14}) {
+		t.Errorf("Expected the line to be rotated clockwise, got %v", lines[0])
+	}
+}
+
+// testCompressed encodes the gids with base64, after compressing them with gzip, zlib or nothing
+func testCompressed(t *testing.T, compression string, gids ...uint32) string {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch compression {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	default:
+		w = nopWriteCloser{&buf}
+	}
+	if err := binary.Write(w, binary.LittleEndian, gids); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestTmxEncodings(t *testing.T) {
+	expected := []uint32{1, 0, 3, 4}
+	data := map[string]string{
+		"xml":    `<data><tile gid="1"/><tile/><tile gid="3"/><tile gid="4"/></data>`,
+		"csv":    "<data encoding=\"csv\">\n1,0,\n3,4\n</data>",
+		"base64": `<data encoding="base64">` + testCompressed(t, "", expected...) + `</data>`,
+		"gzip":   `<data encoding="base64" compression="gzip">` + testCompressed(t, "gzip", expected...) + `</data>`,
+		"zlib":   `<data encoding="base64" compression="zlib">` + testCompressed(t, "zlib", expected...) + `</data>`,
+	}
+
+	for name, d := range data {
+		tlvl, err := parseTestTmx(`<map width="2" height="2" tilewidth="8" tileheight="8">
+ <layer name="ground" width="2" height="2">` + d + `</layer>
+</map>`)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue // with other encodings
+		}
+		if len(tlvl.Layers[0].TileMapping) != len(expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, tlvl.Layers[0].TileMapping)
+			continue // with other encodings
+		}
+		for i := range expected {
+			if tlvl.Layers[0].TileMapping[i] != expected[i] {
+				t.Errorf("%s: expected %v, got %v", name, expected, tlvl.Layers[0].TileMapping)
+				break
+			}
+		}
+	}
+}
+
+func TestTmxMalformedData(t *testing.T) {
+	data := map[string]string{
+		"csv":         `<data encoding="csv">1,x,3,4</data>`,
+		"base64":      `<data encoding="base64">not base64!</data>`,
+		"zlib":        `<data encoding="base64" compression="zlib">` + testCompressed(t, "", 1, 2, 3, 4) + `</data>`,
+		"compression": `<data encoding="base64" compression="lzma">` + testCompressed(t, "", 1, 2, 3, 4) + `</data>`,
+		"encoding":    `<data encoding="hex">01020304</data>`,
+		"size":        `<data encoding="csv">1,2,3</data>`,
+		"bytes":       `<data encoding="base64">AQID</data>`,
+	}
+
+	for name, d := range data {
+		_, err := parseTestTmx(`<map width="2" height="2" tilewidth="8" tileheight="8">
+ <layer name="ground" width="2" height="2">` + d + `</layer>
+</map>`)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if _, err := parseTestTmx(`<map width="2"`); err == nil {
+		t.Error("Expected an error for invalid XML")
+	}
+}
+
+func TestTmxInfiniteMap(t *testing.T) {
+	lvl := loadTestLevel(t, `<?xml version="1.0" encoding="UTF-8"?>
+<map width="10" height="10" tilewidth="8" tileheight="8" infinite="1">
+ <tileset firstgid="1" name="tiles" tilewidth="8" tileheight="8">
+  <image source="tiles.png" width="16" height="16"/>
+ </tileset>
+ <layer name="ground" width="10" height="10">
+  <data encoding="csv">
+   <chunk x="-2" y="0" width="2" height="1">1,2</chunk>
+   <chunk x="0" y="-1" width="2" height="1">3,4</chunk>
+  </data>
+ </layer>
+ <layer name="top" width="10" height="10">
+  <data encoding="base64" compression="zlib">
+   <chunk x="0" y="0" width="1" height="1">`+testLayerData(t, 4)+`</chunk>
+  </data>
+ </layer>
+</map>`)
+
+	if lvl.StartX != -2 || lvl.StartY != -1 || lvl.Width != 4 || lvl.Height != 2 {
+		t.Fatalf("Unexpected bounds: %d,%d %dx%d", lvl.StartX, lvl.StartY, lvl.Width, lvl.Height)
+	}
+
+	// Every layer covers the whole map: the top row has tiles 3 and 4 at x=0, the bottom row 1 and 2 at x=-2
+	ground := lvl.TileLayer("ground").Tiles
+	for i, hasImage := range []bool{false, false, true, true, true, true, false, false} {
+		if (ground[i].Image != nil) != hasImage {
+			t.Errorf("Expected tile %d of ground to have an image: %v", i, hasImage)
+		}
+	}
+	if ground[2].Point != (Point{0, -8}) || ground[4].Point != (Point{-16, 0}) {
+		t.Errorf("Unexpected tile locations: %v, %v", ground[2].Point, ground[4].Point)
+	}
+
+	top := lvl.TileLayer("top").Tiles
+	if len(top) != 8 || top[6].Image == nil || top[6].Point != (Point{0, 0}) {
+		t.Errorf("Unexpected top layer: %v", top)
+	}
+}
+
+func parseTestTmx(tmx string) (*TMXLevel, error) {
+	r, err := ParseResource("level.tmx")
+	if err != nil {
+		return nil, err
+	}
+	return parseTmx(MemoryFileSystem{"level.tmx": []byte(tmx)}, r)
+}
@@ -0,0 +1,68 @@
+package engo
+
+// Unload removes the resource from the Loader, and frees whatever it holds on to (like the OpenGL texture of an
+// image), no matter which scenes are using it. It won't be loaded again, unless it's added again.
+func (l *Loader) Unload(name string) error {
+	r, err := ParseResource(name)
+	if err != nil {
+		return err
+	}
+
+	loader, ok := l.loaders[r.kind]
+	if !ok {
+		return l.notLoaded(r.name)
+	}
+	if _, ok := loader.Resource(r.name); !ok {
+		return l.notLoaded(r.name)
+	}
+
+	// The FileLoader gets the resource as it was added, so it knows the URL
+	for _, res := range l.resources {
+		if res.name == r.name {
+			r = res
+			break
+		}
+	}
+	l.forget(r.name)
+
+	return loader.Unload(r)
+}
+
+// disown removes the owner from all resources, and returns the names of the resources it owned
+func (l *Loader) disown(owner string) []string {
+	var names []string
+	for name, owners := range l.owners {
+		if owners[owner] {
+			delete(owners, owner)
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// release unloads the resources which no longer have an owner
+func (l *Loader) release(names []string) {
+	for _, name := range names {
+		if len(l.owners[name]) > 0 {
+			continue // because another scene (or the game itself) still uses it
+		}
+
+		if err := l.Unload(name); err != nil {
+			// The resource failed to load, or was already unloaded, so it only has to be forgotten
+			l.forget(name)
+		}
+	}
+}
+
+// forget removes the resource, so it won't be loaded again
+func (l *Loader) forget(name string) {
+	resources := l.resources[:0]
+	for _, r := range l.resources {
+		if r.name != name {
+			resources = append(resources, r)
+		}
+	}
+	l.resources = resources
+	delete(l.owners, name)
+	delete(l.failed, name)
+}
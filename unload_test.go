@@ -0,0 +1,127 @@
+package engo
+
+import (
+	"testing"
+
+	"engo.io/ecs"
+)
+
+func newUnloadTestLoader(t *testing.T) *Loader {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	l := NewLoader()
+	l.SetFileSystem(MemoryFileSystem{
+		"a.png": testPNG(t, 4),
+		"b.png": testPNG(t, 8),
+		"c.png": testPNG(t, 16),
+	})
+	return l
+}
+
+func TestLoaderUnload(t *testing.T) {
+	l := newUnloadTestLoader(t)
+	l.Add("a.png", "b.png")
+	if err := l.TryLoad(); err != nil {
+		t.Fatal(err)
+	}
+
+	texture := l.Image("a.png")
+	if err := l.Unload("a.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	if texture.Texture() != nil {
+		t.Error("Expected the texture to be closed")
+	}
+	if _, err := l.TryImage("a.png"); err == nil || err.(ResourceError).Err != ErrNotLoaded {
+		t.Errorf("Expected ErrNotLoaded, got %v", err)
+	}
+	if l.Image("b.png") == nil {
+		t.Error("Expected b.png to still be loaded")
+	}
+
+	// It shouldn't come back with the next Load
+	if err := l.TryLoad(); err != nil {
+		t.Fatal(err)
+	}
+	if l.Image("a.png") != nil {
+		t.Error("Expected a.png not to be loaded again")
+	}
+
+	if err := l.Unload("a.png"); err == nil {
+		t.Error("Expected an error when unloading a resource twice")
+	}
+}
+
+func TestLoaderUnloadAtlas(t *testing.T) {
+	l := newUnloadTestLoader(t)
+	l.UseAtlas(AtlasOptions{Width: 64, Height: 64})
+	l.Add("a.png", "b.png")
+	if err := l.TryLoad(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Unload("a.png"); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.AtlasPages()) != 1 {
+		t.Error("Expected the page to stay, while b.png is still using it")
+	}
+
+	if err := l.Unload("b.png"); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.AtlasPages()) != 0 {
+		t.Error("Expected the page to be freed, once nothing is using it")
+	}
+}
+
+type unloadTestScene struct {
+	name  string
+	files []string
+}
+
+func (s *unloadTestScene) Preload()         { Files.Add(s.files...) }
+func (s *unloadTestScene) Setup(*ecs.World) {}
+func (s *unloadTestScene) Type() string     { return s.name }
+
+func TestSceneOwnership(t *testing.T) {
+	WorldBounds = AABB{Point{0, 0}, Point{300, 300}}
+
+	oldFiles := Files
+	defer func() { Files = oldFiles }()
+	Files = newUnloadTestLoader(t)
+
+	// Resources added outside of a scene belong to the game itself
+	Files.Add("c.png")
+	Files.Load(func() {})
+
+	first := &unloadTestScene{name: "UnloadFirstScene", files: []string{"a.png", "b.png"}}
+	second := &unloadTestScene{name: "UnloadSecondScene", files: []string{"b.png"}}
+	SetScene(first, false)
+	SetScene(second, false)
+
+	// Discarding the world of the first scene only releases what no other scene uses
+	first.files = []string{"c.png"}
+	SetScene(first, true)
+
+	if Files.Image("a.png") != nil {
+		t.Error("Expected a.png to be released")
+	}
+	if Files.Image("b.png") == nil {
+		t.Error("Expected b.png to be kept for the second scene")
+	}
+	if Files.Image("c.png") == nil {
+		t.Error("Expected c.png to be kept")
+	}
+
+	second.files = nil
+	SetScene(second, true)
+	if Files.Image("b.png") != nil {
+		t.Error("Expected b.png to be released")
+	}
+	if Files.Image("c.png") == nil {
+		t.Error("Expected c.png to be kept, because the game itself added it")
+	}
+}
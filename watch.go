@@ -0,0 +1,125 @@
+package engo
+
+import (
+	"log"
+	"time"
+)
+
+// DefaultWatchInterval is how often the Loader checks for changed files, when no interval was given to Watch
+const DefaultWatchInterval = time.Second
+
+// AssetReloadedMessage is dispatched whenever the Loader reloaded a resource because its file changed
+type AssetReloadedMessage struct {
+	// Name is the name of the resource, as used by the Loader
+	Name string
+	// URL is the location of the file that changed
+	URL string
+	// Resource is the resource that was reloaded, as returned by Loader.Resource. Textures, Levels and
+	// AnimationSheets are the same object as before, but with new contents; the text of other JSON files is a new
+	// string.
+	Resource interface{}
+}
+
+func (AssetReloadedMessage) Type() string { return "AssetReloadedMessage" }
+
+// ReloadableFileLoader is a FileLoader that can update a resource in place, so everything using it sees the new
+// contents right away
+type ReloadableFileLoader interface {
+	FileLoader
+	// Reload replaces the contents of the loaded resource by the data returned by Decode
+	Reload(r Resource, data interface{}) error
+}
+
+// fileStamp is used to detect changes to a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch makes the Loader check the files of all loaded resources every interval, and reload the ones that changed.
+// It's meant for development: it doesn't need any support of the operating system, but it does read the files on
+// the render thread. Resources can only be reloaded if their FileLoader is a ReloadableFileLoader.
+func (l *Loader) Watch(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	l.watchInterval = interval
+	l.lastCheck = time.Now()
+	l.stamps = make(map[string]fileStamp)
+	l.checkChanges()
+}
+
+// StopWatching stops checking for changed files
+func (l *Loader) StopWatching() {
+	l.watchInterval = 0
+	l.stamps = nil
+}
+
+// updateWatch checks for changed files, if the interval has passed; it's called by the game loop
+func (l *Loader) updateWatch() {
+	if l.watchInterval <= 0 || time.Since(l.lastCheck) < l.watchInterval {
+		return
+	}
+
+	l.lastCheck = time.Now()
+	l.checkChanges()
+}
+
+// checkChanges reloads the resources whose files changed since the last check. Resources it sees for the first
+// time are only remembered.
+func (l *Loader) checkChanges() {
+	checked := make(map[string]bool)
+	for _, r := range l.resources {
+		if checked[r.name] {
+			continue // because it has been checked already
+		}
+		checked[r.name] = true
+
+		loader, ok := l.loaders[r.kind]
+		if !ok {
+			continue // with other resources
+		}
+		if _, loaded := loader.Resource(r.name); !loaded {
+			continue // with other resources
+		}
+
+		info, err := l.fs.Stat(r.url)
+		if err != nil {
+			continue // because it's probably being written to
+		}
+
+		stamp := fileStamp{info.ModTime(), info.Size()}
+		previous, known := l.stamps[r.name]
+		l.stamps[r.name] = stamp
+		if !known || previous == stamp {
+			continue // with other resources
+		}
+
+		if err := l.reload(loader, r); err != nil {
+			log.Println("Error reloading resource:", err)
+		}
+	}
+}
+
+// reload decodes the resource again, and updates it in place
+func (l *Loader) reload(loader FileLoader, r Resource) error {
+	reloader, ok := loader.(ReloadableFileLoader)
+	if !ok {
+		return nil
+	}
+
+	data, err := loader.Decode(l.fs, r)
+	if err != nil {
+		return ResourceError{r.kind, r.url, err}
+	}
+	if err := reloader.Reload(r, data); err != nil {
+		return ResourceError{r.kind, r.url, err}
+	}
+
+	if Mailbox != nil {
+		res, _ := loader.Resource(r.name)
+		Mailbox.Dispatch(AssetReloadedMessage{Name: r.name, URL: r.url, Resource: res})
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package engo
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoaderWatch(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	dir := writeTestAssets(t, map[string]int{"a.png": 4})
+	defer os.RemoveAll(dir)
+
+	var reloaded []AssetReloadedMessage
+	Mailbox.Listen("AssetReloadedMessage", func(msg Message) {
+		reloaded = append(reloaded, msg.(AssetReloadedMessage))
+	})
+
+	l := NewLoader()
+	l.Add(filepath.Join(dir, "a.png"), filepath.Join(dir, "data.json"))
+	if err := l.TryLoad(); err != nil {
+		t.Fatal(err)
+	}
+
+	texture := l.Image("a.png")
+	l.Watch(time.Hour)
+
+	l.checkChanges()
+	if len(reloaded) != 0 {
+		t.Fatalf("Expected nothing to be reloaded while nothing changed, got %v", reloaded)
+	}
+
+	// Change both files, making sure their modification time changes as well
+	f, err := os.Create(filepath.Join(dir, "a.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(f, image.NewNRGBA(image.Rect(0, 0, 8, 8)))
+	f.Close()
+	if err := ioutil.WriteFile(filepath.Join(dir, "data.json"), []byte(`{"answer": 43}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	for _, name := range []string{"a.png", "data.json"} {
+		if err := os.Chtimes(filepath.Join(dir, name), later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l.checkChanges()
+
+	if len(reloaded) != 2 || reloaded[0].Name != "a.png" || reloaded[1].Name != "data.json" {
+		t.Fatalf("Expected a.png and data.json to be reloaded, got %v", reloaded)
+	}
+	if l.Image("a.png") != texture {
+		t.Error("Expected the texture to be updated in place")
+	}
+	if texture.Width() != 8 || reloaded[0].Resource.(*Texture) != texture {
+		t.Errorf("Expected the texture to have the new width, got %v", texture.Width())
+	}
+	if l.Json("data.json") != `{"answer": 43}` {
+		t.Errorf("Expected the new JSON, got %q", l.Json("data.json"))
+	}
+
+	l.checkChanges()
+	if len(reloaded) != 2 {
+		t.Errorf("Expected the files to be reloaded only once, got %d reloads", len(reloaded))
+	}
+
+	l.StopWatching()
+	l.updateWatch()
+}
+
+func TestReloadAnimationSheet(t *testing.T) {
+	headless = true
+	Mailbox = &MessageManager{}
+
+	sheet := `{"frames": [{"filename": "a", "frame": {"w": %d, "h": 4}}], "meta": {"image": "sheet.png"}}`
+	fs := MemoryFileSystem{"sheet.png": testPNG(t, 16), "sheet.json": []byte(fmt.Sprintf(sheet, 4))}
+
+	var reloaded []AssetReloadedMessage
+	Mailbox.Listen("AssetReloadedMessage", func(msg Message) {
+		reloaded = append(reloaded, msg.(AssetReloadedMessage))
+	})
+
+	l := NewLoader()
+	l.SetFileSystem(fs)
+	l.Add("sheet.png", "sheet.json")
+	if err := l.TryLoad(); err != nil {
+		t.Fatal(err)
+	}
+	old := l.AnimationSheet("sheet.json")
+
+	fs["sheet.json"] = []byte(fmt.Sprintf(sheet, 8))
+	if err := l.reload(l.loaders["json"], NewResource("sheet.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reloaded) != 1 || reloaded[0].Resource != old {
+		t.Fatalf("Expected the message to contain the AnimationSheet, got %v", reloaded)
+	}
+	if old.Cell(0).Width() != 8 {
+		t.Errorf("Expected the AnimationSheet to be updated in place, got a width of %v", old.Cell(0).Width())
+	}
+}